@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/JohnBPerkins/url-shortener/internal/health"
+	"github.com/JohnBPerkins/url-shortener/internal/web"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+)
+
+// newAdminMux serves operational endpoints that must not be reachable from
+// the public listener: Prometheus metrics from the private registry, pprof,
+// the health checks and the link export and import, which it calls over
+// conn.
+func newAdminMux(reg *prometheus.Registry, checker *health.Checker, conn *grpc.ClientConn) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg}))
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	mux.HandleFunc("/healthz", checker.LivenessHandler())
+	mux.HandleFunc("/readyz", checker.ReadinessHandler())
+
+	mux.Handle("GET /api/links/export", web.WithRequestLogging(web.NewExportHandler(conn)))
+	mux.Handle("POST /api/links/import", web.WithRequestLogging(web.NewImportHandler(conn)))
+	return mux
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	pb "github.com/JohnBPerkins/url-shortener/gen"
+	"github.com/JohnBPerkins/url-shortener/internal/config"
+	"github.com/JohnBPerkins/url-shortener/internal/service"
+	"github.com/JohnBPerkins/url-shortener/modules/db"
+	"github.com/go-redis/redis/v8"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// command is a subcommand of the binary; run returns the exit status.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+// Commands that call the gRPC API take --addr. The rest read DATABASE_URL (and
+// Redis settings for delete and disable) like the server does.
+var commands = []command{
+	{"serve", "run the HTTP, gRPC and admin servers (default)", runServe},
+	{"create", "shorten URLs through the gRPC API", runCreate},
+	{"get", "show links from Postgres", runGet},
+	{"delete", "delete links from Postgres and Redis", runDelete},
+	{"disable", "stop links resolving but keep them and their fallback", runDisable},
+	{"enable", "re-enable disabled links", runEnable},
+	{"fallback", "show or set an owner's fallback URL", runFallback},
+	{"stats", "summarise the links table", runStats},
+	{"import", "import links with their existing codes through the gRPC API", runImport},
+	{"migrate", "apply pending schema migrations", runMigrate},
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: shortener [command] [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "shortener <command> -h" for a command's flags.`)
+}
+
+// newFlagSet returns a flag set whose -h output shows the command's synopsis
+// and description before its flags.
+func newFlagSet(name, synopsis, description string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: shortener %s\n\n%s\n\nFlags:\n", synopsis, description)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// flagExit is the exit status for a flag parsing error: 0 when -h asked for
+// help, 2 otherwise.
+func flagExit(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	return 2
+}
+
+// apiAddrFlag registers --addr, defaulting to SHORTENER_ADDR when set.
+func apiAddrFlag(fs *flag.FlagSet) *string {
+	addr := os.Getenv("SHORTENER_ADDR")
+	if addr == "" {
+		addr = "localhost:50051"
+	}
+	return fs.String("addr", addr, "gRPC address of the shortener (env SHORTENER_ADDR)")
+}
+
+// adminTokenFlag registers --token, the admin token that admin RPCs such as
+// ImportLinks need, defaulting to ADMIN_TOKEN like the server.
+func adminTokenFlag(fs *flag.FlagSet) *string {
+	return fs.String("token", os.Getenv("ADMIN_TOKEN"), "admin token for the server's admin RPCs (env ADMIN_TOKEN)")
+}
+
+// dialAPI connects to the gRPC API at addr, sending token on every call when
+// it is set. Callers close the connection.
+func dialAPI(addr, token string) (pb.ShortenerClient, *grpc.ClientConn, error) {
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	if token != "" {
+		auth, err := service.NewAdminAuth(token)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts = append(opts, grpc.WithPerRPCCredentials(auth))
+	}
+	conn, err := grpc.NewClient(addr, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pb.NewShortenerClient(conn), conn, nil
+}
+
+// openDatabase connects to Postgres using the server's environment variables.
+func openDatabase(ctx context.Context) (*db.Pool, error) {
+	cfg, err := config.LoadDatabase()
+	if err != nil {
+		return nil, err
+	}
+	pool, err := db.NewPool(ctx, cfg.DatabaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect to Postgres at %s: %s", config.RedactURL(cfg.DatabaseURL), cfg.Scrub(err.Error()))
+	}
+	return pool, nil
+}
+
+// openCache connects to Redis using the server's environment variables.
+func openCache() (*redis.Client, error) {
+	cfg, err := config.LoadRedis()
+	if err != nil {
+		return nil, err
+	}
+	return redis.NewClient(&redis.Options{Addr: cfg.RedisAddr, Password: cfg.RedisPassword}), nil
+}
+
+// commandTimeout registers --timeout, the deadline for the whole command.
+func commandTimeout(fs *flag.FlagSet) *time.Duration {
+	return fs.Duration("timeout", 30*time.Second, "deadline for the command")
+}
+
+// fail prints err to stderr and returns exit status 1.
+func fail(err error) int {
+	fmt.Fprintln(os.Stderr, "error:", err)
+	return 1
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// resolveCache is a size-bounded LRU of resolved codes whose entries expire
+// after a fixed TTL. A nil cache never hits.
+type resolveCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	now     func() time.Time
+	order   *list.List // front is most recently used
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	code    string
+	url     string
+	expires time.Time
+}
+
+func newResolveCache(size int, ttl time.Duration) *resolveCache {
+	return &resolveCache{
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+func (c *resolveCache) get(code string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[code]
+	if !ok {
+		return "", false
+	}
+	entry := el.Value.(*cacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.order.Remove(el)
+		delete(c.entries, code)
+		return "", false
+	}
+	c.order.MoveToFront(el)
+	return entry.url, true
+}
+
+func (c *resolveCache) put(code, url string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := c.now().Add(c.ttl)
+	if el, ok := c.entries[code]; ok {
+		entry := el.Value.(*cacheEntry)
+		entry.url, entry.expires = url, expires
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[code] = c.order.PushFront(&cacheEntry{code: code, url: url, expires: expires})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).code)
+	}
+}
@@ -0,0 +1,286 @@
+// Package client is a Go SDK for the Shortener service. It wraps the
+// generated gRPC client with deadlines, retries on Unavailable, an optional
+// local Resolve cache and an optional fallback to the HTTP API when gRPC
+// cannot be reached.
+//
+//	c, err := client.New("shortener.internal:50051")
+//	if err != nil { ... }
+//	defer c.Close()
+//	code, err := c.Shorten(ctx, "https://example.com/some/long/path")
+package client
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	pb "github.com/JohnBPerkins/url-shortener/gen"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// Defaults for the options below.
+const (
+	DefaultTimeout     = 5 * time.Second
+	DefaultMaxAttempts = 3
+	DefaultBaseBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff  = 2 * time.Second
+)
+
+// Client calls the Shortener service. It is safe for concurrent use.
+type Client struct {
+	primary  transport
+	fallback transport
+	cache    *resolveCache
+	conn     *grpc.ClientConn
+	opts     options
+}
+
+type options struct {
+	apiKey      string
+	owner       string
+	strategy    pb.CodeStrategy
+	timeout     time.Duration
+	maxAttempts int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+	cacheSize   int
+	cacheTTL    time.Duration
+	httpBaseURL string
+	httpClient  *http.Client
+	dialOptions []grpc.DialOption
+}
+
+// Option configures a Client.
+type Option func(*options)
+
+// WithAPIKey sends key as a bearer token in the authorization metadata (or
+// header, over HTTP) of every call. The Shortener server does not check it
+// on the RPCs this client makes, so it is a no-op unless a proxy in front of
+// the service authenticates callers.
+func WithAPIKey(key string) Option {
+	return func(o *options) { o.apiKey = key }
+}
+
+// WithOwner tags every link the client creates with owner.
+func WithOwner(owner string) Option {
+	return func(o *options) { o.owner = owner }
+}
+
+// WithCodeStrategy picks how codes of the client's new links are generated,
+// overriding the server's default.
+func WithCodeStrategy(strategy pb.CodeStrategy) Option {
+	return func(o *options) { o.strategy = strategy }
+}
+
+// WithTimeout bounds each attempt of a call. A deadline on the caller's
+// context still bounds the call as a whole. Zero disables the per-attempt
+// deadline.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) { o.timeout = d }
+}
+
+// WithRetry sets how many times a call is attempted when the service is
+// Unavailable, and the exponential backoff between attempts. Shorten is
+// retried too, so a call that failed after the link was stored can leave an
+// extra code behind.
+func WithRetry(maxAttempts int, base, max time.Duration) Option {
+	return func(o *options) {
+		o.maxAttempts = maxAttempts
+		o.baseBackoff = base
+		o.maxBackoff = max
+	}
+}
+
+// WithResolveCache keeps up to size resolved codes in memory for ttl. Links
+// deleted on the server keep resolving from the cache until they expire.
+// Fallback URLs and responses the server marks no_store, such as those of
+// protected, click-limited or routed links, are never cached.
+func WithResolveCache(size int, ttl time.Duration) Option {
+	return func(o *options) {
+		o.cacheSize = size
+		o.cacheTTL = ttl
+	}
+}
+
+// WithHTTPFallback retries calls against the HTTP API at baseURL (for example
+// https://sho.rt) when gRPC stays Unavailable. A nil httpClient uses
+// http.DefaultClient.
+func WithHTTPFallback(baseURL string, httpClient *http.Client) Option {
+	return func(o *options) {
+		o.httpBaseURL = baseURL
+		o.httpClient = httpClient
+	}
+}
+
+// WithDialOptions replaces the default dial options, which use plaintext.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *options) { o.dialOptions = opts }
+}
+
+// New connects to the gRPC API at target.
+func New(target string, opts ...Option) (*Client, error) {
+	o := defaultOptions(opts)
+	dialOpts := o.dialOptions
+	if dialOpts == nil {
+		dialOpts = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+	conn, err := grpc.NewClient(target, dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+	c := newClient(conn, o)
+	c.conn = conn
+	return c, nil
+}
+
+// NewFromConn uses an existing connection, which the caller keeps ownership
+// of.
+func NewFromConn(conn grpc.ClientConnInterface, opts ...Option) *Client {
+	return newClient(conn, defaultOptions(opts))
+}
+
+func defaultOptions(opts []Option) options {
+	o := options{
+		timeout:     DefaultTimeout,
+		maxAttempts: DefaultMaxAttempts,
+		baseBackoff: DefaultBaseBackoff,
+		maxBackoff:  DefaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.maxAttempts < 1 {
+		o.maxAttempts = 1
+	}
+	return o
+}
+
+func newClient(conn grpc.ClientConnInterface, o options) *Client {
+	c := &Client{
+		primary: &grpcTransport{rpc: pb.NewShortenerClient(conn), apiKey: o.apiKey},
+		opts:    o,
+	}
+	if o.httpBaseURL != "" {
+		c.fallback = newHTTPTransport(o.httpBaseURL, o.httpClient, o.apiKey)
+	}
+	if o.cacheSize > 0 && o.cacheTTL > 0 {
+		c.cache = newResolveCache(o.cacheSize, o.cacheTTL)
+	}
+	return c
+}
+
+// Close closes the connection opened by New. It is a no-op for clients made
+// with NewFromConn.
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// Shorten creates a link for url and returns its code.
+func (c *Client) Shorten(ctx context.Context, url string) (string, error) {
+	req := &pb.ShortenRequest{Url: url, Owner: c.opts.owner, CodeStrategy: c.opts.strategy}
+	var resp *pb.ShortenResponse
+	err := c.call(ctx, func(ctx context.Context, t transport) (err error) {
+		resp, err = t.shorten(ctx, req)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.GetCode(), nil
+}
+
+// BatchShorten creates a link for each URL. Invalid URLs are reported in
+// their result rather than as an error.
+func (c *Client) BatchShorten(ctx context.Context, urls []string) ([]*pb.BatchShortenResult, error) {
+	req := &pb.BatchShortenRequest{Urls: urls, Owner: c.opts.owner, CodeStrategy: c.opts.strategy}
+	var resp *pb.BatchShortenResponse
+	err := c.call(ctx, func(ctx context.Context, t transport) (err error) {
+		resp, err = t.batchShorten(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetResults(), nil
+}
+
+// Resolve returns the URL behind code, from the local cache when enabled.
+// Unknown codes return a NotFound status error.
+func (c *Client) Resolve(ctx context.Context, code string) (string, error) {
+	if url, ok := c.cache.get(code); ok {
+		return url, nil
+	}
+	req := &pb.ResolveRequest{Code: code}
+	var resp *pb.ResolveResponse
+	err := c.call(ctx, func(ctx context.Context, t transport) (err error) {
+		resp, err = t.resolve(ctx, req)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if !resp.GetFallback() && !resp.GetNoStore() {
+		c.cache.put(code, resp.GetUrl())
+	}
+	return resp.GetUrl(), nil
+}
+
+// call runs fn over gRPC with retries, then over HTTP if gRPC is still
+// Unavailable and a fallback is configured.
+func (c *Client) call(ctx context.Context, fn func(context.Context, transport) error) error {
+	err := c.retry(ctx, c.primary, fn)
+	if c.fallback != nil && status.Code(err) == codes.Unavailable {
+		return c.retry(ctx, c.fallback, fn)
+	}
+	return err
+}
+
+func (c *Client) retry(ctx context.Context, t transport, fn func(context.Context, transport) error) error {
+	var err error
+	for attempt := 0; attempt < c.opts.maxAttempts; attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(c.backoff(attempt))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return errors.Join(err, status.FromContextError(ctx.Err()).Err())
+			case <-timer.C:
+			}
+		}
+		err = c.attempt(ctx, t, fn)
+		if status.Code(err) != codes.Unavailable {
+			return err
+		}
+	}
+	return err
+}
+
+func (c *Client) attempt(ctx context.Context, t transport, fn func(context.Context, transport) error) error {
+	if c.opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.timeout)
+		defer cancel()
+	}
+	return fn(ctx, t)
+}
+
+// backoff is the delay before the given retry: exponential with full jitter,
+// capped at maxBackoff.
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.opts.baseBackoff << (attempt - 1)
+	if d <= 0 || d > c.opts.maxBackoff {
+		d = c.opts.maxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d) + 1
+}
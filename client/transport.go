@@ -0,0 +1,186 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	pb "github.com/JohnBPerkins/url-shortener/gen"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// transport is one way of reaching the service. Errors are gRPC status
+// errors whichever transport returns them.
+type transport interface {
+	shorten(ctx context.Context, req *pb.ShortenRequest) (*pb.ShortenResponse, error)
+	resolve(ctx context.Context, req *pb.ResolveRequest) (*pb.ResolveResponse, error)
+	batchShorten(ctx context.Context, req *pb.BatchShortenRequest) (*pb.BatchShortenResponse, error)
+}
+
+// grpcTransport calls the gRPC API.
+type grpcTransport struct {
+	rpc    pb.ShortenerClient
+	apiKey string
+}
+
+func (t *grpcTransport) outgoing(ctx context.Context) context.Context {
+	if t.apiKey == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+t.apiKey)
+}
+
+func (t *grpcTransport) shorten(ctx context.Context, req *pb.ShortenRequest) (*pb.ShortenResponse, error) {
+	return t.rpc.Shorten(t.outgoing(ctx), req)
+}
+
+func (t *grpcTransport) resolve(ctx context.Context, req *pb.ResolveRequest) (*pb.ResolveResponse, error) {
+	return t.rpc.Resolve(t.outgoing(ctx), req)
+}
+
+func (t *grpcTransport) batchShorten(ctx context.Context, req *pb.BatchShortenRequest) (*pb.BatchShortenResponse, error) {
+	return t.rpc.BatchShorten(t.outgoing(ctx), req)
+}
+
+// httpTransport calls the JSON API served by the gateway on the HTTP port.
+type httpTransport struct {
+	baseURL string
+	client  *http.Client
+	apiKey  string
+}
+
+func newHTTPTransport(baseURL string, client *http.Client, apiKey string) *httpTransport {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &httpTransport{baseURL: strings.TrimSuffix(baseURL, "/"), client: client, apiKey: apiKey}
+}
+
+func (t *httpTransport) shorten(ctx context.Context, req *pb.ShortenRequest) (*pb.ShortenResponse, error) {
+	resp := new(pb.ShortenResponse)
+	return resp, t.do(ctx, http.MethodPost, "/api/shorten", req, resp)
+}
+
+func (t *httpTransport) resolve(ctx context.Context, req *pb.ResolveRequest) (*pb.ResolveResponse, error) {
+	resp := new(pb.ResolveResponse)
+	return resp, t.do(ctx, http.MethodGet, "/api/links/"+url.PathEscape(req.GetCode()), nil, resp)
+}
+
+func (t *httpTransport) batchShorten(ctx context.Context, req *pb.BatchShortenRequest) (*pb.BatchShortenResponse, error) {
+	// The bulk endpoint takes the URLs as the body and the other fields as
+	// query parameters.
+	query := url.Values{}
+	if req.GetOwner() != "" {
+		query.Set("owner", req.GetOwner())
+	}
+	if req.GetCodeStrategy() != pb.CodeStrategy_CODE_STRATEGY_UNSPECIFIED {
+		query.Set("code_strategy", req.GetCodeStrategy().String())
+	}
+	path := "/api/shorten/bulk"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	body, err := json.Marshal(req.GetUrls())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := new(pb.BatchShortenResponse)
+	return resp, t.send(ctx, http.MethodPost, path, body, resp)
+}
+
+func (t *httpTransport) do(ctx context.Context, method, path string, req, resp proto.Message) error {
+	var body []byte
+	if req != nil {
+		var err error
+		if body, err = protojson.Marshal(req); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+	return t.send(ctx, method, path, body, resp)
+}
+
+func (t *httpTransport) send(ctx context.Context, method, path string, body []byte, resp proto.Message) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, t.baseURL+path, reader)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if t.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
+
+	httpResp, err := t.client.Do(httpReq)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return status.FromContextError(ctxErr).Err()
+		}
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	defer httpResp.Body.Close()
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return httpError(httpResp.StatusCode, data)
+	}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, resp); err != nil {
+		return status.Errorf(codes.Internal, "decode response: %v", err)
+	}
+	return nil
+}
+
+// httpError turns an error response from the HTTP API back into the status
+// error the gRPC API would have returned.
+func httpError(httpStatus int, body []byte) error {
+	var e struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &e) == nil && e.Code != "" {
+		if code, ok := codeByName[e.Code]; ok {
+			return status.Error(code, e.Message)
+		}
+	}
+	// Load balancers and proxies answer with their own bodies.
+	code := codes.Unknown
+	switch httpStatus {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		code = codes.Unavailable
+	case http.StatusTooManyRequests:
+		code = codes.ResourceExhausted
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	}
+	return status.Error(code, fmt.Sprintf("HTTP %d", httpStatus))
+}
+
+// codeByName maps the code names used in HTTP error bodies, such as
+// "InvalidArgument", to their gRPC codes.
+var codeByName = func() map[string]codes.Code {
+	m := make(map[string]codes.Code)
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		m[c.String()] = c
+	}
+	return m
+}()
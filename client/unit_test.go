@@ -0,0 +1,315 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/JohnBPerkins/url-shortener/gen"
+	"github.com/JohnBPerkins/url-shortener/internal/web"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeShortener fails the first failures calls with Unavailable and records
+// what the rest received.
+type fakeShortener struct {
+	pb.UnimplementedShortenerServer
+
+	mu       sync.Mutex
+	failures int
+	calls    int
+	auth     []string
+	owners   []string
+}
+
+func (f *fakeShortener) call(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.calls <= f.failures {
+		return status.Error(codes.Unavailable, "try again")
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	f.auth = append(f.auth, md.Get("authorization")...)
+	return nil
+}
+
+func (f *fakeShortener) Shorten(ctx context.Context, req *pb.ShortenRequest) (*pb.ShortenResponse, error) {
+	if err := f.call(ctx); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	f.owners = append(f.owners, req.GetOwner())
+	f.mu.Unlock()
+	return &pb.ShortenResponse{Code: "abc12345"}, nil
+}
+
+func (f *fakeShortener) Resolve(ctx context.Context, req *pb.ResolveRequest) (*pb.ResolveResponse, error) {
+	if err := f.call(ctx); err != nil {
+		return nil, err
+	}
+	switch req.GetCode() {
+	case "abc12345":
+		return &pb.ResolveResponse{Url: "https://example.com"}, nil
+	case "fallback":
+		return &pb.ResolveResponse{Url: "https://example.com/gone", Fallback: true, NoStore: true}, nil
+	case "oneclick":
+		return &pb.ResolveResponse{Url: "https://example.com/once", NoStore: true}, nil
+	}
+	return nil, status.Error(codes.NotFound, "short code not found")
+}
+
+func (f *fakeShortener) BatchShorten(ctx context.Context, req *pb.BatchShortenRequest) (*pb.BatchShortenResponse, error) {
+	if err := f.call(ctx); err != nil {
+		return nil, err
+	}
+	resp := &pb.BatchShortenResponse{}
+	for i, u := range req.GetUrls() {
+		resp.Results = append(resp.Results, &pb.BatchShortenResult{Index: int32(i), Url: u, Code: "abc12345"})
+	}
+	return resp, nil
+}
+
+func (f *fakeShortener) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+// serve starts svc on an in-process listener and returns a connection to it.
+func serve(t *testing.T, svc pb.ShortenerServer) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterShortenerServer(srv, svc)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("grpc.NewClient failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+var fastRetry = WithRetry(3, time.Millisecond, 5*time.Millisecond)
+
+func TestRetriesUnavailable(t *testing.T) {
+	svc := &fakeShortener{failures: 2}
+	c := NewFromConn(serve(t, svc), fastRetry, WithAPIKey("secret"), WithOwner("team-a"))
+
+	code, err := c.Shorten(context.Background(), "https://example.com")
+	if err != nil {
+		t.Fatalf("Shorten failed: %v", err)
+	}
+	if code != "abc12345" {
+		t.Errorf("code = %q; want abc12345", code)
+	}
+	if got := svc.callCount(); got != 3 {
+		t.Errorf("calls = %d; want 3", got)
+	}
+	if len(svc.auth) != 1 || svc.auth[0] != "Bearer secret" {
+		t.Errorf("authorization = %q; want [Bearer secret]", svc.auth)
+	}
+	if len(svc.owners) != 1 || svc.owners[0] != "team-a" {
+		t.Errorf("owners = %q; want [team-a]", svc.owners)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	svc := &fakeShortener{failures: 10}
+	c := NewFromConn(serve(t, svc), fastRetry)
+
+	_, err := c.Shorten(context.Background(), "https://example.com")
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("Shorten error = %v; want Unavailable", err)
+	}
+	if got := svc.callCount(); got != 3 {
+		t.Errorf("calls = %d; want 3", got)
+	}
+}
+
+func TestDoesNotRetryOtherErrors(t *testing.T) {
+	svc := &fakeShortener{}
+	c := NewFromConn(serve(t, svc), fastRetry)
+
+	_, err := c.Resolve(context.Background(), "missing1")
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("Resolve error = %v; want NotFound", err)
+	}
+	if got := svc.callCount(); got != 1 {
+		t.Errorf("calls = %d; want 1", got)
+	}
+}
+
+// slowShortener never answers before its caller gives up.
+type slowShortener struct {
+	pb.UnimplementedShortenerServer
+}
+
+func (slowShortener) Resolve(ctx context.Context, _ *pb.ResolveRequest) (*pb.ResolveResponse, error) {
+	<-ctx.Done()
+	return nil, status.FromContextError(ctx.Err()).Err()
+}
+
+func TestTimeout(t *testing.T) {
+	c := NewFromConn(serve(t, slowShortener{}), WithTimeout(20*time.Millisecond))
+
+	start := time.Now()
+	_, err := c.Resolve(context.Background(), "abc12345")
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("Resolve error = %v; want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Resolve took %v; want it bounded by the timeout", elapsed)
+	}
+}
+
+func TestResolveCache(t *testing.T) {
+	svc := &fakeShortener{}
+	c := NewFromConn(serve(t, svc), WithResolveCache(10, time.Minute))
+	now := time.Now()
+	c.cache.now = func() time.Time { return now }
+
+	for range 3 {
+		url, err := c.Resolve(context.Background(), "abc12345")
+		if err != nil {
+			t.Fatalf("Resolve failed: %v", err)
+		}
+		if url != "https://example.com" {
+			t.Errorf("url = %q; want https://example.com", url)
+		}
+	}
+	if got := svc.callCount(); got != 1 {
+		t.Errorf("calls after cached resolves = %d; want 1", got)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := c.Resolve(context.Background(), "abc12345"); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if got := svc.callCount(); got != 2 {
+		t.Errorf("calls after expiry = %d; want 2", got)
+	}
+
+	// Misses are not cached.
+	for range 2 {
+		if _, err := c.Resolve(context.Background(), "missing1"); status.Code(err) != codes.NotFound {
+			t.Fatalf("Resolve error = %v; want NotFound", err)
+		}
+	}
+	if got := svc.callCount(); got != 4 {
+		t.Errorf("calls after misses = %d; want 4", got)
+	}
+
+	// Neither are fallbacks nor responses marked no_store.
+	for _, code := range []string{"fallback", "fallback", "oneclick", "oneclick"} {
+		if _, err := c.Resolve(context.Background(), code); err != nil {
+			t.Fatalf("Resolve(%s) failed: %v", code, err)
+		}
+	}
+	if got := svc.callCount(); got != 8 {
+		t.Errorf("calls after uncacheable resolves = %d; want 8", got)
+	}
+}
+
+func TestResolveCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newResolveCache(2, time.Minute)
+	cache.put("a", "https://a.example")
+	cache.put("b", "https://b.example")
+	cache.get("a")
+	cache.put("c", "https://c.example")
+
+	if _, ok := cache.get("b"); ok {
+		t.Error("b still cached; want it evicted")
+	}
+	for _, code := range []string{"a", "c"} {
+		if _, ok := cache.get(code); !ok {
+			t.Errorf("%s evicted; want it cached", code)
+		}
+	}
+}
+
+func TestHTTPFallback(t *testing.T) {
+	healthy := &fakeShortener{}
+	gateway, err := web.NewGateway(context.Background(), serve(t, healthy))
+	if err != nil {
+		t.Fatalf("NewGateway failed: %v", err)
+	}
+	api := httptest.NewServer(gateway)
+	t.Cleanup(api.Close)
+
+	down := &fakeShortener{failures: 1 << 30}
+	c := NewFromConn(serve(t, down), fastRetry, WithAPIKey("secret"), WithOwner("team-a"),
+		WithHTTPFallback(api.URL, api.Client()))
+
+	code, err := c.Shorten(context.Background(), "https://example.com")
+	if err != nil {
+		t.Fatalf("Shorten failed: %v", err)
+	}
+	if code != "abc12345" {
+		t.Errorf("code = %q; want abc12345", code)
+	}
+	url, err := c.Resolve(context.Background(), "abc12345")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if url != "https://example.com" {
+		t.Errorf("url = %q; want https://example.com", url)
+	}
+	results, err := c.BatchShorten(context.Background(), []string{"https://a.example", "https://b.example"})
+	if err != nil {
+		t.Fatalf("BatchShorten failed: %v", err)
+	}
+	if len(results) != 2 || results[1].GetUrl() != "https://b.example" {
+		t.Errorf("results = %v; want both URLs", results)
+	}
+	if _, err := c.Resolve(context.Background(), "missing1"); status.Code(err) != codes.NotFound {
+		t.Errorf("Resolve error over HTTP = %v; want NotFound", err)
+	}
+
+	if got := down.callCount(); got != 12 {
+		t.Errorf("gRPC calls = %d; want 3 attempts for each of 4 calls", got)
+	}
+	if len(healthy.auth) != 4 || healthy.auth[0] != "Bearer secret" {
+		t.Errorf("authorization over HTTP = %q; want Bearer secret on every call", healthy.auth)
+	}
+	if len(healthy.owners) != 1 || healthy.owners[0] != "team-a" {
+		t.Errorf("owners over HTTP = %q; want [team-a]", healthy.owners)
+	}
+}
+
+func TestHTTPErrorWithoutBody(t *testing.T) {
+	tests := []struct {
+		status int
+		want   codes.Code
+	}{
+		{http.StatusBadGateway, codes.Unavailable},
+		{http.StatusTooManyRequests, codes.ResourceExhausted},
+		{http.StatusTeapot, codes.Unknown},
+	}
+	for _, tt := range tests {
+		if got := status.Code(httpError(tt.status, []byte("<html>"))); got != tt.want {
+			t.Errorf("httpError(%d) = %v; want %v", tt.status, got, tt.want)
+		}
+	}
+	err := httpError(http.StatusBadRequest, []byte(`{"code":"InvalidArgument","message":"invalid URL"}`))
+	if st := status.Convert(err); st.Code() != codes.InvalidArgument || st.Message() != "invalid URL" {
+		t.Errorf("httpError with body = %v; want InvalidArgument: invalid URL", err)
+	}
+}
@@ -7,8 +7,10 @@ interface ShortenResponse {
   code: string;
 }
 
-interface ErrorResponse {
-  error: string;
+// Connect protocol error body; code is a lower_snake_case gRPC code name.
+interface ConnectError {
+  code: string;
+  message?: string;
 }
 
 const urlRegex = /^(?:https?:\/\/)?[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?)*\.[A-Za-z]{2,6}(?::\d{1,5})?(?:[/?#][^\s]*)?$/;
@@ -36,10 +38,12 @@ export function UrlShortener() {
     const finalUrl = url.match(/^https?:\/\//) ? url : `https://${url}`;
 
     try {
-      const response = await fetch(`${config.apiBaseUrl}/api/shorten`, {
+      // Unary Connect call to shortener.Shortener/Shorten with a JSON body.
+      const response = await fetch(`${config.apiBaseUrl}/shortener.Shortener/Shorten`, {
         method: 'POST',
         headers: {
           'Content-Type': 'application/json',
+          'Connect-Protocol-Version': '1',
         },
         body: JSON.stringify({ url: finalUrl }),
       });
@@ -51,8 +55,8 @@ export function UrlShortener() {
         setShortenedUrl(`${config.apiBaseUrl}/${result.code}`);
         setShowResult(true);
       } else {
-        const errorData = data as ErrorResponse;
-        setError(errorData.error || 'Failed to shorten URL');
+        const errorData = data as ConnectError;
+        setError(errorData.message || 'Failed to shorten URL');
       }
     } catch (error) {
       setError(`Error: ${error instanceof Error ? error.message : 'Unknown error'}`);
@@ -0,0 +1,242 @@
+// Code generated by protoc-gen-connect-go. DO NOT EDIT.
+//
+// Source: shortener.proto
+
+package genconnect
+
+import (
+	connect "connectrpc.com/connect"
+	context "context"
+	errors "errors"
+	gen "github.com/JohnBPerkins/url-shortener/gen"
+	http "net/http"
+	strings "strings"
+)
+
+// This is a compile-time assertion to ensure that this generated file and the connect package are
+// compatible. If you get a compiler error that this constant is not defined, this code was
+// generated with a version of connect newer than the one compiled into your binary. You can fix the
+// problem by either regenerating this code with an older version of connect or updating the connect
+// version compiled into your binary.
+const _ = connect.IsAtLeastVersion1_13_0
+
+const (
+	// ShortenerName is the fully-qualified name of the Shortener service.
+	ShortenerName = "shortener.Shortener"
+)
+
+// These constants are the fully-qualified names of the RPCs defined in this package. They're
+// exposed at runtime as Spec.Procedure and as the final two segments of the HTTP route.
+//
+// Note that these are different from the fully-qualified method names used by
+// google.golang.org/protobuf/reflect/protoreflect. To convert from these constants to
+// reflection-formatted method names, remove the leading slash and convert the remaining slash to a
+// period.
+const (
+	// ShortenerShortenProcedure is the fully-qualified name of the Shortener's Shorten RPC.
+	ShortenerShortenProcedure = "/shortener.Shortener/Shorten"
+	// ShortenerResolveProcedure is the fully-qualified name of the Shortener's Resolve RPC.
+	ShortenerResolveProcedure = "/shortener.Shortener/Resolve"
+	// ShortenerBatchShortenProcedure is the fully-qualified name of the Shortener's BatchShorten RPC.
+	ShortenerBatchShortenProcedure = "/shortener.Shortener/BatchShorten"
+	// ShortenerExportLinksProcedure is the fully-qualified name of the Shortener's ExportLinks RPC.
+	ShortenerExportLinksProcedure = "/shortener.Shortener/ExportLinks"
+	// ShortenerImportLinksProcedure is the fully-qualified name of the Shortener's ImportLinks RPC.
+	ShortenerImportLinksProcedure = "/shortener.Shortener/ImportLinks"
+)
+
+// ShortenerClient is a client for the shortener.Shortener service.
+type ShortenerClient interface {
+	Shorten(context.Context, *connect.Request[gen.ShortenRequest]) (*connect.Response[gen.ShortenResponse], error)
+	// Resolve is also bound to POST so that passwords travel in the body;
+	// the gateway refuses them as query parameters.
+	Resolve(context.Context, *connect.Request[gen.ResolveRequest]) (*connect.Response[gen.ResolveResponse], error)
+	// BatchShorten creates a link for each URL in one round trip. Invalid URLs
+	// are reported per item and do not fail the batch.
+	BatchShorten(context.Context, *connect.Request[gen.BatchShortenRequest]) (*connect.Response[gen.BatchShortenResponse], error)
+	// ExportLinks streams every link matching the filters, oldest first.
+	// Protected links carry their password hash. It is an admin RPC: gRPC
+	// callers need the admin token, and over HTTP it is served as NDJSON or
+	// CSV on the admin listener only.
+	ExportLinks(context.Context, *connect.Request[gen.ExportLinksRequest]) (*connect.ServerStreamForClient[gen.Link], error)
+	// ImportLinks stores links under the codes they were given elsewhere.
+	// Problems are reported per link and do not fail the request. It is an
+	// admin RPC like ExportLinks, served over HTTP on the admin listener only.
+	ImportLinks(context.Context, *connect.Request[gen.ImportLinksRequest]) (*connect.Response[gen.ImportLinksResponse], error)
+}
+
+// NewShortenerClient constructs a client for the shortener.Shortener service. By default, it uses
+// the Connect protocol with the binary Protobuf Codec, asks for gzipped responses, and sends
+// uncompressed requests. To use the gRPC or gRPC-Web protocols, supply the connect.WithGRPC() or
+// connect.WithGRPCWeb() options.
+//
+// The URL supplied here should be the base URL for the Connect or gRPC server (for example,
+// http://api.acme.com or https://acme.com/grpc).
+func NewShortenerClient(httpClient connect.HTTPClient, baseURL string, opts ...connect.ClientOption) ShortenerClient {
+	baseURL = strings.TrimRight(baseURL, "/")
+	shortenerMethods := gen.File_shortener_proto.Services().ByName("Shortener").Methods()
+	return &shortenerClient{
+		shorten: connect.NewClient[gen.ShortenRequest, gen.ShortenResponse](
+			httpClient,
+			baseURL+ShortenerShortenProcedure,
+			connect.WithSchema(shortenerMethods.ByName("Shorten")),
+			connect.WithClientOptions(opts...),
+		),
+		resolve: connect.NewClient[gen.ResolveRequest, gen.ResolveResponse](
+			httpClient,
+			baseURL+ShortenerResolveProcedure,
+			connect.WithSchema(shortenerMethods.ByName("Resolve")),
+			connect.WithClientOptions(opts...),
+		),
+		batchShorten: connect.NewClient[gen.BatchShortenRequest, gen.BatchShortenResponse](
+			httpClient,
+			baseURL+ShortenerBatchShortenProcedure,
+			connect.WithSchema(shortenerMethods.ByName("BatchShorten")),
+			connect.WithClientOptions(opts...),
+		),
+		exportLinks: connect.NewClient[gen.ExportLinksRequest, gen.Link](
+			httpClient,
+			baseURL+ShortenerExportLinksProcedure,
+			connect.WithSchema(shortenerMethods.ByName("ExportLinks")),
+			connect.WithClientOptions(opts...),
+		),
+		importLinks: connect.NewClient[gen.ImportLinksRequest, gen.ImportLinksResponse](
+			httpClient,
+			baseURL+ShortenerImportLinksProcedure,
+			connect.WithSchema(shortenerMethods.ByName("ImportLinks")),
+			connect.WithClientOptions(opts...),
+		),
+	}
+}
+
+// shortenerClient implements ShortenerClient.
+type shortenerClient struct {
+	shorten      *connect.Client[gen.ShortenRequest, gen.ShortenResponse]
+	resolve      *connect.Client[gen.ResolveRequest, gen.ResolveResponse]
+	batchShorten *connect.Client[gen.BatchShortenRequest, gen.BatchShortenResponse]
+	exportLinks  *connect.Client[gen.ExportLinksRequest, gen.Link]
+	importLinks  *connect.Client[gen.ImportLinksRequest, gen.ImportLinksResponse]
+}
+
+// Shorten calls shortener.Shortener.Shorten.
+func (c *shortenerClient) Shorten(ctx context.Context, req *connect.Request[gen.ShortenRequest]) (*connect.Response[gen.ShortenResponse], error) {
+	return c.shorten.CallUnary(ctx, req)
+}
+
+// Resolve calls shortener.Shortener.Resolve.
+func (c *shortenerClient) Resolve(ctx context.Context, req *connect.Request[gen.ResolveRequest]) (*connect.Response[gen.ResolveResponse], error) {
+	return c.resolve.CallUnary(ctx, req)
+}
+
+// BatchShorten calls shortener.Shortener.BatchShorten.
+func (c *shortenerClient) BatchShorten(ctx context.Context, req *connect.Request[gen.BatchShortenRequest]) (*connect.Response[gen.BatchShortenResponse], error) {
+	return c.batchShorten.CallUnary(ctx, req)
+}
+
+// ExportLinks calls shortener.Shortener.ExportLinks.
+func (c *shortenerClient) ExportLinks(ctx context.Context, req *connect.Request[gen.ExportLinksRequest]) (*connect.ServerStreamForClient[gen.Link], error) {
+	return c.exportLinks.CallServerStream(ctx, req)
+}
+
+// ImportLinks calls shortener.Shortener.ImportLinks.
+func (c *shortenerClient) ImportLinks(ctx context.Context, req *connect.Request[gen.ImportLinksRequest]) (*connect.Response[gen.ImportLinksResponse], error) {
+	return c.importLinks.CallUnary(ctx, req)
+}
+
+// ShortenerHandler is an implementation of the shortener.Shortener service.
+type ShortenerHandler interface {
+	Shorten(context.Context, *connect.Request[gen.ShortenRequest]) (*connect.Response[gen.ShortenResponse], error)
+	// Resolve is also bound to POST so that passwords travel in the body;
+	// the gateway refuses them as query parameters.
+	Resolve(context.Context, *connect.Request[gen.ResolveRequest]) (*connect.Response[gen.ResolveResponse], error)
+	// BatchShorten creates a link for each URL in one round trip. Invalid URLs
+	// are reported per item and do not fail the batch.
+	BatchShorten(context.Context, *connect.Request[gen.BatchShortenRequest]) (*connect.Response[gen.BatchShortenResponse], error)
+	// ExportLinks streams every link matching the filters, oldest first.
+	// Protected links carry their password hash. It is an admin RPC: gRPC
+	// callers need the admin token, and over HTTP it is served as NDJSON or
+	// CSV on the admin listener only.
+	ExportLinks(context.Context, *connect.Request[gen.ExportLinksRequest], *connect.ServerStream[gen.Link]) error
+	// ImportLinks stores links under the codes they were given elsewhere.
+	// Problems are reported per link and do not fail the request. It is an
+	// admin RPC like ExportLinks, served over HTTP on the admin listener only.
+	ImportLinks(context.Context, *connect.Request[gen.ImportLinksRequest]) (*connect.Response[gen.ImportLinksResponse], error)
+}
+
+// NewShortenerHandler builds an HTTP handler from the service implementation. It returns the path
+// on which to mount the handler and the handler itself.
+//
+// By default, handlers support the Connect, gRPC, and gRPC-Web protocols with the binary Protobuf
+// and JSON codecs. They also support gzip compression.
+func NewShortenerHandler(svc ShortenerHandler, opts ...connect.HandlerOption) (string, http.Handler) {
+	shortenerMethods := gen.File_shortener_proto.Services().ByName("Shortener").Methods()
+	shortenerShortenHandler := connect.NewUnaryHandler(
+		ShortenerShortenProcedure,
+		svc.Shorten,
+		connect.WithSchema(shortenerMethods.ByName("Shorten")),
+		connect.WithHandlerOptions(opts...),
+	)
+	shortenerResolveHandler := connect.NewUnaryHandler(
+		ShortenerResolveProcedure,
+		svc.Resolve,
+		connect.WithSchema(shortenerMethods.ByName("Resolve")),
+		connect.WithHandlerOptions(opts...),
+	)
+	shortenerBatchShortenHandler := connect.NewUnaryHandler(
+		ShortenerBatchShortenProcedure,
+		svc.BatchShorten,
+		connect.WithSchema(shortenerMethods.ByName("BatchShorten")),
+		connect.WithHandlerOptions(opts...),
+	)
+	shortenerExportLinksHandler := connect.NewServerStreamHandler(
+		ShortenerExportLinksProcedure,
+		svc.ExportLinks,
+		connect.WithSchema(shortenerMethods.ByName("ExportLinks")),
+		connect.WithHandlerOptions(opts...),
+	)
+	shortenerImportLinksHandler := connect.NewUnaryHandler(
+		ShortenerImportLinksProcedure,
+		svc.ImportLinks,
+		connect.WithSchema(shortenerMethods.ByName("ImportLinks")),
+		connect.WithHandlerOptions(opts...),
+	)
+	return "/shortener.Shortener/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case ShortenerShortenProcedure:
+			shortenerShortenHandler.ServeHTTP(w, r)
+		case ShortenerResolveProcedure:
+			shortenerResolveHandler.ServeHTTP(w, r)
+		case ShortenerBatchShortenProcedure:
+			shortenerBatchShortenHandler.ServeHTTP(w, r)
+		case ShortenerExportLinksProcedure:
+			shortenerExportLinksHandler.ServeHTTP(w, r)
+		case ShortenerImportLinksProcedure:
+			shortenerImportLinksHandler.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// UnimplementedShortenerHandler returns CodeUnimplemented from all methods.
+type UnimplementedShortenerHandler struct{}
+
+func (UnimplementedShortenerHandler) Shorten(context.Context, *connect.Request[gen.ShortenRequest]) (*connect.Response[gen.ShortenResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("shortener.Shortener.Shorten is not implemented"))
+}
+
+func (UnimplementedShortenerHandler) Resolve(context.Context, *connect.Request[gen.ResolveRequest]) (*connect.Response[gen.ResolveResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("shortener.Shortener.Resolve is not implemented"))
+}
+
+func (UnimplementedShortenerHandler) BatchShorten(context.Context, *connect.Request[gen.BatchShortenRequest]) (*connect.Response[gen.BatchShortenResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("shortener.Shortener.BatchShorten is not implemented"))
+}
+
+func (UnimplementedShortenerHandler) ExportLinks(context.Context, *connect.Request[gen.ExportLinksRequest], *connect.ServerStream[gen.Link]) error {
+	return connect.NewError(connect.CodeUnimplemented, errors.New("shortener.Shortener.ExportLinks is not implemented"))
+}
+
+func (UnimplementedShortenerHandler) ImportLinks(context.Context, *connect.Request[gen.ImportLinksRequest]) (*connect.Response[gen.ImportLinksResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("shortener.Shortener.ImportLinks is not implemented"))
+}
@@ -0,0 +1,9 @@
+package gen
+
+import _ "embed"
+
+// OpenAPISpec is the OpenAPI v2 document protoc-gen-openapiv2 generates from
+// shortener.proto.
+//
+//go:embed shortener.swagger.json
+var OpenAPISpec []byte
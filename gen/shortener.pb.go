@@ -7,8 +7,10 @@
 package gen
 
 import (
+	_ "google.golang.org/genproto/googleapis/api/annotations"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -21,16 +23,289 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// CodeStrategy is how a short code is generated for a new link.
+type CodeStrategy int32
+
+const (
+	CodeStrategy_CODE_STRATEGY_UNSPECIFIED CodeStrategy = 0
+	// The full Base62 encoding of a Sonyflake ID, up to 11 characters and
+	// roughly time-ordered.
+	CodeStrategy_CODE_STRATEGY_SONYFLAKE CodeStrategy = 1
+	// Cryptographically random Base62 characters.
+	CodeStrategy_CODE_STRATEGY_RANDOM CodeStrategy = 2
+	// A Sonyflake ID encoded with an alphabet shuffled by a secret salt, so
+	// consecutive codes look unrelated.
+	CodeStrategy_CODE_STRATEGY_HASHIDS CodeStrategy = 3
+	// Random words and digits such as calm-otter-4821.
+	CodeStrategy_CODE_STRATEGY_WORDS CodeStrategy = 4
+)
+
+// Enum value maps for CodeStrategy.
+var (
+	CodeStrategy_name = map[int32]string{
+		0: "CODE_STRATEGY_UNSPECIFIED",
+		1: "CODE_STRATEGY_SONYFLAKE",
+		2: "CODE_STRATEGY_RANDOM",
+		3: "CODE_STRATEGY_HASHIDS",
+		4: "CODE_STRATEGY_WORDS",
+	}
+	CodeStrategy_value = map[string]int32{
+		"CODE_STRATEGY_UNSPECIFIED": 0,
+		"CODE_STRATEGY_SONYFLAKE":   1,
+		"CODE_STRATEGY_RANDOM":      2,
+		"CODE_STRATEGY_HASHIDS":     3,
+		"CODE_STRATEGY_WORDS":       4,
+	}
+)
+
+func (x CodeStrategy) Enum() *CodeStrategy {
+	p := new(CodeStrategy)
+	*p = x
+	return p
+}
+
+func (x CodeStrategy) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (CodeStrategy) Descriptor() protoreflect.EnumDescriptor {
+	return file_shortener_proto_enumTypes[0].Descriptor()
+}
+
+func (CodeStrategy) Type() protoreflect.EnumType {
+	return &file_shortener_proto_enumTypes[0]
+}
+
+func (x CodeStrategy) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use CodeStrategy.Descriptor instead.
+func (CodeStrategy) EnumDescriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{0}
+}
+
+// Platform is the operating system a visitor's User-Agent reports.
+type Platform int32
+
+const (
+	Platform_PLATFORM_UNSPECIFIED Platform = 0
+	Platform_PLATFORM_IOS         Platform = 1
+	Platform_PLATFORM_ANDROID     Platform = 2
+	Platform_PLATFORM_WINDOWS     Platform = 3
+	Platform_PLATFORM_MACOS       Platform = 4
+	Platform_PLATFORM_LINUX       Platform = 5
+)
+
+// Enum value maps for Platform.
+var (
+	Platform_name = map[int32]string{
+		0: "PLATFORM_UNSPECIFIED",
+		1: "PLATFORM_IOS",
+		2: "PLATFORM_ANDROID",
+		3: "PLATFORM_WINDOWS",
+		4: "PLATFORM_MACOS",
+		5: "PLATFORM_LINUX",
+	}
+	Platform_value = map[string]int32{
+		"PLATFORM_UNSPECIFIED": 0,
+		"PLATFORM_IOS":         1,
+		"PLATFORM_ANDROID":     2,
+		"PLATFORM_WINDOWS":     3,
+		"PLATFORM_MACOS":       4,
+		"PLATFORM_LINUX":       5,
+	}
+)
+
+func (x Platform) Enum() *Platform {
+	p := new(Platform)
+	*p = x
+	return p
+}
+
+func (x Platform) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Platform) Descriptor() protoreflect.EnumDescriptor {
+	return file_shortener_proto_enumTypes[1].Descriptor()
+}
+
+func (Platform) Type() protoreflect.EnumType {
+	return &file_shortener_proto_enumTypes[1]
+}
+
+func (x Platform) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Platform.Descriptor instead.
+func (Platform) EnumDescriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{1}
+}
+
+type ImportStatus int32
+
+const (
+	ImportStatus_IMPORT_STATUS_UNSPECIFIED ImportStatus = 0
+	// The link was imported, or would be on a dry run.
+	ImportStatus_IMPORT_STATUS_IMPORTED ImportStatus = 1
+	// The code or URL breaks the alias rules; see error.
+	ImportStatus_IMPORT_STATUS_INVALID ImportStatus = 2
+	// The code already exists, or appears earlier in the same request.
+	ImportStatus_IMPORT_STATUS_CONFLICT ImportStatus = 3
+)
+
+// Enum value maps for ImportStatus.
+var (
+	ImportStatus_name = map[int32]string{
+		0: "IMPORT_STATUS_UNSPECIFIED",
+		1: "IMPORT_STATUS_IMPORTED",
+		2: "IMPORT_STATUS_INVALID",
+		3: "IMPORT_STATUS_CONFLICT",
+	}
+	ImportStatus_value = map[string]int32{
+		"IMPORT_STATUS_UNSPECIFIED": 0,
+		"IMPORT_STATUS_IMPORTED":    1,
+		"IMPORT_STATUS_INVALID":     2,
+		"IMPORT_STATUS_CONFLICT":    3,
+	}
+)
+
+func (x ImportStatus) Enum() *ImportStatus {
+	p := new(ImportStatus)
+	*p = x
+	return p
+}
+
+func (x ImportStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ImportStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_shortener_proto_enumTypes[2].Descriptor()
+}
+
+func (ImportStatus) Type() protoreflect.EnumType {
+	return &file_shortener_proto_enumTypes[2]
+}
+
+func (x ImportStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ImportStatus.Descriptor instead.
+func (ImportStatus) EnumDescriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{2}
+}
+
+// RoutingRule sends the visitors it matches to url instead of the link's
+// own URL. Every condition that is set must match; a rule with none matches
+// everyone.
+type RoutingRule struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Platforms []Platform             `protobuf:"varint,1,rep,packed,name=platforms,proto3,enum=shortener.Platform" json:"platforms,omitempty"`
+	// languages are language tags such as "en" or "pt-BR", matched against
+	// the visitor's preferred Accept-Language. "en" also matches "en-GB".
+	Languages []string `protobuf:"bytes,2,rep,name=languages,proto3" json:"languages,omitempty"`
+	// countries are ISO 3166-1 alpha-2 codes such as "US".
+	Countries     []string `protobuf:"bytes,3,rep,name=countries,proto3" json:"countries,omitempty"`
+	Url           string   `protobuf:"bytes,4,opt,name=url,proto3" json:"url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RoutingRule) Reset() {
+	*x = RoutingRule{}
+	mi := &file_shortener_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RoutingRule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RoutingRule) ProtoMessage() {}
+
+func (x *RoutingRule) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RoutingRule.ProtoReflect.Descriptor instead.
+func (*RoutingRule) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *RoutingRule) GetPlatforms() []Platform {
+	if x != nil {
+		return x.Platforms
+	}
+	return nil
+}
+
+func (x *RoutingRule) GetLanguages() []string {
+	if x != nil {
+		return x.Languages
+	}
+	return nil
+}
+
+func (x *RoutingRule) GetCountries() []string {
+	if x != nil {
+		return x.Countries
+	}
+	return nil
+}
+
+func (x *RoutingRule) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
 type ShortenRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Url   string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	// owner optionally tags the link with the team or user it belongs to.
+	Owner string `protobuf:"bytes,2,opt,name=owner,proto3" json:"owner,omitempty"`
+	// code_strategy picks how the code is generated. Unspecified uses the
+	// server's CODE_STRATEGY setting.
+	CodeStrategy CodeStrategy `protobuf:"varint,3,opt,name=code_strategy,json=codeStrategy,proto3,enum=shortener.CodeStrategy" json:"code_strategy,omitempty"`
+	// password optionally protects the link: Resolve then requires it. Only
+	// a bcrypt hash is stored, and at most 72 bytes are allowed.
+	Password string `protobuf:"bytes,4,opt,name=password,proto3" json:"password,omitempty"`
+	// max_clicks makes the link stop resolving after that many successful
+	// resolves, such as 1 for a one-time link. 0 means unlimited.
+	MaxClicks int32 `protobuf:"varint,5,opt,name=max_clicks,json=maxClicks,proto3" json:"max_clicks,omitempty"`
+	// not_before and not_after bound when the link resolves. Outside the
+	// window Resolve fails with NotFound, or returns the server's fallback URL
+	// for inactive links if one is configured. Either may be left unset.
+	NotBefore *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=not_before,json=notBefore,proto3" json:"not_before,omitempty"`
+	NotAfter  *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=not_after,json=notAfter,proto3" json:"not_after,omitempty"`
+	// fallback_url is where Resolve sends visitors once the link cannot
+	// resolve: outside its window, out of clicks or disabled. Unset uses the
+	// owner's fallback URL, if any.
+	FallbackUrl string `protobuf:"bytes,8,opt,name=fallback_url,json=fallbackUrl,proto3" json:"fallback_url,omitempty"`
+	// rules are tried in order on every resolve; the first that matches
+	// picks the destination. Visitors no rule matches get url. At most 20.
+	Rules         []*RoutingRule `protobuf:"bytes,9,rep,name=rules,proto3" json:"rules,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ShortenRequest) Reset() {
 	*x = ShortenRequest{}
-	mi := &file_shortener_proto_msgTypes[0]
+	mi := &file_shortener_proto_msgTypes[1]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -42,7 +317,7 @@ func (x *ShortenRequest) String() string {
 func (*ShortenRequest) ProtoMessage() {}
 
 func (x *ShortenRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shortener_proto_msgTypes[0]
+	mi := &file_shortener_proto_msgTypes[1]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -55,7 +330,7 @@ func (x *ShortenRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ShortenRequest.ProtoReflect.Descriptor instead.
 func (*ShortenRequest) Descriptor() ([]byte, []int) {
-	return file_shortener_proto_rawDescGZIP(), []int{0}
+	return file_shortener_proto_rawDescGZIP(), []int{1}
 }
 
 func (x *ShortenRequest) GetUrl() string {
@@ -65,6 +340,62 @@ func (x *ShortenRequest) GetUrl() string {
 	return ""
 }
 
+func (x *ShortenRequest) GetOwner() string {
+	if x != nil {
+		return x.Owner
+	}
+	return ""
+}
+
+func (x *ShortenRequest) GetCodeStrategy() CodeStrategy {
+	if x != nil {
+		return x.CodeStrategy
+	}
+	return CodeStrategy_CODE_STRATEGY_UNSPECIFIED
+}
+
+func (x *ShortenRequest) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+func (x *ShortenRequest) GetMaxClicks() int32 {
+	if x != nil {
+		return x.MaxClicks
+	}
+	return 0
+}
+
+func (x *ShortenRequest) GetNotBefore() *timestamppb.Timestamp {
+	if x != nil {
+		return x.NotBefore
+	}
+	return nil
+}
+
+func (x *ShortenRequest) GetNotAfter() *timestamppb.Timestamp {
+	if x != nil {
+		return x.NotAfter
+	}
+	return nil
+}
+
+func (x *ShortenRequest) GetFallbackUrl() string {
+	if x != nil {
+		return x.FallbackUrl
+	}
+	return ""
+}
+
+func (x *ShortenRequest) GetRules() []*RoutingRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
 type ShortenResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
@@ -74,7 +405,7 @@ type ShortenResponse struct {
 
 func (x *ShortenResponse) Reset() {
 	*x = ShortenResponse{}
-	mi := &file_shortener_proto_msgTypes[1]
+	mi := &file_shortener_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -86,7 +417,7 @@ func (x *ShortenResponse) String() string {
 func (*ShortenResponse) ProtoMessage() {}
 
 func (x *ShortenResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shortener_proto_msgTypes[1]
+	mi := &file_shortener_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -99,7 +430,7 @@ func (x *ShortenResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ShortenResponse.ProtoReflect.Descriptor instead.
 func (*ShortenResponse) Descriptor() ([]byte, []int) {
-	return file_shortener_proto_rawDescGZIP(), []int{1}
+	return file_shortener_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *ShortenResponse) GetCode() string {
@@ -110,15 +441,24 @@ func (x *ShortenResponse) GetCode() string {
 }
 
 type ResolveRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Code  string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	// password is required for links created with one.
+	Password string `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"`
+	// user_agent, accept_language and the country are what routing rules
+	// match. The country is looked up from client_ip in the server's GeoIP
+	// database unless the caller already knows it.
+	UserAgent      string `protobuf:"bytes,3,opt,name=user_agent,json=userAgent,proto3" json:"user_agent,omitempty"`
+	AcceptLanguage string `protobuf:"bytes,4,opt,name=accept_language,json=acceptLanguage,proto3" json:"accept_language,omitempty"`
+	ClientIp       string `protobuf:"bytes,5,opt,name=client_ip,json=clientIp,proto3" json:"client_ip,omitempty"`
+	Country        string `protobuf:"bytes,6,opt,name=country,proto3" json:"country,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
 }
 
 func (x *ResolveRequest) Reset() {
 	*x = ResolveRequest{}
-	mi := &file_shortener_proto_msgTypes[2]
+	mi := &file_shortener_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -130,7 +470,7 @@ func (x *ResolveRequest) String() string {
 func (*ResolveRequest) ProtoMessage() {}
 
 func (x *ResolveRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shortener_proto_msgTypes[2]
+	mi := &file_shortener_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -143,7 +483,7 @@ func (x *ResolveRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ResolveRequest.ProtoReflect.Descriptor instead.
 func (*ResolveRequest) Descriptor() ([]byte, []int) {
-	return file_shortener_proto_rawDescGZIP(), []int{2}
+	return file_shortener_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *ResolveRequest) GetCode() string {
@@ -153,16 +493,60 @@ func (x *ResolveRequest) GetCode() string {
 	return ""
 }
 
+func (x *ResolveRequest) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+func (x *ResolveRequest) GetUserAgent() string {
+	if x != nil {
+		return x.UserAgent
+	}
+	return ""
+}
+
+func (x *ResolveRequest) GetAcceptLanguage() string {
+	if x != nil {
+		return x.AcceptLanguage
+	}
+	return ""
+}
+
+func (x *ResolveRequest) GetClientIp() string {
+	if x != nil {
+		return x.ClientIp
+	}
+	return ""
+}
+
+func (x *ResolveRequest) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
 type ResolveResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Url   string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	// fallback is set when url is a fallback URL rather than the link's own.
+	Fallback bool `protobuf:"varint,2,opt,name=fallback,proto3" json:"fallback,omitempty"`
+	// rule is the 1-based position of the routing rule that chose url, or 0
+	// if no rule matched.
+	Rule int32 `protobuf:"varint,3,opt,name=rule,proto3" json:"rule,omitempty"`
+	// no_store is set when url must not be cached, because it is a fallback
+	// or the link is one the server does not cache either: protected,
+	// click-limited or routed.
+	NoStore       bool `protobuf:"varint,4,opt,name=no_store,json=noStore,proto3" json:"no_store,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ResolveResponse) Reset() {
 	*x = ResolveResponse{}
-	mi := &file_shortener_proto_msgTypes[3]
+	mi := &file_shortener_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -174,7 +558,7 @@ func (x *ResolveResponse) String() string {
 func (*ResolveResponse) ProtoMessage() {}
 
 func (x *ResolveResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shortener_proto_msgTypes[3]
+	mi := &file_shortener_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -187,7 +571,7 @@ func (x *ResolveResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ResolveResponse.ProtoReflect.Descriptor instead.
 func (*ResolveResponse) Descriptor() ([]byte, []int) {
-	return file_shortener_proto_rawDescGZIP(), []int{3}
+	return file_shortener_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *ResolveResponse) GetUrl() string {
@@ -197,22 +581,890 @@ func (x *ResolveResponse) GetUrl() string {
 	return ""
 }
 
-var File_shortener_proto protoreflect.FileDescriptor
+func (x *ResolveResponse) GetFallback() bool {
+	if x != nil {
+		return x.Fallback
+	}
+	return false
+}
 
-const file_shortener_proto_rawDesc = "" +
-	"\n" +
-	"\x0fshortener.proto\x12\tshortener\"\"\n" +
-	"\x0eShortenRequest\x12\x10\n" +
-	"\x03url\x18\x01 \x01(\tR\x03url\"%\n" +
-	"\x0fShortenResponse\x12\x12\n" +
-	"\x04code\x18\x01 \x01(\tR\x04code\"$\n" +
-	"\x0eResolveRequest\x12\x12\n" +
-	"\x04code\x18\x01 \x01(\tR\x04code\"#\n" +
-	"\x0fResolveResponse\x12\x10\n" +
-	"\x03url\x18\x01 \x01(\tR\x03url2\x8f\x01\n" +
-	"\tShortener\x12@\n" +
-	"\aShorten\x12\x19.shortener.ShortenRequest\x1a\x1a.shortener.ShortenResponse\x12@\n" +
-	"\aResolve\x12\x19.shortener.ResolveRequest\x1a\x1a.shortener.ResolveResponseB/Z-github.com/johnbperkins/url-shortener/gen;genb\x06proto3"
+func (x *ResolveResponse) GetRule() int32 {
+	if x != nil {
+		return x.Rule
+	}
+	return 0
+}
+
+func (x *ResolveResponse) GetNoStore() bool {
+	if x != nil {
+		return x.NoStore
+	}
+	return false
+}
+
+type BatchShortenRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Urls  []string               `protobuf:"bytes,1,rep,name=urls,proto3" json:"urls,omitempty"`
+	// owner tags every link in the batch, as in ShortenRequest.
+	Owner string `protobuf:"bytes,2,opt,name=owner,proto3" json:"owner,omitempty"`
+	// code_strategy applies to every link in the batch, as in ShortenRequest.
+	CodeStrategy  CodeStrategy `protobuf:"varint,3,opt,name=code_strategy,json=codeStrategy,proto3,enum=shortener.CodeStrategy" json:"code_strategy,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchShortenRequest) Reset() {
+	*x = BatchShortenRequest{}
+	mi := &file_shortener_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchShortenRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchShortenRequest) ProtoMessage() {}
+
+func (x *BatchShortenRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchShortenRequest.ProtoReflect.Descriptor instead.
+func (*BatchShortenRequest) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *BatchShortenRequest) GetUrls() []string {
+	if x != nil {
+		return x.Urls
+	}
+	return nil
+}
+
+func (x *BatchShortenRequest) GetOwner() string {
+	if x != nil {
+		return x.Owner
+	}
+	return ""
+}
+
+func (x *BatchShortenRequest) GetCodeStrategy() CodeStrategy {
+	if x != nil {
+		return x.CodeStrategy
+	}
+	return CodeStrategy_CODE_STRATEGY_UNSPECIFIED
+}
+
+// BatchShortenResult reports one URL of a BatchShortenRequest. Exactly one of
+// code and error is set.
+type BatchShortenResult struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// index is the URL's position in the request.
+	Index         int32  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Url           string `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	Code          string `protobuf:"bytes,3,opt,name=code,proto3" json:"code,omitempty"`
+	Error         string `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchShortenResult) Reset() {
+	*x = BatchShortenResult{}
+	mi := &file_shortener_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchShortenResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchShortenResult) ProtoMessage() {}
+
+func (x *BatchShortenResult) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchShortenResult.ProtoReflect.Descriptor instead.
+func (*BatchShortenResult) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *BatchShortenResult) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *BatchShortenResult) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *BatchShortenResult) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *BatchShortenResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type BatchShortenResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*BatchShortenResult  `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchShortenResponse) Reset() {
+	*x = BatchShortenResponse{}
+	mi := &file_shortener_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchShortenResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchShortenResponse) ProtoMessage() {}
+
+func (x *BatchShortenResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchShortenResponse.ProtoReflect.Descriptor instead.
+func (*BatchShortenResponse) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *BatchShortenResponse) GetResults() []*BatchShortenResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+// Link is a stored link with everything needed to recreate it through
+// ImportLinks.
+type Link struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Code      string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Url       string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	Owner     string                 `protobuf:"bytes,3,opt,name=owner,proto3" json:"owner,omitempty"`
+	CreatedAt *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	// expires_at is unset for links that never expire.
+	ExpiresAt *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	// password_hash is the bcrypt hash of a protected link's password.
+	PasswordHash string `protobuf:"bytes,6,opt,name=password_hash,json=passwordHash,proto3" json:"password_hash,omitempty"`
+	// clicks is how many of max_clicks were spent when the count was last
+	// written to Postgres.
+	MaxClicks   int32                  `protobuf:"varint,7,opt,name=max_clicks,json=maxClicks,proto3" json:"max_clicks,omitempty"`
+	Clicks      int32                  `protobuf:"varint,8,opt,name=clicks,proto3" json:"clicks,omitempty"`
+	NotBefore   *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=not_before,json=notBefore,proto3" json:"not_before,omitempty"`
+	NotAfter    *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=not_after,json=notAfter,proto3" json:"not_after,omitempty"`
+	FallbackUrl string                 `protobuf:"bytes,11,opt,name=fallback_url,json=fallbackUrl,proto3" json:"fallback_url,omitempty"`
+	// disabled_at is set while the link is disabled.
+	DisabledAt    *timestamppb.Timestamp `protobuf:"bytes,12,opt,name=disabled_at,json=disabledAt,proto3" json:"disabled_at,omitempty"`
+	Rules         []*RoutingRule         `protobuf:"bytes,13,rep,name=rules,proto3" json:"rules,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Link) Reset() {
+	*x = Link{}
+	mi := &file_shortener_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Link) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Link) ProtoMessage() {}
+
+func (x *Link) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Link.ProtoReflect.Descriptor instead.
+func (*Link) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Link) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Link) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *Link) GetOwner() string {
+	if x != nil {
+		return x.Owner
+	}
+	return ""
+}
+
+func (x *Link) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Link) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+func (x *Link) GetPasswordHash() string {
+	if x != nil {
+		return x.PasswordHash
+	}
+	return ""
+}
+
+func (x *Link) GetMaxClicks() int32 {
+	if x != nil {
+		return x.MaxClicks
+	}
+	return 0
+}
+
+func (x *Link) GetClicks() int32 {
+	if x != nil {
+		return x.Clicks
+	}
+	return 0
+}
+
+func (x *Link) GetNotBefore() *timestamppb.Timestamp {
+	if x != nil {
+		return x.NotBefore
+	}
+	return nil
+}
+
+func (x *Link) GetNotAfter() *timestamppb.Timestamp {
+	if x != nil {
+		return x.NotAfter
+	}
+	return nil
+}
+
+func (x *Link) GetFallbackUrl() string {
+	if x != nil {
+		return x.FallbackUrl
+	}
+	return ""
+}
+
+func (x *Link) GetDisabledAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.DisabledAt
+	}
+	return nil
+}
+
+func (x *Link) GetRules() []*RoutingRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+// ExportLinksRequest filters the export. Unset fields match every link.
+type ExportLinksRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Owner string                 `protobuf:"bytes,1,opt,name=owner,proto3" json:"owner,omitempty"`
+	// created_after is inclusive and created_before exclusive.
+	CreatedAfter  *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=created_after,json=createdAfter,proto3" json:"created_after,omitempty"`
+	CreatedBefore *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=created_before,json=createdBefore,proto3" json:"created_before,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExportLinksRequest) Reset() {
+	*x = ExportLinksRequest{}
+	mi := &file_shortener_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExportLinksRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExportLinksRequest) ProtoMessage() {}
+
+func (x *ExportLinksRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExportLinksRequest.ProtoReflect.Descriptor instead.
+func (*ExportLinksRequest) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ExportLinksRequest) GetOwner() string {
+	if x != nil {
+		return x.Owner
+	}
+	return ""
+}
+
+func (x *ExportLinksRequest) GetCreatedAfter() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAfter
+	}
+	return nil
+}
+
+func (x *ExportLinksRequest) GetCreatedBefore() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedBefore
+	}
+	return nil
+}
+
+// ImportLink is a link from another shortener whose code must be kept.
+type ImportLink struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Code  string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Url   string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	Owner string                 `protobuf:"bytes,3,opt,name=owner,proto3" json:"owner,omitempty"`
+	// created_at defaults to the import time. Without expires_at, links
+	// with a window or click limit expire at not_after, or never if they
+	// have none, and other links a day after the import.
+	CreatedAt *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	ExpiresAt *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	// The rest are as in Link, so exported links import unchanged.
+	// password_hash must be a bcrypt hash.
+	PasswordHash  string                 `protobuf:"bytes,6,opt,name=password_hash,json=passwordHash,proto3" json:"password_hash,omitempty"`
+	MaxClicks     int32                  `protobuf:"varint,7,opt,name=max_clicks,json=maxClicks,proto3" json:"max_clicks,omitempty"`
+	Clicks        int32                  `protobuf:"varint,8,opt,name=clicks,proto3" json:"clicks,omitempty"`
+	NotBefore     *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=not_before,json=notBefore,proto3" json:"not_before,omitempty"`
+	NotAfter      *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=not_after,json=notAfter,proto3" json:"not_after,omitempty"`
+	FallbackUrl   string                 `protobuf:"bytes,11,opt,name=fallback_url,json=fallbackUrl,proto3" json:"fallback_url,omitempty"`
+	DisabledAt    *timestamppb.Timestamp `protobuf:"bytes,12,opt,name=disabled_at,json=disabledAt,proto3" json:"disabled_at,omitempty"`
+	Rules         []*RoutingRule         `protobuf:"bytes,13,rep,name=rules,proto3" json:"rules,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImportLink) Reset() {
+	*x = ImportLink{}
+	mi := &file_shortener_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImportLink) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportLink) ProtoMessage() {}
+
+func (x *ImportLink) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportLink.ProtoReflect.Descriptor instead.
+func (*ImportLink) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ImportLink) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *ImportLink) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *ImportLink) GetOwner() string {
+	if x != nil {
+		return x.Owner
+	}
+	return ""
+}
+
+func (x *ImportLink) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *ImportLink) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+func (x *ImportLink) GetPasswordHash() string {
+	if x != nil {
+		return x.PasswordHash
+	}
+	return ""
+}
+
+func (x *ImportLink) GetMaxClicks() int32 {
+	if x != nil {
+		return x.MaxClicks
+	}
+	return 0
+}
+
+func (x *ImportLink) GetClicks() int32 {
+	if x != nil {
+		return x.Clicks
+	}
+	return 0
+}
+
+func (x *ImportLink) GetNotBefore() *timestamppb.Timestamp {
+	if x != nil {
+		return x.NotBefore
+	}
+	return nil
+}
+
+func (x *ImportLink) GetNotAfter() *timestamppb.Timestamp {
+	if x != nil {
+		return x.NotAfter
+	}
+	return nil
+}
+
+func (x *ImportLink) GetFallbackUrl() string {
+	if x != nil {
+		return x.FallbackUrl
+	}
+	return ""
+}
+
+func (x *ImportLink) GetDisabledAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.DisabledAt
+	}
+	return nil
+}
+
+func (x *ImportLink) GetRules() []*RoutingRule {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+type ImportLinksRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Links []*ImportLink          `protobuf:"bytes,1,rep,name=links,proto3" json:"links,omitempty"`
+	// dry_run validates the links and reports conflicts without writing.
+	DryRun        bool `protobuf:"varint,2,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImportLinksRequest) Reset() {
+	*x = ImportLinksRequest{}
+	mi := &file_shortener_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImportLinksRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportLinksRequest) ProtoMessage() {}
+
+func (x *ImportLinksRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportLinksRequest.ProtoReflect.Descriptor instead.
+func (*ImportLinksRequest) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ImportLinksRequest) GetLinks() []*ImportLink {
+	if x != nil {
+		return x.Links
+	}
+	return nil
+}
+
+func (x *ImportLinksRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+type ImportLinkResult struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// index is the link's position in the request.
+	Index  int32        `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Code   string       `protobuf:"bytes,2,opt,name=code,proto3" json:"code,omitempty"`
+	Status ImportStatus `protobuf:"varint,3,opt,name=status,proto3,enum=shortener.ImportStatus" json:"status,omitempty"`
+	Error  string       `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	// existing_url is the URL already stored under code, for conflicts with
+	// existing rows.
+	ExistingUrl   string `protobuf:"bytes,5,opt,name=existing_url,json=existingUrl,proto3" json:"existing_url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImportLinkResult) Reset() {
+	*x = ImportLinkResult{}
+	mi := &file_shortener_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImportLinkResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportLinkResult) ProtoMessage() {}
+
+func (x *ImportLinkResult) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportLinkResult.ProtoReflect.Descriptor instead.
+func (*ImportLinkResult) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ImportLinkResult) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *ImportLinkResult) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *ImportLinkResult) GetStatus() ImportStatus {
+	if x != nil {
+		return x.Status
+	}
+	return ImportStatus_IMPORT_STATUS_UNSPECIFIED
+}
+
+func (x *ImportLinkResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *ImportLinkResult) GetExistingUrl() string {
+	if x != nil {
+		return x.ExistingUrl
+	}
+	return ""
+}
+
+type ImportLinksResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*ImportLinkResult    `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	Imported      int32                  `protobuf:"varint,2,opt,name=imported,proto3" json:"imported,omitempty"`
+	Invalid       int32                  `protobuf:"varint,3,opt,name=invalid,proto3" json:"invalid,omitempty"`
+	Conflicts     int32                  `protobuf:"varint,4,opt,name=conflicts,proto3" json:"conflicts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImportLinksResponse) Reset() {
+	*x = ImportLinksResponse{}
+	mi := &file_shortener_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImportLinksResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportLinksResponse) ProtoMessage() {}
+
+func (x *ImportLinksResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_shortener_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportLinksResponse.ProtoReflect.Descriptor instead.
+func (*ImportLinksResponse) Descriptor() ([]byte, []int) {
+	return file_shortener_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ImportLinksResponse) GetResults() []*ImportLinkResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+func (x *ImportLinksResponse) GetImported() int32 {
+	if x != nil {
+		return x.Imported
+	}
+	return 0
+}
+
+func (x *ImportLinksResponse) GetInvalid() int32 {
+	if x != nil {
+		return x.Invalid
+	}
+	return 0
+}
+
+func (x *ImportLinksResponse) GetConflicts() int32 {
+	if x != nil {
+		return x.Conflicts
+	}
+	return 0
+}
+
+var File_shortener_proto protoreflect.FileDescriptor
+
+const file_shortener_proto_rawDesc = "" +
+	"\n" +
+	"\x0fshortener.proto\x12\tshortener\x1a\x1cgoogle/api/annotations.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\x8e\x01\n" +
+	"\vRoutingRule\x121\n" +
+	"\tplatforms\x18\x01 \x03(\x0e2\x13.shortener.PlatformR\tplatforms\x12\x1c\n" +
+	"\tlanguages\x18\x02 \x03(\tR\tlanguages\x12\x1c\n" +
+	"\tcountries\x18\x03 \x03(\tR\tcountries\x12\x10\n" +
+	"\x03url\x18\x04 \x01(\tR\x03url\"\xf6\x02\n" +
+	"\x0eShortenRequest\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12\x14\n" +
+	"\x05owner\x18\x02 \x01(\tR\x05owner\x12<\n" +
+	"\rcode_strategy\x18\x03 \x01(\x0e2\x17.shortener.CodeStrategyR\fcodeStrategy\x12\x1a\n" +
+	"\bpassword\x18\x04 \x01(\tR\bpassword\x12\x1d\n" +
+	"\n" +
+	"max_clicks\x18\x05 \x01(\x05R\tmaxClicks\x129\n" +
+	"\n" +
+	"not_before\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tnotBefore\x127\n" +
+	"\tnot_after\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\bnotAfter\x12!\n" +
+	"\ffallback_url\x18\b \x01(\tR\vfallbackUrl\x12,\n" +
+	"\x05rules\x18\t \x03(\v2\x16.shortener.RoutingRuleR\x05rules\"%\n" +
+	"\x0fShortenResponse\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\"\xd2\x01\n" +
+	"\x0eResolveRequest\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x1a\n" +
+	"\bpassword\x18\x02 \x01(\tR\bpassword\x12\x1d\n" +
+	"\n" +
+	"user_agent\x18\x03 \x01(\tR\tuserAgent\x12'\n" +
+	"\x0faccept_language\x18\x04 \x01(\tR\x0eacceptLanguage\x12\x1b\n" +
+	"\tclient_ip\x18\x05 \x01(\tR\bclientIp\x12\x18\n" +
+	"\acountry\x18\x06 \x01(\tR\acountryJ\x04\b\a\x10\bR\vcount_click\"n\n" +
+	"\x0fResolveResponse\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12\x1a\n" +
+	"\bfallback\x18\x02 \x01(\bR\bfallback\x12\x12\n" +
+	"\x04rule\x18\x03 \x01(\x05R\x04rule\x12\x19\n" +
+	"\bno_store\x18\x04 \x01(\bR\anoStore\"}\n" +
+	"\x13BatchShortenRequest\x12\x12\n" +
+	"\x04urls\x18\x01 \x03(\tR\x04urls\x12\x14\n" +
+	"\x05owner\x18\x02 \x01(\tR\x05owner\x12<\n" +
+	"\rcode_strategy\x18\x03 \x01(\x0e2\x17.shortener.CodeStrategyR\fcodeStrategy\"f\n" +
+	"\x12BatchShortenResult\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\x12\x12\n" +
+	"\x04code\x18\x03 \x01(\tR\x04code\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\"O\n" +
+	"\x14BatchShortenResponse\x127\n" +
+	"\aresults\x18\x01 \x03(\v2\x1d.shortener.BatchShortenResultR\aresults\"\x96\x04\n" +
+	"\x04Link\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\x12\x14\n" +
+	"\x05owner\x18\x03 \x01(\tR\x05owner\x129\n" +
+	"\n" +
+	"created_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"expires_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\x12#\n" +
+	"\rpassword_hash\x18\x06 \x01(\tR\fpasswordHash\x12\x1d\n" +
+	"\n" +
+	"max_clicks\x18\a \x01(\x05R\tmaxClicks\x12\x16\n" +
+	"\x06clicks\x18\b \x01(\x05R\x06clicks\x129\n" +
+	"\n" +
+	"not_before\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\tnotBefore\x127\n" +
+	"\tnot_after\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\bnotAfter\x12!\n" +
+	"\ffallback_url\x18\v \x01(\tR\vfallbackUrl\x12;\n" +
+	"\vdisabled_at\x18\f \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"disabledAt\x12,\n" +
+	"\x05rules\x18\r \x03(\v2\x16.shortener.RoutingRuleR\x05rules\"\xae\x01\n" +
+	"\x12ExportLinksRequest\x12\x14\n" +
+	"\x05owner\x18\x01 \x01(\tR\x05owner\x12?\n" +
+	"\rcreated_after\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\fcreatedAfter\x12A\n" +
+	"\x0ecreated_before\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\rcreatedBefore\"\x9c\x04\n" +
+	"\n" +
+	"ImportLink\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\x12\x14\n" +
+	"\x05owner\x18\x03 \x01(\tR\x05owner\x129\n" +
+	"\n" +
+	"created_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"expires_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\x12#\n" +
+	"\rpassword_hash\x18\x06 \x01(\tR\fpasswordHash\x12\x1d\n" +
+	"\n" +
+	"max_clicks\x18\a \x01(\x05R\tmaxClicks\x12\x16\n" +
+	"\x06clicks\x18\b \x01(\x05R\x06clicks\x129\n" +
+	"\n" +
+	"not_before\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\tnotBefore\x127\n" +
+	"\tnot_after\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\bnotAfter\x12!\n" +
+	"\ffallback_url\x18\v \x01(\tR\vfallbackUrl\x12;\n" +
+	"\vdisabled_at\x18\f \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"disabledAt\x12,\n" +
+	"\x05rules\x18\r \x03(\v2\x16.shortener.RoutingRuleR\x05rules\"Z\n" +
+	"\x12ImportLinksRequest\x12+\n" +
+	"\x05links\x18\x01 \x03(\v2\x15.shortener.ImportLinkR\x05links\x12\x17\n" +
+	"\adry_run\x18\x02 \x01(\bR\x06dryRun\"\xa6\x01\n" +
+	"\x10ImportLinkResult\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x12\n" +
+	"\x04code\x18\x02 \x01(\tR\x04code\x12/\n" +
+	"\x06status\x18\x03 \x01(\x0e2\x17.shortener.ImportStatusR\x06status\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\x12!\n" +
+	"\fexisting_url\x18\x05 \x01(\tR\vexistingUrl\"\xa0\x01\n" +
+	"\x13ImportLinksResponse\x125\n" +
+	"\aresults\x18\x01 \x03(\v2\x1b.shortener.ImportLinkResultR\aresults\x12\x1a\n" +
+	"\bimported\x18\x02 \x01(\x05R\bimported\x12\x18\n" +
+	"\ainvalid\x18\x03 \x01(\x05R\ainvalid\x12\x1c\n" +
+	"\tconflicts\x18\x04 \x01(\x05R\tconflicts*\x98\x01\n" +
+	"\fCodeStrategy\x12\x1d\n" +
+	"\x19CODE_STRATEGY_UNSPECIFIED\x10\x00\x12\x1b\n" +
+	"\x17CODE_STRATEGY_SONYFLAKE\x10\x01\x12\x18\n" +
+	"\x14CODE_STRATEGY_RANDOM\x10\x02\x12\x19\n" +
+	"\x15CODE_STRATEGY_HASHIDS\x10\x03\x12\x17\n" +
+	"\x13CODE_STRATEGY_WORDS\x10\x04*\x8a\x01\n" +
+	"\bPlatform\x12\x18\n" +
+	"\x14PLATFORM_UNSPECIFIED\x10\x00\x12\x10\n" +
+	"\fPLATFORM_IOS\x10\x01\x12\x14\n" +
+	"\x10PLATFORM_ANDROID\x10\x02\x12\x14\n" +
+	"\x10PLATFORM_WINDOWS\x10\x03\x12\x12\n" +
+	"\x0ePLATFORM_MACOS\x10\x04\x12\x12\n" +
+	"\x0ePLATFORM_LINUX\x10\x05*\x80\x01\n" +
+	"\fImportStatus\x12\x1d\n" +
+	"\x19IMPORT_STATUS_UNSPECIFIED\x10\x00\x12\x1a\n" +
+	"\x16IMPORT_STATUS_IMPORTED\x10\x01\x12\x19\n" +
+	"\x15IMPORT_STATUS_INVALID\x10\x02\x12\x1a\n" +
+	"\x16IMPORT_STATUS_CONFLICT\x10\x032\xe4\x03\n" +
+	"\tShortener\x12Y\n" +
+	"\aShorten\x12\x19.shortener.ShortenRequest\x1a\x1a.shortener.ShortenResponse\"\x17\x82\xd3\xe4\x93\x02\x11:\x01*\"\f/api/shorten\x12{\n" +
+	"\aResolve\x12\x19.shortener.ResolveRequest\x1a\x1a.shortener.ResolveResponse\"9\x82\xd3\xe4\x93\x023Z\x1e:\x01*\"\x19/api/links/{code}/resolve\x12\x11/api/links/{code}\x12p\n" +
+	"\fBatchShorten\x12\x1e.shortener.BatchShortenRequest\x1a\x1f.shortener.BatchShortenResponse\"\x1f\x82\xd3\xe4\x93\x02\x19:\x04urls\"\x11/api/shorten/bulk\x12?\n" +
+	"\vExportLinks\x12\x1d.shortener.ExportLinksRequest\x1a\x0f.shortener.Link0\x01\x12L\n" +
+	"\vImportLinks\x12\x1d.shortener.ImportLinksRequest\x1a\x1e.shortener.ImportLinksResponseB/Z-github.com/JohnBPerkins/url-shortener/gen;genb\x06proto3"
 
 var (
 	file_shortener_proto_rawDescOnce sync.Once
@@ -226,23 +1478,68 @@ func file_shortener_proto_rawDescGZIP() []byte {
 	return file_shortener_proto_rawDescData
 }
 
-var file_shortener_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_shortener_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_shortener_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
 var file_shortener_proto_goTypes = []any{
-	(*ShortenRequest)(nil),  // 0: shortener.ShortenRequest
-	(*ShortenResponse)(nil), // 1: shortener.ShortenResponse
-	(*ResolveRequest)(nil),  // 2: shortener.ResolveRequest
-	(*ResolveResponse)(nil), // 3: shortener.ResolveResponse
+	(CodeStrategy)(0),             // 0: shortener.CodeStrategy
+	(Platform)(0),                 // 1: shortener.Platform
+	(ImportStatus)(0),             // 2: shortener.ImportStatus
+	(*RoutingRule)(nil),           // 3: shortener.RoutingRule
+	(*ShortenRequest)(nil),        // 4: shortener.ShortenRequest
+	(*ShortenResponse)(nil),       // 5: shortener.ShortenResponse
+	(*ResolveRequest)(nil),        // 6: shortener.ResolveRequest
+	(*ResolveResponse)(nil),       // 7: shortener.ResolveResponse
+	(*BatchShortenRequest)(nil),   // 8: shortener.BatchShortenRequest
+	(*BatchShortenResult)(nil),    // 9: shortener.BatchShortenResult
+	(*BatchShortenResponse)(nil),  // 10: shortener.BatchShortenResponse
+	(*Link)(nil),                  // 11: shortener.Link
+	(*ExportLinksRequest)(nil),    // 12: shortener.ExportLinksRequest
+	(*ImportLink)(nil),            // 13: shortener.ImportLink
+	(*ImportLinksRequest)(nil),    // 14: shortener.ImportLinksRequest
+	(*ImportLinkResult)(nil),      // 15: shortener.ImportLinkResult
+	(*ImportLinksResponse)(nil),   // 16: shortener.ImportLinksResponse
+	(*timestamppb.Timestamp)(nil), // 17: google.protobuf.Timestamp
 }
 var file_shortener_proto_depIdxs = []int32{
-	0, // 0: shortener.Shortener.Shorten:input_type -> shortener.ShortenRequest
-	2, // 1: shortener.Shortener.Resolve:input_type -> shortener.ResolveRequest
-	1, // 2: shortener.Shortener.Shorten:output_type -> shortener.ShortenResponse
-	3, // 3: shortener.Shortener.Resolve:output_type -> shortener.ResolveResponse
-	2, // [2:4] is the sub-list for method output_type
-	0, // [0:2] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	1,  // 0: shortener.RoutingRule.platforms:type_name -> shortener.Platform
+	0,  // 1: shortener.ShortenRequest.code_strategy:type_name -> shortener.CodeStrategy
+	17, // 2: shortener.ShortenRequest.not_before:type_name -> google.protobuf.Timestamp
+	17, // 3: shortener.ShortenRequest.not_after:type_name -> google.protobuf.Timestamp
+	3,  // 4: shortener.ShortenRequest.rules:type_name -> shortener.RoutingRule
+	0,  // 5: shortener.BatchShortenRequest.code_strategy:type_name -> shortener.CodeStrategy
+	9,  // 6: shortener.BatchShortenResponse.results:type_name -> shortener.BatchShortenResult
+	17, // 7: shortener.Link.created_at:type_name -> google.protobuf.Timestamp
+	17, // 8: shortener.Link.expires_at:type_name -> google.protobuf.Timestamp
+	17, // 9: shortener.Link.not_before:type_name -> google.protobuf.Timestamp
+	17, // 10: shortener.Link.not_after:type_name -> google.protobuf.Timestamp
+	17, // 11: shortener.Link.disabled_at:type_name -> google.protobuf.Timestamp
+	3,  // 12: shortener.Link.rules:type_name -> shortener.RoutingRule
+	17, // 13: shortener.ExportLinksRequest.created_after:type_name -> google.protobuf.Timestamp
+	17, // 14: shortener.ExportLinksRequest.created_before:type_name -> google.protobuf.Timestamp
+	17, // 15: shortener.ImportLink.created_at:type_name -> google.protobuf.Timestamp
+	17, // 16: shortener.ImportLink.expires_at:type_name -> google.protobuf.Timestamp
+	17, // 17: shortener.ImportLink.not_before:type_name -> google.protobuf.Timestamp
+	17, // 18: shortener.ImportLink.not_after:type_name -> google.protobuf.Timestamp
+	17, // 19: shortener.ImportLink.disabled_at:type_name -> google.protobuf.Timestamp
+	3,  // 20: shortener.ImportLink.rules:type_name -> shortener.RoutingRule
+	13, // 21: shortener.ImportLinksRequest.links:type_name -> shortener.ImportLink
+	2,  // 22: shortener.ImportLinkResult.status:type_name -> shortener.ImportStatus
+	15, // 23: shortener.ImportLinksResponse.results:type_name -> shortener.ImportLinkResult
+	4,  // 24: shortener.Shortener.Shorten:input_type -> shortener.ShortenRequest
+	6,  // 25: shortener.Shortener.Resolve:input_type -> shortener.ResolveRequest
+	8,  // 26: shortener.Shortener.BatchShorten:input_type -> shortener.BatchShortenRequest
+	12, // 27: shortener.Shortener.ExportLinks:input_type -> shortener.ExportLinksRequest
+	14, // 28: shortener.Shortener.ImportLinks:input_type -> shortener.ImportLinksRequest
+	5,  // 29: shortener.Shortener.Shorten:output_type -> shortener.ShortenResponse
+	7,  // 30: shortener.Shortener.Resolve:output_type -> shortener.ResolveResponse
+	10, // 31: shortener.Shortener.BatchShorten:output_type -> shortener.BatchShortenResponse
+	11, // 32: shortener.Shortener.ExportLinks:output_type -> shortener.Link
+	16, // 33: shortener.Shortener.ImportLinks:output_type -> shortener.ImportLinksResponse
+	29, // [29:34] is the sub-list for method output_type
+	24, // [24:29] is the sub-list for method input_type
+	24, // [24:24] is the sub-list for extension type_name
+	24, // [24:24] is the sub-list for extension extendee
+	0,  // [0:24] is the sub-list for field type_name
 }
 
 func init() { file_shortener_proto_init() }
@@ -255,13 +1552,14 @@ func file_shortener_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_shortener_proto_rawDesc), len(file_shortener_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   4,
+			NumEnums:      3,
+			NumMessages:   14,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_shortener_proto_goTypes,
 		DependencyIndexes: file_shortener_proto_depIdxs,
+		EnumInfos:         file_shortener_proto_enumTypes,
 		MessageInfos:      file_shortener_proto_msgTypes,
 	}.Build()
 	File_shortener_proto = out.File
@@ -19,16 +19,37 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	Shortener_Shorten_FullMethodName = "/shortener.Shortener/Shorten"
-	Shortener_Resolve_FullMethodName = "/shortener.Shortener/Resolve"
+	Shortener_Shorten_FullMethodName      = "/shortener.Shortener/Shorten"
+	Shortener_Resolve_FullMethodName      = "/shortener.Shortener/Resolve"
+	Shortener_BatchShorten_FullMethodName = "/shortener.Shortener/BatchShorten"
+	Shortener_ExportLinks_FullMethodName  = "/shortener.Shortener/ExportLinks"
+	Shortener_ImportLinks_FullMethodName  = "/shortener.Shortener/ImportLinks"
 )
 
 // ShortenerClient is the client API for Shortener service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Every public RPC carries a google.api.http annotation so the REST gateway
+// and the OpenAPI spec are generated from this file; new ones need one too.
+// Admin RPCs carry none, so the public listener never serves them.
 type ShortenerClient interface {
 	Shorten(ctx context.Context, in *ShortenRequest, opts ...grpc.CallOption) (*ShortenResponse, error)
+	// Resolve is also bound to POST so that passwords travel in the body;
+	// the gateway refuses them as query parameters.
 	Resolve(ctx context.Context, in *ResolveRequest, opts ...grpc.CallOption) (*ResolveResponse, error)
+	// BatchShorten creates a link for each URL in one round trip. Invalid URLs
+	// are reported per item and do not fail the batch.
+	BatchShorten(ctx context.Context, in *BatchShortenRequest, opts ...grpc.CallOption) (*BatchShortenResponse, error)
+	// ExportLinks streams every link matching the filters, oldest first.
+	// Protected links carry their password hash. It is an admin RPC: gRPC
+	// callers need the admin token, and over HTTP it is served as NDJSON or
+	// CSV on the admin listener only.
+	ExportLinks(ctx context.Context, in *ExportLinksRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Link], error)
+	// ImportLinks stores links under the codes they were given elsewhere.
+	// Problems are reported per link and do not fail the request. It is an
+	// admin RPC like ExportLinks, served over HTTP on the admin listener only.
+	ImportLinks(ctx context.Context, in *ImportLinksRequest, opts ...grpc.CallOption) (*ImportLinksResponse, error)
 }
 
 type shortenerClient struct {
@@ -59,12 +80,69 @@ func (c *shortenerClient) Resolve(ctx context.Context, in *ResolveRequest, opts
 	return out, nil
 }
 
+func (c *shortenerClient) BatchShorten(ctx context.Context, in *BatchShortenRequest, opts ...grpc.CallOption) (*BatchShortenResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchShortenResponse)
+	err := c.cc.Invoke(ctx, Shortener_BatchShorten_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerClient) ExportLinks(ctx context.Context, in *ExportLinksRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Link], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Shortener_ServiceDesc.Streams[0], Shortener_ExportLinks_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExportLinksRequest, Link]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Shortener_ExportLinksClient = grpc.ServerStreamingClient[Link]
+
+func (c *shortenerClient) ImportLinks(ctx context.Context, in *ImportLinksRequest, opts ...grpc.CallOption) (*ImportLinksResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ImportLinksResponse)
+	err := c.cc.Invoke(ctx, Shortener_ImportLinks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ShortenerServer is the server API for Shortener service.
 // All implementations must embed UnimplementedShortenerServer
 // for forward compatibility.
+//
+// Every public RPC carries a google.api.http annotation so the REST gateway
+// and the OpenAPI spec are generated from this file; new ones need one too.
+// Admin RPCs carry none, so the public listener never serves them.
 type ShortenerServer interface {
 	Shorten(context.Context, *ShortenRequest) (*ShortenResponse, error)
+	// Resolve is also bound to POST so that passwords travel in the body;
+	// the gateway refuses them as query parameters.
 	Resolve(context.Context, *ResolveRequest) (*ResolveResponse, error)
+	// BatchShorten creates a link for each URL in one round trip. Invalid URLs
+	// are reported per item and do not fail the batch.
+	BatchShorten(context.Context, *BatchShortenRequest) (*BatchShortenResponse, error)
+	// ExportLinks streams every link matching the filters, oldest first.
+	// Protected links carry their password hash. It is an admin RPC: gRPC
+	// callers need the admin token, and over HTTP it is served as NDJSON or
+	// CSV on the admin listener only.
+	ExportLinks(*ExportLinksRequest, grpc.ServerStreamingServer[Link]) error
+	// ImportLinks stores links under the codes they were given elsewhere.
+	// Problems are reported per link and do not fail the request. It is an
+	// admin RPC like ExportLinks, served over HTTP on the admin listener only.
+	ImportLinks(context.Context, *ImportLinksRequest) (*ImportLinksResponse, error)
 	mustEmbedUnimplementedShortenerServer()
 }
 
@@ -81,6 +159,15 @@ func (UnimplementedShortenerServer) Shorten(context.Context, *ShortenRequest) (*
 func (UnimplementedShortenerServer) Resolve(context.Context, *ResolveRequest) (*ResolveResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Resolve not implemented")
 }
+func (UnimplementedShortenerServer) BatchShorten(context.Context, *BatchShortenRequest) (*BatchShortenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchShorten not implemented")
+}
+func (UnimplementedShortenerServer) ExportLinks(*ExportLinksRequest, grpc.ServerStreamingServer[Link]) error {
+	return status.Errorf(codes.Unimplemented, "method ExportLinks not implemented")
+}
+func (UnimplementedShortenerServer) ImportLinks(context.Context, *ImportLinksRequest) (*ImportLinksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ImportLinks not implemented")
+}
 func (UnimplementedShortenerServer) mustEmbedUnimplementedShortenerServer() {}
 func (UnimplementedShortenerServer) testEmbeddedByValue()                   {}
 
@@ -138,6 +225,53 @@ func _Shortener_Resolve_Handler(srv interface{}, ctx context.Context, dec func(i
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Shortener_BatchShorten_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchShortenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).BatchShorten(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_BatchShorten_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).BatchShorten(ctx, req.(*BatchShortenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_ExportLinks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportLinksRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ShortenerServer).ExportLinks(m, &grpc.GenericServerStream[ExportLinksRequest, Link]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Shortener_ExportLinksServer = grpc.ServerStreamingServer[Link]
+
+func _Shortener_ImportLinks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportLinksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).ImportLinks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_ImportLinks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).ImportLinks(ctx, req.(*ImportLinksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Shortener_ServiceDesc is the grpc.ServiceDesc for Shortener service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -153,7 +287,21 @@ var Shortener_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Resolve",
 			Handler:    _Shortener_Resolve_Handler,
 		},
+		{
+			MethodName: "BatchShorten",
+			Handler:    _Shortener_BatchShorten_Handler,
+		},
+		{
+			MethodName: "ImportLinks",
+			Handler:    _Shortener_ImportLinks_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ExportLinks",
+			Handler:       _Shortener_ExportLinks_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "shortener.proto",
 }
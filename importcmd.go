@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/JohnBPerkins/url-shortener/gen"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// runImport implements the import subcommand and returns the exit status:
+// 0 if every row was imported, 1 if any was rejected, 2 on usage errors.
+func runImport(args []string) int {
+	fs := newFlagSet("import", "import [flags] FILE",
+		`Imports code,url[,created_at,expires_at,owner] rows from a CSV file ("-" for
+stdin) through the ImportLinks RPC, keeping the codes. A header row naming the
+columns may list them in any order, and may add the other columns of a CSV
+export, so an export imports as is. Timestamps are RFC 3339.`)
+	addr := apiAddrFlag(fs)
+	token := adminTokenFlag(fs)
+	dryRun := fs.Bool("dry-run", false, "validate rows and report conflicts without writing")
+	owner := fs.String("owner", "", "owner for rows that do not name one")
+	batch := fs.Int("batch", 1000, "rows per ImportLinks call")
+	timeout := fs.Duration("timeout", time.Minute, "deadline for each ImportLinks call")
+	if err := fs.Parse(args); err != nil {
+		return flagExit(err)
+	}
+	if fs.NArg() != 1 || *batch < 1 {
+		fs.Usage()
+		return 2
+	}
+
+	in := os.Stdin
+	if name := fs.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return fail(err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	client, conn, err := dialAPI(*addr, *token)
+	if err != nil {
+		return fail(err)
+	}
+	defer conn.Close()
+
+	rows := newImportReader(in, *owner)
+	var imported, rejected int
+	for {
+		links, lines, done := rows.next(*batch)
+		if len(links) > 0 {
+			ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+			resp, err := client.ImportLinks(ctx, &pb.ImportLinksRequest{Links: links, DryRun: *dryRun})
+			cancel()
+			if err != nil {
+				return fail(fmt.Errorf("import failed at line %d: %w", lines[0], err))
+			}
+			for _, r := range resp.GetResults() {
+				if r.GetStatus() == pb.ImportStatus_IMPORT_STATUS_IMPORTED {
+					continue
+				}
+				fmt.Printf("line %d: %s\n", lines[r.GetIndex()], describeImportResult(r))
+			}
+			imported += int(resp.GetImported())
+			rejected += int(resp.GetInvalid() + resp.GetConflicts())
+		}
+		for _, e := range rows.errs {
+			fmt.Println(e)
+		}
+		rejected += len(rows.errs)
+		rows.errs = rows.errs[:0]
+		if done {
+			break
+		}
+	}
+
+	verb := "imported"
+	if *dryRun {
+		verb = "would import"
+	}
+	fmt.Printf("%s %d links, rejected %d\n", verb, imported, rejected)
+	if rejected > 0 {
+		return 1
+	}
+	return 0
+}
+
+func describeImportResult(r *pb.ImportLinkResult) string {
+	msg := fmt.Sprintf("code %q: %s", r.GetCode(), r.GetError())
+	if r.GetExistingUrl() != "" {
+		msg += fmt.Sprintf(" (existing URL %s)", r.GetExistingUrl())
+	}
+	return msg
+}
+
+// importReader turns CSV rows into ImportLinks, remembering the line number
+// of each and collecting rows that cannot be parsed.
+type importReader struct {
+	r       *csv.Reader
+	owner   string
+	columns map[string]int
+	started bool
+	errs    []string
+}
+
+func newImportReader(r io.Reader, owner string) *importReader {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	return &importReader{
+		r:       cr,
+		owner:   owner,
+		columns: map[string]int{"code": 0, "url": 1, "created_at": 2, "expires_at": 3, "owner": 4},
+	}
+}
+
+// next reads up to n links and reports whether the input is exhausted.
+func (ir *importReader) next(n int) (links []*pb.ImportLink, lines []int, done bool) {
+	for len(links) < n {
+		record, err := ir.r.Read()
+		if errors.Is(err, io.EOF) {
+			return links, lines, true
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				ir.errs = append(ir.errs, err.Error())
+				return links, lines, true
+			}
+			ir.errs = append(ir.errs, fmt.Sprintf("line %d: %v", parseErr.Line, parseErr.Err))
+			continue
+		}
+		line, _ := ir.r.FieldPos(0)
+		if !ir.started {
+			ir.started = true
+			if ir.readHeader(record) {
+				continue
+			}
+		}
+		link, err := ir.parse(record)
+		if err != nil {
+			ir.errs = append(ir.errs, fmt.Sprintf("line %d: %v", line, err))
+			continue
+		}
+		links = append(links, link)
+		lines = append(lines, line)
+	}
+	return links, lines, false
+}
+
+// readHeader maps columns by name if record is a header row.
+func (ir *importReader) readHeader(record []string) bool {
+	columns := make(map[string]int)
+	for i, name := range record {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["code"]; !ok {
+		return false
+	}
+	if _, ok := columns["url"]; !ok {
+		return false
+	}
+	ir.columns = columns
+	return true
+}
+
+func (ir *importReader) parse(record []string) (*pb.ImportLink, error) {
+	field := func(name string) string {
+		if i, ok := ir.columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	link := &pb.ImportLink{
+		Code:         field("code"),
+		Url:          field("url"),
+		Owner:        field("owner"),
+		PasswordHash: field("password_hash"),
+		FallbackUrl:  field("fallback_url"),
+	}
+	if link.Owner == "" {
+		link.Owner = ir.owner
+	}
+	for name, dst := range map[string]**timestamppb.Timestamp{
+		"created_at": &link.CreatedAt, "expires_at": &link.ExpiresAt,
+		"not_before": &link.NotBefore, "not_after": &link.NotAfter, "disabled_at": &link.DisabledAt,
+	} {
+		raw := field(name)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		*dst = timestamppb.New(t)
+	}
+	for name, dst := range map[string]*int32{"max_clicks": &link.MaxClicks, "clicks": &link.Clicks} {
+		raw := field(name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		*dst = int32(n)
+	}
+	if raw := field("rules"); raw != "" {
+		var rules []json.RawMessage
+		if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+			return nil, fmt.Errorf("rules: %w", err)
+		}
+		for _, r := range rules {
+			rule := &pb.RoutingRule{}
+			if err := protojson.Unmarshal(r, rule); err != nil {
+				return nil, fmt.Errorf("rules: %w", err)
+			}
+			link.Rules = append(link.Rules, rule)
+		}
+	}
+	return link, nil
+}
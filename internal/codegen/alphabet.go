@@ -0,0 +1,91 @@
+package codegen
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Named alphabets accepted by ParseAlphabet.
+const (
+	// Base58 drops 0, O, I and l, which are easily confused in print.
+	Base58 = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	// Base36 is digits and lowercase letters, for channels that change case
+	// such as SMS, voice or printed material.
+	Base36 = "0123456789abcdefghijklmnopqrstuvwxyz"
+)
+
+var namedAlphabets = map[string]string{
+	"base62": Base62,
+	"base58": Base58,
+	"base36": Base36,
+}
+
+const (
+	// minAlphabetSize keeps codes short enough for every ID to fit the alias
+	// length limit.
+	minAlphabetSize = 16
+	// minRandomBits is the smallest space random codes may draw from. At 2^40
+	// codes a table of 10 million links still collides on fewer than 1 in
+	// 100,000 attempts.
+	minRandomBits = 40
+	// MinLength keeps padded codes longer than every reserved alias.
+	MinLength = 8
+	// MaxLength is the longest code the alias rules accept.
+	MaxLength = 64
+)
+
+// ParseAlphabet returns the named alphabet (base62, base58 or base36), or
+// spec itself as a literal alphabet. Literal alphabets need at least 16
+// distinct ASCII letters and digits.
+func ParseAlphabet(spec string) (string, error) {
+	if a, ok := namedAlphabets[strings.ToLower(spec)]; ok {
+		return a, nil
+	}
+	if len(spec) < minAlphabetSize {
+		return "", fmt.Errorf("alphabet %q is neither base62, base58 nor base36, and shorter than %d characters", spec, minAlphabetSize)
+	}
+	seen := make(map[rune]bool, len(spec))
+	for _, r := range spec {
+		if !('0' <= r && r <= '9' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z') {
+			return "", fmt.Errorf("alphabet %q contains %q; only ASCII letters and digits are allowed", spec, r)
+		}
+		if seen[r] {
+			return "", fmt.Errorf("alphabet %q repeats %q", spec, r)
+		}
+		seen[r] = true
+	}
+	return spec, nil
+}
+
+// ValidateSpace checks that codes of length over alphabet can serve every
+// strategy: random codes must draw from at least 2^40 values. ID-based codes
+// need no check, since an alphabet of minAlphabetSize characters already
+// encodes the largest 64-bit ID in 16.
+func ValidateSpace(alphabet string, length int) error {
+	if length < MinLength || length > MaxLength {
+		return fmt.Errorf("code length %d is outside %d-%d", length, MinLength, MaxLength)
+	}
+	if len(alphabet) < minAlphabetSize {
+		return fmt.Errorf("alphabet has %d characters; want at least %d", len(alphabet), minAlphabetSize)
+	}
+	if bits := float64(length) * math.Log2(float64(len(alphabet))); bits < minRandomBits {
+		return fmt.Errorf("%d characters from a %d-character alphabet give 2^%.1f codes; want at least 2^%d, use a longer length",
+			length, len(alphabet), bits, minRandomBits)
+	}
+	return nil
+}
+
+// caseFolder returns the function mapping codes onto alphabet's single case,
+// or nil when alphabet mixes cases and codes are case-sensitive.
+func caseFolder(alphabet string) func(string) string {
+	hasLower := strings.ToUpper(alphabet) != alphabet
+	hasUpper := strings.ToLower(alphabet) != alphabet
+	switch {
+	case hasLower && !hasUpper:
+		return strings.ToLower
+	case hasUpper && !hasLower:
+		return strings.ToUpper
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+// Package codegen generates the short codes of new links. Each strategy
+// implements CodeGenerator; a Set holds one of each and the default used when
+// a request does not pick one.
+package codegen
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Base62 is the default alphabet of Sonyflake, random and hashids codes.
+const Base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// DefaultLength is the default length of random codes and minimum length of
+// Sonyflake and hashids codes.
+const DefaultLength = 8
+
+// Strategy names a CodeGenerator, as used in CODE_STRATEGY.
+type Strategy string
+
+const (
+	Sonyflake Strategy = "sonyflake"
+	Random    Strategy = "random"
+	Hashids   Strategy = "hashids"
+	Words     Strategy = "words"
+)
+
+// Strategies lists every strategy in documentation order.
+var Strategies = []Strategy{Sonyflake, Random, Hashids, Words}
+
+// CodeGenerator returns candidate codes for new links. Candidates can still
+// collide with existing codes; callers insert with a uniqueness check and ask
+// for another code on conflict. Implementations are safe for concurrent use.
+type CodeGenerator interface {
+	NextCode() (string, error)
+}
+
+// IDSource yields unique, increasing IDs. *sonyflake.Sonyflake implements it.
+type IDSource interface {
+	NextID() (uint64, error)
+}
+
+// Options configures the strategies of a Set.
+type Options struct {
+	// Default is the strategy used when a request does not name one.
+	Default Strategy
+	// Length is the length of random codes and the minimum length of
+	// Sonyflake and hashids codes.
+	Length int
+	// Alphabet is the characters of Sonyflake, random and hashids codes;
+	// empty means Base62. Word codes are always lowercase.
+	Alphabet string
+	// Salt keys the hashids alphabet shuffle. Changing it changes which codes
+	// new IDs map to, but never affects stored codes.
+	Salt string
+	// IDKey, when set, keys a Feistel permutation of Sonyflake IDs before
+	// they are encoded, so Sonyflake codes cannot be enumerated. Like Salt,
+	// changing it only affects new codes.
+	IDKey string
+}
+
+// Set holds a generator per strategy.
+type Set struct {
+	def        Strategy
+	generators map[Strategy]CodeGenerator
+	fold       func(string) string
+}
+
+// NewSet builds every strategy, drawing IDs from ids.
+func NewSet(ids IDSource, opts Options) (*Set, error) {
+	alphabet := opts.Alphabet
+	if alphabet == "" {
+		alphabet = Base62
+	}
+	if err := ValidateSpace(alphabet, opts.Length); err != nil {
+		return nil, err
+	}
+	sonyflake := SonyflakeGenerator{IDs: ids, Alphabet: alphabet, MinLength: opts.Length}
+	if opts.IDKey != "" {
+		sonyflake.Permutation = NewFeistel([]byte(opts.IDKey))
+	}
+	s := &Set{
+		def: opts.Default,
+		generators: map[Strategy]CodeGenerator{
+			Sonyflake: sonyflake,
+			Random:    RandomGenerator{Alphabet: alphabet, Length: opts.Length},
+			Hashids:   NewHashidsGenerator(ids, alphabet, opts.Salt, opts.Length),
+			Words:     WordGenerator{},
+		},
+		fold: caseFolder(alphabet),
+	}
+	if _, ok := s.generators[s.def]; !ok {
+		return nil, fmt.Errorf("unknown code strategy %q (want one of %s)", s.def, strategyList())
+	}
+	return s, nil
+}
+
+// Get returns the generator for strategy, or the default one when strategy
+// is empty.
+func (s *Set) Get(strategy Strategy) (CodeGenerator, error) {
+	if strategy == "" {
+		strategy = s.def
+	}
+	g, ok := s.generators[strategy]
+	if !ok {
+		return nil, fmt.Errorf("unknown code strategy %q", strategy)
+	}
+	return g, nil
+}
+
+// Default is the strategy used when a request does not name one.
+func (s *Set) Default() Strategy {
+	return s.def
+}
+
+// FoldCase maps code onto the case of a single-case alphabet, so a code
+// typed in the wrong case still resolves. ok is false when the alphabet
+// mixes cases, or code is already in the alphabet's case.
+func (s *Set) FoldCase(code string) (folded string, ok bool) {
+	if s == nil || s.fold == nil {
+		return code, false
+	}
+	folded = s.fold(code)
+	return folded, folded != code
+}
+
+// ParseStrategy validates a strategy name such as a CODE_STRATEGY value.
+func ParseStrategy(name string) (Strategy, error) {
+	for _, s := range Strategies {
+		if Strategy(strings.ToLower(name)) == s {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unknown code strategy %q (want one of %s)", name, strategyList())
+}
+
+func strategyList() string {
+	names := make([]string, len(Strategies))
+	for i, s := range Strategies {
+		names[i] = string(s)
+	}
+	return strings.Join(names, ", ")
+}
+
+// SonyflakeGenerator encodes each ID in Alphabet without dropping digits,
+// left-padding short encodings to MinLength. Codes are unique as long as the
+// IDs are. Without a Permutation consecutive codes share prefixes and are
+// easy to guess; with one they look random and are mostly 11 characters.
+type SonyflakeGenerator struct {
+	IDs         IDSource
+	Alphabet    string
+	MinLength   int
+	Permutation *Feistel
+}
+
+func (g SonyflakeGenerator) NextCode() (string, error) {
+	id, err := g.IDs.NextID()
+	if err != nil {
+		return "", fmt.Errorf("generate ID: %w", err)
+	}
+	if g.Permutation != nil {
+		id = g.Permutation.Permute(id)
+	}
+	return Encode(id, g.Alphabet, g.MinLength), nil
+}
+
+// RandomGenerator draws Length characters uniformly from Alphabet using
+// crypto/rand, giving len(Alphabet)^Length possible codes.
+type RandomGenerator struct {
+	Alphabet string
+	Length   int
+}
+
+func (g RandomGenerator) NextCode() (string, error) {
+	return randomString(g.Alphabet, g.Length)
+}
+
+// EncodeBase62 encodes num in Base62, left-padded with zeros to minLength.
+// Longer encodings are returned whole.
+func EncodeBase62(num uint64, minLength int) string {
+	return Encode(num, Base62, minLength)
+}
+
+// Encode writes num in the positional system whose digits are alphabet,
+// left-padded with the alphabet's first character to minLength.
+func Encode(num uint64, alphabet string, minLength int) string {
+	base := uint64(len(alphabet))
+	var buf [64]byte
+	i := len(buf)
+	for {
+		i--
+		buf[i] = alphabet[num%base]
+		num /= base
+		if num == 0 {
+			break
+		}
+	}
+	encoded := string(buf[i:])
+	if pad := minLength - len(encoded); pad > 0 {
+		encoded = strings.Repeat(alphabet[:1], pad) + encoded
+	}
+	return encoded
+}
+
+func randomString(alphabet string, length int) (string, error) {
+	max := big.NewInt(int64(len(alphabet)))
+	b := make([]byte, length)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("read random bytes: %w", err)
+		}
+		b[i] = alphabet[n.Int64()]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,57 @@
+package codegen
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// feistelRounds is enough rounds for every output bit to depend on every
+// input bit.
+const feistelRounds = 8
+
+// Feistel is a keyed permutation of uint64 built as a balanced Feistel
+// network over two 32-bit halves. Distinct IDs always map to distinct
+// values, so codes stay collision-free, while adjacent IDs map to unrelated
+// values. It hides the order of IDs from scrapers; it is not meant to resist
+// cryptanalysis.
+type Feistel struct {
+	keys [feistelRounds]uint64
+}
+
+// NewFeistel derives the round keys from key.
+func NewFeistel(key []byte) *Feistel {
+	f := &Feistel{}
+	for i := range f.keys {
+		sum := sha256.Sum256(append([]byte{byte(i)}, key...))
+		f.keys[i] = binary.BigEndian.Uint64(sum[:8])
+	}
+	return f
+}
+
+// Permute maps id to its obfuscated value.
+func (f *Feistel) Permute(id uint64) uint64 {
+	l, r := uint32(id>>32), uint32(id)
+	for _, k := range f.keys {
+		l, r = r, l^round(r, k)
+	}
+	return uint64(l)<<32 | uint64(r)
+}
+
+// Invert recovers the ID behind a value returned by Permute.
+func (f *Feistel) Invert(v uint64) uint64 {
+	l, r := uint32(v>>32), uint32(v)
+	for i := len(f.keys) - 1; i >= 0; i-- {
+		l, r = r^round(l, f.keys[i]), l
+	}
+	return uint64(l)<<32 | uint64(r)
+}
+
+// round is the Feistel round function: the splitmix64 finalizer over the
+// half block mixed with the round key.
+func round(half uint32, key uint64) uint32 {
+	z := uint64(half) ^ key
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+	z ^= z >> 31
+	return uint32(z >> 32)
+}
@@ -0,0 +1,61 @@
+package codegen
+
+import "fmt"
+
+// HashidsGenerator obfuscates IDs in the style of hashids: the alphabet is
+// shuffled by a salt, and again per ID by a "lottery" character that leads
+// the code, so consecutive IDs give unrelated-looking codes. Codes are unique
+// whenever the IDs are. Only the encoding is implemented; codes are looked up
+// in the database, never decoded.
+type HashidsGenerator struct {
+	ids       IDSource
+	salt      []byte
+	alphabet  []byte
+	minLength int
+}
+
+// NewHashidsGenerator shuffles alphabet by salt. An empty salt leaves the
+// per-ID shuffle as the only obfuscation.
+func NewHashidsGenerator(ids IDSource, alphabet, salt string, minLength int) *HashidsGenerator {
+	shuffled := []byte(alphabet)
+	shuffle(shuffled, []byte(salt))
+	return &HashidsGenerator{ids: ids, salt: []byte(salt), alphabet: shuffled, minLength: minLength}
+}
+
+func (g *HashidsGenerator) NextCode() (string, error) {
+	id, err := g.ids.NextID()
+	if err != nil {
+		return "", fmt.Errorf("generate ID: %w", err)
+	}
+	return g.Encode(id), nil
+}
+
+// Encode returns the code for id.
+func (g *HashidsGenerator) Encode(id uint64) string {
+	lottery := g.alphabet[id%100%uint64(len(g.alphabet))]
+
+	alphabet := append([]byte(nil), g.alphabet...)
+	key := make([]byte, 0, 1+len(g.salt)+len(alphabet))
+	key = append(append(append(key, lottery), g.salt...), alphabet...)
+	shuffle(alphabet, key[:len(alphabet)])
+
+	// Padding with the alphabet's zero digit keeps codes unique: a padded
+	// code never equals the unpadded code of a larger ID.
+	return string(lottery) + Encode(id, string(alphabet), g.minLength-1)
+}
+
+// shuffle permutes alphabet in place, deterministically for a given salt,
+// using the hashids "consistent shuffle".
+func shuffle(alphabet, salt []byte) {
+	if len(salt) == 0 {
+		return
+	}
+	for i, v, p := len(alphabet)-1, 0, 0; i > 0; i-- {
+		v %= len(salt)
+		n := int(salt[v])
+		p += n
+		j := (n + v + p) % i
+		alphabet[i], alphabet[j] = alphabet[j], alphabet[i]
+		v++
+	}
+}
@@ -0,0 +1,291 @@
+package codegen
+
+import (
+	"math/bits"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// counter is an IDSource yielding consecutive IDs from next.
+type counter struct{ next atomic.Uint64 }
+
+func (c *counter) NextID() (uint64, error) {
+	return c.next.Add(1) - 1, nil
+}
+
+func TestEncodeBase62(t *testing.T) {
+	pow := func(n int) uint64 {
+		v := uint64(1)
+		for range n {
+			v *= 62
+		}
+		return v
+	}
+	tests := []struct {
+		num  uint64
+		want string
+	}{
+		{0, "00000000"},
+		{1, "00000001"},
+		{61, "0000000z"},
+		{pow(7), "10000000"},
+		// Longer encodings keep every digit instead of being truncated.
+		{pow(9), "1000000000"},
+		{1<<64 - 1, "LygHa16AHYF"},
+	}
+	for _, tc := range tests {
+		if got := EncodeBase62(tc.num, 8); got != tc.want {
+			t.Errorf("EncodeBase62(%d, 8) = %q; want %q", tc.num, got, tc.want)
+		}
+	}
+}
+
+func TestSonyflakeCodesAreDistinct(t *testing.T) {
+	ids := &counter{}
+	// Start past 62^8 so the old truncation would have produced repeats.
+	ids.next.Store(218340105584896 - 5)
+	g := SonyflakeGenerator{IDs: ids, Alphabet: Base62, MinLength: 8}
+	seen := make(map[string]bool)
+	for range 10 {
+		code, err := g.NextCode()
+		if err != nil {
+			t.Fatalf("NextCode failed: %v", err)
+		}
+		if seen[code] {
+			t.Fatalf("duplicate code %q", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestRandomGenerator(t *testing.T) {
+	g := RandomGenerator{Alphabet: Base62, Length: 12}
+	valid := regexp.MustCompile(`^[0-9A-Za-z]{12}$`)
+	seen := make(map[string]bool)
+	for range 1000 {
+		code, err := g.NextCode()
+		if err != nil {
+			t.Fatalf("NextCode failed: %v", err)
+		}
+		if !valid.MatchString(code) {
+			t.Fatalf("code %q is not 12 Base62 characters", code)
+		}
+		if seen[code] {
+			t.Fatalf("duplicate code %q", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestHashidsGenerator(t *testing.T) {
+	g := NewHashidsGenerator(&counter{}, Base62, "pepper", 8)
+	valid := regexp.MustCompile(`^[0-9A-Za-z]{8,}$`)
+	seen := make(map[string]bool)
+	var prev string
+	for id := uint64(0); id < 20000; id++ {
+		code := g.Encode(id)
+		if !valid.MatchString(code) {
+			t.Fatalf("Encode(%d) = %q; want 8+ Base62 characters", id, code)
+		}
+		if seen[code] {
+			t.Fatalf("Encode(%d) = %q, already returned for a smaller ID", id, code)
+		}
+		seen[code] = true
+		if prev != "" && code[:4] == prev[:4] {
+			t.Errorf("Encode(%d) = %q shares a prefix with the previous code %q", id, code, prev)
+		}
+		prev = code
+	}
+
+	if other := NewHashidsGenerator(&counter{}, Base62, "salt", 8); other.Encode(12345) == g.Encode(12345) {
+		t.Error("codes do not depend on the salt")
+	}
+	if again := NewHashidsGenerator(&counter{}, Base62, "pepper", 8); again.Encode(12345) != g.Encode(12345) {
+		t.Error("codes differ between generators with the same salt")
+	}
+}
+
+func TestWordGenerator(t *testing.T) {
+	valid := regexp.MustCompile(`^[a-z]+-[a-z]+-[0-9]{4}$`)
+	for range 100 {
+		code, err := WordGenerator{}.NextCode()
+		if err != nil {
+			t.Fatalf("NextCode failed: %v", err)
+		}
+		if !valid.MatchString(code) {
+			t.Fatalf("code %q is not adjective-noun-NNNN", code)
+		}
+	}
+	for name, words := range map[string][]string{"adjectives": adjectives, "nouns": nouns} {
+		if len(words) != 128 {
+			t.Errorf("%d %s; want 128", len(words), name)
+		}
+		seen := make(map[string]bool)
+		for _, w := range words {
+			if seen[w] {
+				t.Errorf("%s lists %q twice", name, w)
+			}
+			seen[w] = true
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	set, err := NewSet(&counter{}, Options{Default: Random, Length: 8})
+	if err != nil {
+		t.Fatalf("NewSet failed: %v", err)
+	}
+	g, err := set.Get("")
+	if err != nil {
+		t.Fatalf("Get default failed: %v", err)
+	}
+	if _, ok := g.(RandomGenerator); !ok {
+		t.Errorf("default generator = %T; want RandomGenerator", g)
+	}
+	for _, s := range Strategies {
+		if _, err := set.Get(s); err != nil {
+			t.Errorf("Get(%q) failed: %v", s, err)
+		}
+	}
+	if _, err := set.Get("uuid"); err == nil {
+		t.Error("Get(uuid) succeeded; want an error")
+	}
+	if _, err := NewSet(&counter{}, Options{Default: "uuid", Length: 8}); err == nil || !strings.Contains(err.Error(), "sonyflake, random, hashids, words") {
+		t.Errorf("NewSet with unknown default = %v; want an error listing strategies", err)
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	if s, err := ParseStrategy("Words"); err != nil || s != Words {
+		t.Errorf("ParseStrategy(Words) = %q, %v; want words", s, err)
+	}
+	if _, err := ParseStrategy("base62"); err == nil {
+		t.Error("ParseStrategy(base62) succeeded; want an error")
+	}
+}
+
+func TestFeistel(t *testing.T) {
+	f := NewFeistel([]byte("key"))
+	seen := make(map[uint64]bool)
+	for _, base := range []uint64{0, 1 << 40, 1<<64 - 5000} {
+		for id := base; id < base+5000 && id >= base; id++ {
+			v := f.Permute(id)
+			if seen[v] {
+				t.Fatalf("Permute(%d) = %d, already returned for another ID", id, v)
+			}
+			seen[v] = true
+			if got := f.Invert(v); got != id {
+				t.Fatalf("Invert(Permute(%d)) = %d", id, got)
+			}
+		}
+	}
+
+	// Adjacent IDs should differ in about half their bits.
+	a, b := f.Permute(1<<40), f.Permute(1<<40+1)
+	if diff := bits.OnesCount64(a ^ b); diff < 16 {
+		t.Errorf("Permute of adjacent IDs differ in %d bits; want about 32", diff)
+	}
+	if NewFeistel([]byte("other")).Permute(1<<40) == a {
+		t.Error("permutation does not depend on the key")
+	}
+}
+
+func TestSonyflakeGeneratorWithPermutation(t *testing.T) {
+	ids := &counter{}
+	ids.next.Store(1 << 40)
+	g := SonyflakeGenerator{IDs: ids, Alphabet: Base62, MinLength: 8, Permutation: NewFeistel([]byte("key"))}
+	first, _ := g.NextCode()
+	second, _ := g.NextCode()
+	if first[:3] == second[:3] {
+		t.Errorf("consecutive codes %q and %q share a prefix", first, second)
+	}
+}
+
+func TestParseAlphabet(t *testing.T) {
+	tests := []struct {
+		spec, want string
+		ok         bool
+	}{
+		{"base62", Base62, true},
+		{"Base58", Base58, true},
+		{"base36", Base36, true},
+		{"0123456789abcdefgh", "0123456789abcdefgh", true},
+		{"0123456789abcdef", "0123456789abcdef", true},
+		{"0123456789abcde", "", false},   // too short
+		{"0123456789abcdeff", "", false}, // repeats f
+		{"0123456789abcdef-", "", false}, // not alphanumeric
+	}
+	for _, tt := range tests {
+		got, err := ParseAlphabet(tt.spec)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseAlphabet(%q) = %q, %v; want %q, ok=%v", tt.spec, got, err, tt.want, tt.ok)
+		}
+	}
+	for _, a := range []string{Base58, Base36} {
+		if _, err := ParseAlphabet(a); err != nil {
+			t.Errorf("ParseAlphabet(%q) failed: %v", a, err)
+		}
+	}
+	if strings.ContainsAny(Base58, "0OIl") || len(Base58) != 58 {
+		t.Errorf("Base58 = %q; want 58 characters without 0, O, I or l", Base58)
+	}
+}
+
+func TestValidateSpace(t *testing.T) {
+	tests := []struct {
+		alphabet string
+		length   int
+		ok       bool
+	}{
+		{Base62, 8, true},
+		{Base58, 8, true},
+		{Base36, 8, true},
+		{Base62, 7, false},  // could equal a reserved alias
+		{Base62, 65, false}, // longer than the alias rules allow
+		{"0123456789abcdef", 8, false},
+		{"0123456789abcdef", 10, true},
+	}
+	for _, tt := range tests {
+		if err := ValidateSpace(tt.alphabet, tt.length); (err == nil) != tt.ok {
+			t.Errorf("ValidateSpace(%d chars, %d) = %v; want ok=%v", len(tt.alphabet), tt.length, err, tt.ok)
+		}
+	}
+}
+
+func TestSetWithAlphabet(t *testing.T) {
+	set, err := NewSet(&counter{}, Options{Default: Random, Length: 10, Alphabet: Base36, IDKey: "key"})
+	if err != nil {
+		t.Fatalf("NewSet failed: %v", err)
+	}
+	valid := regexp.MustCompile(`^[0-9a-z]+$`)
+	for _, s := range []Strategy{Sonyflake, Random, Hashids} {
+		g, _ := set.Get(s)
+		code, err := g.NextCode()
+		if err != nil {
+			t.Fatalf("%s NextCode failed: %v", s, err)
+		}
+		if !valid.MatchString(code) || len(code) < 10 {
+			t.Errorf("%s code = %q; want 10+ Base36 characters", s, code)
+		}
+	}
+
+	if folded, ok := set.FoldCase("AbC123xyZ0"); !ok || folded != "abc123xyz0" {
+		t.Errorf("FoldCase(AbC123xyZ0) = %q, %v; want abc123xyz0, true", folded, ok)
+	}
+	if _, ok := set.FoldCase("abc123xyz0"); ok {
+		t.Error("FoldCase of a lowercase code reports a change")
+	}
+
+	mixed, err := NewSet(&counter{}, Options{Default: Random, Length: 8})
+	if err != nil {
+		t.Fatalf("NewSet failed: %v", err)
+	}
+	if _, ok := mixed.FoldCase("AbC12345"); ok {
+		t.Error("FoldCase folds codes of a mixed-case alphabet")
+	}
+	if _, err := NewSet(&counter{}, Options{Default: Random, Length: 6, Alphabet: Base36}); err == nil {
+		t.Error("NewSet with a 6-character Base36 space succeeded; want an error")
+	}
+}
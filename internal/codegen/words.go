@@ -0,0 +1,75 @@
+package codegen
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+// WordGenerator returns human-readable codes such as calm-otter-4821: a
+// random adjective, noun and four-digit number, about 164 million
+// combinations. They are easy to read aloud but longer than other codes.
+type WordGenerator struct{}
+
+func (WordGenerator) NextCode() (string, error) {
+	adjective, err := pick(adjectives)
+	if err != nil {
+		return "", err
+	}
+	noun, err := pick(nouns)
+	if err != nil {
+		return "", err
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(10000))
+	if err != nil {
+		return "", fmt.Errorf("read random bytes: %w", err)
+	}
+	return fmt.Sprintf("%s-%s-%04d", adjective, noun, n.Int64()), nil
+}
+
+func pick(words []string) (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(words))))
+	if err != nil {
+		return "", fmt.Errorf("read random bytes: %w", err)
+	}
+	return words[n.Int64()], nil
+}
+
+// Each list has 128 short, common and neutral words.
+var adjectives = []string{
+	"able", "amber", "ample", "azure", "bold", "brave", "breezy", "bright",
+	"brisk", "busy", "calm", "candid", "cheery", "chief", "civil", "clean",
+	"clear", "clever", "cosy", "crisp", "curly", "daring", "deft", "dry",
+	"eager", "early", "easy", "elated", "epic", "exact", "fair", "fancy",
+	"fast", "fine", "firm", "fluffy", "fond", "free", "fresh", "frosty",
+	"gentle", "giant", "glad", "golden", "grand", "green", "handy", "happy",
+	"hardy", "hazel", "honest", "humble", "icy", "ideal", "jolly", "jovial",
+	"just", "keen", "kind", "large", "lavish", "lively", "loyal", "lucky",
+	"lush", "magic", "merry", "mighty", "mild", "misty", "modest", "neat",
+	"nimble", "noble", "open", "pale", "patient", "plain", "polite", "proud",
+	"quick", "quiet", "rapid", "rare", "ready", "regal", "rich", "robust",
+	"rosy", "royal", "rustic", "safe", "sandy", "sharp", "shiny", "silver",
+	"simple", "sleek", "smart", "snowy", "soft", "solid", "spry", "steady",
+	"stout", "sunny", "super", "sweet", "swift", "tall", "tidy", "tiny",
+	"topaz", "trusty", "upbeat", "urban", "valid", "vast", "vivid", "warm",
+	"wavy", "wise", "witty", "woody", "young", "zany", "zesty", "zippy",
+}
+
+var nouns = []string{
+	"acorn", "anchor", "apple", "arrow", "badger", "banjo", "basil", "beacon",
+	"beaver", "birch", "bison", "bloom", "brook", "cactus", "camel", "canoe",
+	"canyon", "cedar", "cherry", "cloud", "clover", "comet", "coral", "cotton",
+	"crane", "creek", "daisy", "delta", "dingo", "dolphin", "dove", "dune",
+	"eagle", "ember", "falcon", "fern", "finch", "fjord", "flame", "forest",
+	"fox", "gecko", "glacier", "grape", "harbor", "hazel", "heron", "hill",
+	"iris", "island", "ivy", "jaguar", "jasper", "kayak", "kelp", "kiwi",
+	"koala", "lagoon", "lake", "lemon", "lily", "llama", "lotus", "lynx",
+	"maple", "marble", "meadow", "melon", "mesa", "moose", "nectar", "newt",
+	"oak", "ocean", "olive", "orbit", "orchid", "otter", "owl", "panda",
+	"parrot", "peach", "pebble", "pepper", "pine", "planet", "plum", "pony",
+	"quail", "quartz", "rabbit", "raven", "reef", "river", "robin", "rocket",
+	"saddle", "salmon", "sparrow", "spruce", "squid", "star", "stone", "sunset",
+	"swan", "thistle", "tiger", "tulip", "tundra", "turtle", "valley", "violet",
+	"walnut", "walrus", "whale", "willow", "wombat", "wren", "yak", "yarrow",
+	"zebra", "zephyr", "badge", "bridge", "candle", "harp", "lantern", "summit",
+}
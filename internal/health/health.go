@@ -0,0 +1,180 @@
+// Package health reports whether this instance is alive and whether its
+// dependencies are reachable, over HTTP for the load balancer and over the
+// standard grpc.health.v1 service for gRPC clients.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// ShortenerService is the fully-qualified gRPC service name whose overall
+// status tracks every registered dependency.
+const ShortenerService = "shortener.Shortener"
+
+// CheckFunc probes a single dependency. It must respect ctx cancellation.
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker runs dependency probes with a per-probe timeout. Readiness is
+// flipped to failing when the process starts draining so load balancers stop
+// routing new requests here before the listeners close.
+type Checker struct {
+	timeout  time.Duration
+	checks   []check
+	draining atomic.Bool
+	grpc     *health.Server
+}
+
+// NewChecker returns a Checker whose probes are each bounded by timeout.
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout, grpc: health.NewServer()}
+}
+
+// Add registers a dependency probe. The name doubles as a gRPC health
+// service name, e.g. "shortener.Shortener/postgres".
+func (c *Checker) Add(name string, fn CheckFunc) {
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+// GRPCServer returns the grpc.health.v1 implementation to register on the
+// gRPC server.
+func (c *Checker) GRPCServer() healthpb.HealthServer {
+	return c.grpc
+}
+
+// Fail marks the instance as no longer ready. It cannot be undone.
+func (c *Checker) Fail() {
+	c.draining.Store(true)
+	c.grpc.Shutdown()
+}
+
+// Ready reports whether the instance is accepting new traffic.
+func (c *Checker) Ready() bool {
+	return !c.draining.Load()
+}
+
+// statusOK is recorded in a Result for a dependency whose probe passed.
+const statusOK = "ok"
+
+// Result maps each dependency to "ok" or the error its probe returned.
+type Result map[string]string
+
+// OK reports whether every dependency passed.
+func (r Result) OK() bool {
+	for _, outcome := range r {
+		if outcome != statusOK {
+			return false
+		}
+	}
+	return true
+}
+
+// Probe runs all checks concurrently and returns their outcomes.
+func (c *Checker) Probe(ctx context.Context) Result {
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		result = make(Result, len(c.checks))
+	)
+	for _, chk := range c.checks {
+		wg.Add(1)
+		go func(chk check) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+
+			outcome := statusOK
+			if err := chk.fn(ctx); err != nil {
+				outcome = err.Error()
+			}
+			mu.Lock()
+			result[chk.name] = outcome
+			mu.Unlock()
+		}(chk)
+	}
+	wg.Wait()
+	return result
+}
+
+// Watch re-probes the dependencies every interval and publishes the results
+// to the gRPC health service until ctx is cancelled.
+func (c *Checker) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		c.publish(c.Probe(ctx))
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Checker) publish(result Result) {
+	for name, outcome := range result {
+		c.grpc.SetServingStatus(ShortenerService+"/"+name, servingStatus(outcome == statusOK))
+	}
+	c.grpc.SetServingStatus(ShortenerService, servingStatus(result.OK()))
+	c.grpc.SetServingStatus("", servingStatus(result.OK()))
+}
+
+func servingStatus(ok bool) healthpb.HealthCheckResponse_ServingStatus {
+	if ok {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
+
+type statusResponse struct {
+	Status string `json:"status"`
+	Checks Result `json:"checks,omitempty"`
+}
+
+// LivenessHandler serves /healthz. It only reports that the process is up and
+// able to serve HTTP; dependency outages must not get the task restarted.
+func (c *Checker) LivenessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeStatus(w, http.StatusOK, statusResponse{Status: statusOK})
+	}
+}
+
+// ReadinessHandler serves /readyz. It fails while draining or when any
+// dependency probe fails, listing each dependency's outcome.
+func (c *Checker) ReadinessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !c.Ready() {
+			writeStatus(w, http.StatusServiceUnavailable, statusResponse{Status: "draining"})
+			return
+		}
+
+		result := c.Probe(r.Context())
+		if !result.OK() {
+			writeStatus(w, http.StatusServiceUnavailable, statusResponse{Status: "unavailable", Checks: result})
+			return
+		}
+		writeStatus(w, http.StatusOK, statusResponse{Status: statusOK, Checks: result})
+	}
+}
+
+func writeStatus(w http.ResponseWriter, code int, body statusResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Error("failed to write health response", "status", code, "error", err)
+	}
+}
@@ -0,0 +1,77 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestReadinessHandler(t *testing.T) {
+	c := NewChecker(50 * time.Millisecond)
+	c.Add("postgres", func(ctx context.Context) error { return nil })
+	c.Add("redis", func(ctx context.Context) error { return nil })
+
+	rec := httptest.NewRecorder()
+	c.ReadinessHandler()(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("healthy /readyz = %d; want %d", rec.Code, http.StatusOK)
+	}
+
+	c.Fail()
+	rec = httptest.NewRecorder()
+	c.ReadinessHandler()(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("draining /readyz = %d; want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+
+	rec = httptest.NewRecorder()
+	c.LivenessHandler()(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("draining /healthz = %d; want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestProbeTimeout(t *testing.T) {
+	c := NewChecker(20 * time.Millisecond)
+	c.Add("postgres", func(ctx context.Context) error { return nil })
+	c.Add("redis", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	result := c.Probe(context.Background())
+	if result.OK() {
+		t.Fatalf("expected probe to fail, got %v", result)
+	}
+	if result["postgres"] != statusOK {
+		t.Errorf("postgres = %q; want %q", result["postgres"], statusOK)
+	}
+	if result["redis"] == statusOK {
+		t.Errorf("redis should have timed out")
+	}
+}
+
+func TestPublishPerDependency(t *testing.T) {
+	c := NewChecker(time.Second)
+	c.publish(Result{"postgres": statusOK, "redis": errors.New("connection refused").Error()})
+
+	tests := map[string]healthpb.HealthCheckResponse_ServingStatus{
+		ShortenerService + "/postgres": healthpb.HealthCheckResponse_SERVING,
+		ShortenerService + "/redis":    healthpb.HealthCheckResponse_NOT_SERVING,
+		ShortenerService:               healthpb.HealthCheckResponse_NOT_SERVING,
+	}
+	for service, want := range tests {
+		resp, err := c.GRPCServer().Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatalf("Check(%q) failed: %v", service, err)
+		}
+		if resp.GetStatus() != want {
+			t.Errorf("Check(%q) = %v; want %v", service, resp.GetStatus(), want)
+		}
+	}
+}
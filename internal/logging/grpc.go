@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var requestIDMetadataKey = strings.ToLower(RequestIDHeader)
+
+// UnaryServerInterceptor propagates the x-request-id metadata (generating one
+// if absent), echoes it in the response header and logs each call.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, id := withIncomingRequestID(ctx)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadataKey, id))
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logCall(ctx, info.FullMethod, err, time.Since(start))
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of
+// UnaryServerInterceptor.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, id := withIncomingRequestID(ss.Context())
+		_ = ss.SetHeader(metadata.Pairs(requestIDMetadataKey, id))
+		start := time.Now()
+		err := handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+		logCall(ctx, info.FullMethod, err, time.Since(start))
+		return err
+	}
+}
+
+func withIncomingRequestID(ctx context.Context) (context.Context, string) {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if vals := md.Get(requestIDMetadataKey); len(vals) > 0 {
+			id = vals[0]
+		}
+	}
+	if !ValidRequestID(id) {
+		id = NewRequestID()
+	}
+	return WithRequestID(ctx, id), id
+}
+
+func logCall(ctx context.Context, method string, err error, latency time.Duration) {
+	code := status.Code(err)
+	level := slog.LevelInfo
+	switch code {
+	case codes.Unknown, codes.Internal, codes.Unavailable, codes.DataLoss, codes.Unimplemented:
+		level = slog.LevelError
+	}
+	slog.Log(ctx, level, "grpc request",
+		KeyMethod, method,
+		KeyStatus, code.String(),
+		KeyLatency, latency,
+	)
+}
+
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,105 @@
+// Package logging configures the process-wide structured logger and carries
+// request IDs through contexts so every log line for a request can be joined
+// up, whether it arrived over HTTP or gRPC.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attribute keys shared by the HTTP and gRPC access logs and ShortenerService
+// so that queries work the same regardless of transport.
+const (
+	KeyRequestID = "request_id"
+	KeyTraceID   = "trace_id"
+	KeyCode      = "code"
+	KeyMethod    = "method"
+	KeyStatus    = "status"
+	KeyLatency   = "latency"
+)
+
+// RequestIDHeader is the HTTP header, and lower-cased the gRPC metadata key,
+// used to propagate request IDs between services.
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID returns a random 128-bit hex identifier.
+func NewRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("logging: crypto/rand failed: %v", err))
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// ValidRequestID accepts a caller-supplied ID only if it is short and
+// printable, so it can't be used to forge log lines.
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseLevel understands debug, info, warn and error (case-insensitive).
+func ParseLevel(raw string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(raw))); err != nil {
+		return 0, fmt.Errorf("invalid log level %q: %w", raw, err)
+	}
+	return level, nil
+}
+
+// New returns a JSON logger writing to w at the given level. Records logged
+// with a context carrying a request ID or an active span get request_id and
+// trace_id attributes.
+func New(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(contextHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})})
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String(KeyRequestID, id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String(KeyTraceID, sc.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,63 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewAddsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+
+	ctx := WithRequestID(context.Background(), "abc123")
+	logger.InfoContext(ctx, "link created", KeyCode, "0000abcd")
+	logger.DebugContext(ctx, "cache hit", KeyCode, "0000abcd")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 log line at info level, got %d:\n%s", len(lines), buf.String())
+	}
+	var record map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("log line is not JSON: %v", err)
+	}
+	if record[KeyRequestID] != "abc123" {
+		t.Errorf("request_id = %v; want %q", record[KeyRequestID], "abc123")
+	}
+	if record[KeyCode] != "0000abcd" {
+		t.Errorf("code = %v; want %q", record[KeyCode], "0000abcd")
+	}
+}
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"", false},
+		{"abc-123", true},
+		{NewRequestID(), true},
+		{"has space", false},
+		{"line\nbreak", false},
+		{strings.Repeat("a", 129), false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidRequestID(tt.input); got != tt.want {
+			t.Errorf("ValidRequestID(%q) = %v; want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	if level, err := ParseLevel("DEBUG"); err != nil || level != slog.LevelDebug {
+		t.Errorf("ParseLevel(DEBUG) = %v, %v; want debug", level, err)
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Errorf("ParseLevel(verbose) should fail")
+	}
+}
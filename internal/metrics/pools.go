@@ -0,0 +1,106 @@
+// Package metrics exposes connection pool statistics from the Postgres and
+// Redis clients as Prometheus collectors. The values are read from the pools
+// at scrape time, so nothing needs updating on the request path.
+package metrics
+
+import (
+	"github.com/JohnBPerkins/url-shortener/modules/db"
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "url_shortener"
+
+var (
+	pgAcquiredConns = prometheus.NewDesc(namespace+"_pgxpool_acquired_conns",
+		"Connections currently checked out of the Postgres pool.", nil, nil)
+	pgIdleConns = prometheus.NewDesc(namespace+"_pgxpool_idle_conns",
+		"Idle connections in the Postgres pool.", nil, nil)
+	pgTotalConns = prometheus.NewDesc(namespace+"_pgxpool_total_conns",
+		"Total connections in the Postgres pool, including those being constructed.", nil, nil)
+	pgMaxConns = prometheus.NewDesc(namespace+"_pgxpool_max_conns",
+		"Configured maximum size of the Postgres pool.", nil, nil)
+	pgAcquires = prometheus.NewDesc(namespace+"_pgxpool_acquires_total",
+		"Successful connection acquisitions from the Postgres pool.", nil, nil)
+	pgEmptyAcquires = prometheus.NewDesc(namespace+"_pgxpool_empty_acquires_total",
+		"Acquisitions that had to wait because the Postgres pool was empty.", nil, nil)
+	pgCanceledAcquires = prometheus.NewDesc(namespace+"_pgxpool_canceled_acquires_total",
+		"Acquisitions cancelled by their context while waiting on the Postgres pool.", nil, nil)
+	pgAcquireSeconds = prometheus.NewDesc(namespace+"_pgxpool_acquire_duration_seconds_total",
+		"Cumulative time spent acquiring connections from the Postgres pool.", nil, nil)
+)
+
+// PgxPoolCollector reports pgxpool.Stat for a pool.
+type PgxPoolCollector struct {
+	pool *db.Pool
+}
+
+func NewPgxPoolCollector(pool *db.Pool) *PgxPoolCollector {
+	return &PgxPoolCollector{pool: pool}
+}
+
+func (c *PgxPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- pgAcquiredConns
+	ch <- pgIdleConns
+	ch <- pgTotalConns
+	ch <- pgMaxConns
+	ch <- pgAcquires
+	ch <- pgEmptyAcquires
+	ch <- pgCanceledAcquires
+	ch <- pgAcquireSeconds
+}
+
+func (c *PgxPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(pgAcquiredConns, prometheus.GaugeValue, float64(s.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(pgIdleConns, prometheus.GaugeValue, float64(s.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(pgTotalConns, prometheus.GaugeValue, float64(s.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(pgMaxConns, prometheus.GaugeValue, float64(s.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(pgAcquires, prometheus.CounterValue, float64(s.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(pgEmptyAcquires, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(pgCanceledAcquires, prometheus.CounterValue, float64(s.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(pgAcquireSeconds, prometheus.CounterValue, s.AcquireDuration().Seconds())
+}
+
+var (
+	redisHits = prometheus.NewDesc(namespace+"_redis_pool_hits_total",
+		"Times a free connection was found in the Redis pool.", nil, nil)
+	redisMisses = prometheus.NewDesc(namespace+"_redis_pool_misses_total",
+		"Times no free connection was found in the Redis pool.", nil, nil)
+	redisTimeouts = prometheus.NewDesc(namespace+"_redis_pool_timeouts_total",
+		"Times waiting for a Redis pool connection timed out.", nil, nil)
+	redisTotalConns = prometheus.NewDesc(namespace+"_redis_pool_total_conns",
+		"Total connections in the Redis pool.", nil, nil)
+	redisIdleConns = prometheus.NewDesc(namespace+"_redis_pool_idle_conns",
+		"Idle connections in the Redis pool.", nil, nil)
+	redisStaleConns = prometheus.NewDesc(namespace+"_redis_pool_stale_conns_total",
+		"Stale connections removed from the Redis pool.", nil, nil)
+)
+
+// RedisPoolCollector reports redis.PoolStats for a client.
+type RedisPoolCollector struct {
+	client *redis.Client
+}
+
+func NewRedisPoolCollector(client *redis.Client) *RedisPoolCollector {
+	return &RedisPoolCollector{client: client}
+}
+
+func (c *RedisPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- redisHits
+	ch <- redisMisses
+	ch <- redisTimeouts
+	ch <- redisTotalConns
+	ch <- redisIdleConns
+	ch <- redisStaleConns
+}
+
+func (c *RedisPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.client.PoolStats()
+	ch <- prometheus.MustNewConstMetric(redisHits, prometheus.CounterValue, float64(s.Hits))
+	ch <- prometheus.MustNewConstMetric(redisMisses, prometheus.CounterValue, float64(s.Misses))
+	ch <- prometheus.MustNewConstMetric(redisTimeouts, prometheus.CounterValue, float64(s.Timeouts))
+	ch <- prometheus.MustNewConstMetric(redisTotalConns, prometheus.GaugeValue, float64(s.TotalConns))
+	ch <- prometheus.MustNewConstMetric(redisIdleConns, prometheus.GaugeValue, float64(s.IdleConns))
+	ch <- prometheus.MustNewConstMetric(redisStaleConns, prometheus.CounterValue, float64(s.StaleConns))
+}
@@ -0,0 +1,201 @@
+// Package routing picks where a link sends a visitor. A link's rules are
+// tried in order against the visitor's platform, preferred language and
+// country; the first that matches supplies the destination.
+package routing
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Platform is the operating system a User-Agent reports.
+type Platform string
+
+const (
+	Unknown Platform = ""
+	IOS     Platform = "ios"
+	Android Platform = "android"
+	Windows Platform = "windows"
+	MacOS   Platform = "macos"
+	Linux   Platform = "linux"
+)
+
+// Platforms lists every known platform in documentation order.
+var Platforms = []Platform{IOS, Android, Windows, MacOS, Linux}
+
+// ParsePlatform returns the platform in a User-Agent header. The checks run
+// from most to least specific: Android and iOS browsers also claim Linux and
+// Mac OS X.
+//
+// Safari on iPadOS 13 and later sends the same User-Agent as Safari on a
+// Mac, so those iPads are reported as MacOS. Only iPad apps' web views,
+// which add a "Mobile/" token to the Mac User-Agent, are recognised as IOS.
+func ParsePlatform(userAgent string) Platform {
+	switch {
+	case strings.Contains(userAgent, "Android"):
+		return Android
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"), strings.Contains(userAgent, "iPod"):
+		return IOS
+	case strings.Contains(userAgent, "Macintosh") && strings.Contains(userAgent, "Mobile/"):
+		return IOS
+	case strings.Contains(userAgent, "Windows"):
+		return Windows
+	case strings.Contains(userAgent, "Macintosh"), strings.Contains(userAgent, "Mac OS X"):
+		return MacOS
+	case strings.Contains(userAgent, "Linux"), strings.Contains(userAgent, "X11"):
+		return Linux
+	}
+	return Unknown
+}
+
+// PreferredLanguage returns the language tag an Accept-Language header
+// ranks highest, such as "pt-BR", or "" if it names none. Ties go to the
+// tag listed first, as browsers list them in order.
+func PreferredLanguage(acceptLanguage string) string {
+	type ranked struct {
+		tag string
+		q   float64
+	}
+	var tags []ranked
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" || !languageRegex.MatchString(tag) {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			tags = append(tags, ranked{tag, q})
+		}
+	}
+	if len(tags) == 0 {
+		return ""
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	return tags[0].tag
+}
+
+// Visitor is what rules are matched against. Empty fields are unknown and
+// match no rule that asks about them.
+type Visitor struct {
+	Platform Platform
+	// Language is a language tag, such as "en-GB".
+	Language string
+	// Country is an ISO 3166-1 alpha-2 code, such as "GB".
+	Country string
+}
+
+// Rule sends visitors it matches to URL. A rule matches when every
+// condition it sets matches; one that sets none matches everyone.
+type Rule struct {
+	Platforms []Platform `json:"platforms,omitempty"`
+	// Languages match a visitor's language exactly or as a prefix: "en"
+	// matches "en-GB", but "en-GB" does not match "en".
+	Languages []string `json:"languages,omitempty"`
+	Countries []string `json:"countries,omitempty"`
+	URL       string   `json:"url"`
+}
+
+// Matches reports whether r applies to v.
+func (r Rule) Matches(v Visitor) bool {
+	if len(r.Platforms) > 0 && !slices.Contains(r.Platforms, v.Platform) {
+		return false
+	}
+	if len(r.Languages) > 0 && !matchesLanguage(r.Languages, v.Language) {
+		return false
+	}
+	if len(r.Countries) > 0 && !containsFold(r.Countries, v.Country) {
+		return false
+	}
+	return true
+}
+
+// Select returns the index of the first rule that matches v.
+func Select(rules []Rule, v Visitor) (int, bool) {
+	for i, r := range rules {
+		if r.Matches(v) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+const (
+	// MaxRules bounds the rules of one link, which are tried on every
+	// resolve.
+	MaxRules = 20
+	// maxValues bounds the platforms, languages or countries of one rule.
+	maxValues = 50
+)
+
+var (
+	languageRegex = regexp.MustCompile(`^[A-Za-z]{1,8}(?:-[A-Za-z0-9]{1,8})*$`)
+	countryRegex  = regexp.MustCompile(`^[A-Za-z]{2}$`)
+)
+
+// Validate checks rules other than their URLs, which the caller validates
+// like any other link destination.
+func Validate(rules []Rule) error {
+	if len(rules) > MaxRules {
+		return fmt.Errorf("at most %d routing rules are allowed, got %d", MaxRules, len(rules))
+	}
+	for i, r := range rules {
+		if len(r.Platforms) > maxValues || len(r.Languages) > maxValues || len(r.Countries) > maxValues {
+			return fmt.Errorf("rule %d: at most %d values per condition are allowed", i+1, maxValues)
+		}
+		for _, p := range r.Platforms {
+			if !slices.Contains(Platforms, p) {
+				return fmt.Errorf("rule %d: unknown platform %q", i+1, p)
+			}
+		}
+		for _, l := range r.Languages {
+			if !languageRegex.MatchString(l) {
+				return fmt.Errorf("rule %d: invalid language tag %q", i+1, l)
+			}
+		}
+		for _, c := range r.Countries {
+			if !countryRegex.MatchString(c) {
+				return fmt.Errorf("rule %d: invalid country code %q (want ISO 3166-1 alpha-2)", i+1, c)
+			}
+		}
+	}
+	return nil
+}
+
+func containsFold(values []string, v string) bool {
+	if v == "" {
+		return false
+	}
+	for _, x := range values {
+		if strings.EqualFold(x, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesLanguage(tags []string, language string) bool {
+	if language == "" {
+		return false
+	}
+	for _, tag := range tags {
+		if strings.EqualFold(tag, language) {
+			return true
+		}
+		if len(language) > len(tag) && language[len(tag)] == '-' && strings.EqualFold(tag, language[:len(tag)]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package routing
+
+import "testing"
+
+func TestParsePlatform(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      Platform
+	}{
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1", IOS},
+		{"Mozilla/5.0 (iPad; CPU OS 12_5_7 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/12.1.2 Mobile/15E148 Safari/604.1", IOS},
+		{"Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/120.0 Mobile/15E148 Safari/604.1", IOS},
+		// An iPad app's web view on iPadOS 13+.
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148", IOS},
+		// Safari on iPadOS 13+ cannot be told apart from Safari on a Mac.
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15", MacOS},
+		{"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Mobile Safari/537.36", Android},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Safari/537.36", Windows},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_4) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15", MacOS},
+		{"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0", Linux},
+		{"curl/8.5.0", Unknown},
+		{"", Unknown},
+	}
+	for _, tt := range tests {
+		if got := ParsePlatform(tt.userAgent); got != tt.want {
+			t.Errorf("ParsePlatform(%q) = %q; want %q", tt.userAgent, got, tt.want)
+		}
+	}
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	tests := []struct {
+		header, want string
+	}{
+		{"en-GB,en;q=0.9", "en-GB"},
+		{"fr;q=0.5, de", "de"},
+		{"es, pt;q=1", "es"},
+		{"*;q=1, it;q=0.3", "it"},
+		{"en;q=0, nl;q=0.1", "nl"},
+		{"en;q=abc", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := PreferredLanguage(tt.header); got != tt.want {
+			t.Errorf("PreferredLanguage(%q) = %q; want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	rules := []Rule{
+		{Platforms: []Platform{IOS}, URL: "https://apps.apple.com/app"},
+		{Platforms: []Platform{Android}, URL: "https://play.google.com/app"},
+		{Languages: []string{"pt"}, Countries: []string{"br"}, URL: "https://example.com/br"},
+		{Languages: []string{"en-GB"}, URL: "https://example.co.uk"},
+	}
+	tests := []struct {
+		name   string
+		v      Visitor
+		want   int
+		wantOK bool
+	}{
+		{"ios", Visitor{Platform: IOS, Language: "pt-BR", Country: "BR"}, 0, true},
+		{"android", Visitor{Platform: Android}, 1, true},
+		{"language prefix and country", Visitor{Platform: Windows, Language: "pt-BR", Country: "BR"}, 2, true},
+		{"country alone", Visitor{Language: "en", Country: "BR"}, 0, false},
+		{"exact language", Visitor{Language: "en-gb"}, 3, true},
+		{"broader language", Visitor{Language: "en"}, 0, false},
+		{"nothing known", Visitor{}, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := Select(rules, tt.v)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("%s: Select = %d, %v; want %d, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+	if _, ok := Select([]Rule{{URL: "https://example.com"}}, Visitor{}); !ok {
+		t.Error("a rule without conditions did not match")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate([]Rule{{Platforms: []Platform{IOS}, Languages: []string{"zh-Hant-TW"}, Countries: []string{"tw"}}}); err != nil {
+		t.Errorf("Validate of a valid rule = %v", err)
+	}
+	invalid := [][]Rule{
+		{{Platforms: []Platform{"symbian"}}},
+		{{Languages: []string{"en_US"}}},
+		{{Countries: []string{"USA"}}},
+		make([]Rule, MaxRules+1),
+	}
+	for _, rules := range invalid {
+		if err := Validate(rules); err == nil {
+			t.Errorf("Validate(%v) succeeded", rules)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+
+	"github.com/JohnBPerkins/url-shortener/gen"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// adminMethods are the RPCs that read or write links in bulk. They are
+// never exposed on the public HTTP listener, and over gRPC they need the
+// admin token.
+var adminMethods = map[string]bool{
+	gen.Shortener_ExportLinks_FullMethodName: true,
+	gen.Shortener_ImportLinks_FullMethodName: true,
+}
+
+// AdminAuth guards the admin RPCs with a bearer token. It also implements
+// credentials.PerRPCCredentials, so a client connection dialed with it, such
+// as the admin listener's loopback connection, carries the token.
+type AdminAuth struct {
+	token string
+}
+
+// NewAdminAuth returns an AdminAuth for token. Without one it makes up a
+// random token, so only connections dialed with the AdminAuth itself can
+// call the admin RPCs.
+func NewAdminAuth(token string) (*AdminAuth, error) {
+	if token == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		token = base64.RawURLEncoding.EncodeToString(b)
+	}
+	return &AdminAuth{token: token}, nil
+}
+
+// UnaryServerInterceptor refuses unary admin RPCs without the token.
+func (a *AdminAuth) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := a.check(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor refuses streaming admin RPCs without the token.
+func (a *AdminAuth) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := a.check(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func (a *AdminAuth) check(ctx context.Context, method string) error {
+	if !adminMethods[method] {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		token, ok := strings.CutPrefix(v, "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1 {
+			return nil
+		}
+	}
+	return status.Errorf(codes.Unauthenticated, "%s requires the admin token", method)
+}
+
+// GetRequestMetadata implements credentials.PerRPCCredentials.
+func (a *AdminAuth) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + a.token}, nil
+}
+
+// RequireTransportSecurity implements credentials.PerRPCCredentials. The
+// token only travels over loopback or to operators' own connections.
+func (a *AdminAuth) RequireTransportSecurity() bool {
+	return false
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Alias rules for codes chosen by a caller rather than generated. Generated
+// codes always satisfy them.
+const (
+	minAliasLength = 3
+	maxAliasLength = 64
+)
+
+var aliasRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// reservedAliases are path segments the HTTP server routes itself, so a link
+// under one of them could never be reached.
+var reservedAliases = map[string]bool{
+	"api":     true,
+	"debug":   true,
+	"healthz": true,
+	"metrics": true,
+	"readyz":  true,
+}
+
+// validateAlias reports why code cannot be used as a custom code, or nil.
+func validateAlias(code string) error {
+	switch {
+	case len(code) < minAliasLength || len(code) > maxAliasLength:
+		return fmt.Errorf("code %q must be %d to %d characters long", code, minAliasLength, maxAliasLength)
+	case !aliasRegex.MatchString(code):
+		return fmt.Errorf("code %q may only contain letters, digits, '-' and '_'", code)
+	case reservedAliases[strings.ToLower(code)]:
+		return fmt.Errorf("code %q is reserved", code)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/JohnBPerkins/url-shortener/gen"
+	"github.com/JohnBPerkins/url-shortener/internal/telemetry"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxBatchSize bounds BatchShorten so one request cannot hold a connection
+// or the ID generator for long. A full batch of the longest URLs also has to
+// fit gRPC's default 4 MB message limit, which the gateway's loopback calls
+// are subject to.
+const maxBatchSize = 1000
+
+// BatchShorten inserts every valid URL with a single statement per attempt.
+// Rows whose code collides are skipped by ON CONFLICT and retried with fresh
+// IDs, so the rest of the batch is unaffected.
+func (s *ShortenerService) BatchShorten(ctx context.Context, req *gen.BatchShortenRequest) (*gen.BatchShortenResponse, error) {
+	urls := req.GetUrls()
+	if len(urls) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no URLs given")
+	}
+	if len(urls) > maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "batch of %d URLs exceeds the limit of %d", len(urls), maxBatchSize)
+	}
+	generator, err := s.generator(req.GetCodeStrategy())
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*gen.BatchShortenResult, len(urls))
+	var pending []int
+	for i, u := range urls {
+		results[i] = &gen.BatchShortenResult{Index: int32(i), Url: u}
+		if !isValidURL(u) {
+			results[i].Error = fmt.Sprintf("invalid URL: %q", u)
+			s.metrics.ShortenRequests.WithLabelValues(outcomeInvalidURL).Inc()
+			continue
+		}
+		pending = append(pending, i)
+	}
+
+	for attempt := 0; attempt < maxAttempts && len(pending) > 0; attempt++ {
+		batchCodes := make([]string, len(pending))
+		batchURLs := make([]string, len(pending))
+		for j, i := range pending {
+			code, err := generator.NextCode()
+			if err != nil {
+				s.metrics.ShortenRequests.WithLabelValues(outcomeIDError).Add(float64(len(pending)))
+				return nil, codeError(err)
+			}
+			batchCodes[j] = code
+			batchURLs[j] = urls[i]
+		}
+
+		inserted, err := s.insertBatch(ctx, batchCodes, batchURLs, req.GetOwner())
+		if err != nil {
+			s.metrics.ShortenRequests.WithLabelValues(outcomeDBError).Add(float64(len(pending)))
+			return nil, status.Errorf(codes.Internal, "db insert failed: %v", err)
+		}
+
+		var retry []int
+		for j, i := range pending {
+			if !inserted[batchCodes[j]] {
+				s.metrics.ShortenCollisions.Inc()
+				retry = append(retry, i)
+				continue
+			}
+			results[i].Code = batchCodes[j]
+			s.metrics.ShortenRequests.WithLabelValues(outcomeCreated).Inc()
+		}
+		if len(retry) > 0 {
+			slog.WarnContext(ctx, "code collisions in batch, retrying", "collisions", len(retry), "attempt", attempt+1)
+		}
+		pending = retry
+	}
+	for _, i := range pending {
+		results[i].Error = fmt.Sprintf("could not generate a unique code after %d attempts", maxAttempts)
+		s.metrics.ShortenRequests.WithLabelValues(outcomeExhausted).Inc()
+	}
+
+	var cached []cachedLink
+	for _, r := range results {
+		if r.GetCode() != "" {
+			cached = append(cached, cachedLink{code: r.GetCode(), url: r.GetUrl(), ttl: linkCacheTTL})
+		}
+	}
+	s.cacheLinks(ctx, cached)
+	slog.InfoContext(ctx, "batch shortened", "urls", len(urls), "created", len(urls)-countErrors(results))
+	return &gen.BatchShortenResponse{Results: results}, nil
+}
+
+// insertBatch inserts code/url pairs in one statement and returns the set of
+// codes that were actually inserted.
+func (s *ShortenerService) insertBatch(ctx context.Context, batchCodes, batchURLs []string, owner string) (map[string]bool, error) {
+	const stmt = `INSERT INTO links (code, url, owner, created_at)
+		SELECT code, url, NULLIF($3, ''), NOW() FROM unnest($1::text[], $2::text[]) AS t(code, url)
+		ON CONFLICT (code) DO NOTHING
+		RETURNING code`
+	dbCtx, span := telemetry.StartQuery(ctx, "INSERT", "links", stmt)
+	rows, err := s.dbPool.Query(dbCtx, stmt, batchCodes, batchURLs, owner)
+	if err != nil {
+		telemetry.EndQuery(span, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	inserted := make(map[string]bool, len(batchCodes))
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			telemetry.EndQuery(span, err)
+			return nil, err
+		}
+		inserted[code] = true
+	}
+	err = rows.Err()
+	telemetry.EndQuery(span, err)
+	return inserted, err
+}
+
+// cachedLink is a link to write to Redis for ttl.
+type cachedLink struct {
+	code, url string
+	ttl       time.Duration
+}
+
+// cacheLinks writes new links to Redis in a single pipeline. Failures are
+// logged only; Resolve falls back to Postgres.
+func (s *ShortenerService) cacheLinks(ctx context.Context, links []cachedLink) {
+	if len(links) == 0 {
+		return
+	}
+	pipe := s.cache.Pipeline()
+	for _, l := range links {
+		pipe.Set(ctx, l.code, l.url, l.ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.WarnContext(ctx, "redis pipeline SET failed", "links", len(links), "error", err)
+	}
+}
+
+func countErrors(results []*gen.BatchShortenResult) int {
+	n := 0
+	for _, r := range results {
+		if r.GetError() != "" {
+			n++
+		}
+	}
+	return n
+}
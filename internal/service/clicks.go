@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/JohnBPerkins/url-shortener/internal/logging"
+	"github.com/JohnBPerkins/url-shortener/internal/telemetry"
+	"github.com/go-redis/redis/v8"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// clicksTTL is how long an idle click counter stays in Redis. A counter that
+// expires is rebuilt from Postgres on the next click.
+const clicksTTL = 24 * time.Hour
+
+// ClicksKey is the Redis key counting down a click-limited link's remaining
+// clicks. Codes cannot contain ':', so it never clashes with a cached link.
+func ClicksKey(code string) string {
+	return "clicks:" + code
+}
+
+// Visit says what a Resolve is for. It travels in the context rather than
+// the request so that only in-process callers, which gRPC clients cannot
+// impersonate, can resolve without spending a click.
+type Visit int
+
+const (
+	// VisitLookup is a Resolve with no Visit, such as an API lookup. It
+	// spends a click, since the caller gets the URL.
+	VisitLookup Visit = iota
+	// VisitRedirect is a visitor the redirect handler sends to the URL.
+	VisitRedirect
+	// VisitProbe is a HEAD request or link preview bot. It only checks that
+	// a click is left, and gets no URL for click-limited links in return.
+	VisitProbe
+)
+
+type visitKey struct{}
+
+// WithVisit returns a copy of ctx marking Resolve calls as v.
+func WithVisit(ctx context.Context, v Visit) context.Context {
+	return context.WithValue(ctx, visitKey{}, v)
+}
+
+// VisitFrom returns the Visit carried by ctx, or VisitLookup.
+func VisitFrom(ctx context.Context) Visit {
+	v, _ := ctx.Value(visitKey{}).(Visit)
+	return v
+}
+
+// clicksExhausted is what takeClickScript returns once no clicks are left.
+const clicksExhausted = -1
+
+// takeClickScript spends one click atomically, so replicas racing for the
+// last click cannot both win. A missing counter is first set to ARGV[1], the
+// clicks Postgres says are left.
+var takeClickScript = redis.NewScript(`
+local left = redis.call("GET", KEYS[1])
+if not left then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	left = ARGV[1]
+end
+if tonumber(left) <= 0 then
+	return -1
+end
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+return redis.call("DECR", KEYS[1])
+`)
+
+// takeClick spends one of l's clicks or fails with NotFound once they are
+// used up. The Redis counter decides; Postgres is brought up to date before
+// the redirect so a lost counter is rebuilt without handing out extra clicks.
+func (s *ShortenerService) takeClick(ctx context.Context, l link) error {
+	remaining := l.maxClicks - l.clicks
+	if remaining < 0 {
+		remaining = 0
+	}
+	left, err := takeClickScript.Run(ctx, s.cache, []string{ClicksKey(l.code)}, remaining, clicksTTL.Milliseconds()).Int()
+	if err != nil {
+		s.metrics.ResolveErrors.Inc()
+		return status.Errorf(codes.Unavailable, "click counter failed: %v", err)
+	}
+	if left == clicksExhausted {
+		return s.clicksExhausted(l)
+	}
+
+	// GREATEST keeps the count from going backwards when reconciliations
+	// from several replicas arrive out of order.
+	const stmt = `UPDATE links SET clicks = GREATEST(clicks, max_clicks - $2) WHERE code = $1`
+	dbCtx, span := telemetry.StartQuery(ctx, "UPDATE", "links", stmt)
+	_, err = s.dbPool.Exec(dbCtx, stmt, l.code, left)
+	telemetry.EndQuery(span, err)
+	if err != nil {
+		// The click is already spent in Redis, which stays authoritative.
+		slog.WarnContext(ctx, "failed to reconcile clicks to Postgres", logging.KeyCode, l.code, "clicks_left", left, "error", err)
+	}
+	return nil
+}
+
+// checkClicks fails like takeClick once l's clicks are used up, but does not
+// spend one.
+func (s *ShortenerService) checkClicks(ctx context.Context, l link) error {
+	left, err := s.cache.Get(ctx, ClicksKey(l.code)).Int()
+	switch {
+	case err == redis.Nil:
+		left = int(l.maxClicks - l.clicks)
+	case err != nil:
+		s.metrics.ResolveErrors.Inc()
+		return status.Errorf(codes.Unavailable, "click counter failed: %v", err)
+	}
+	if left <= 0 {
+		return s.clicksExhausted(l)
+	}
+	return nil
+}
+
+func (s *ShortenerService) clicksExhausted(l link) error {
+	s.metrics.ResolveClickLimited.Inc()
+	return reasonError(status.Newf(codes.NotFound, "link %s has used all of its %d clicks", l.code, l.maxClicks),
+		ReasonClicksExhausted, nil)
+}
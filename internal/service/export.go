@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/JohnBPerkins/url-shortener/gen"
+	"github.com/JohnBPerkins/url-shortener/internal/routing"
+	"github.com/JohnBPerkins/url-shortener/internal/telemetry"
+	"github.com/jackc/pgx/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// exportFetchSize is how many rows ExportLinks pulls from the cursor at a
+// time, which bounds its memory use regardless of table size.
+const exportFetchSize = 1000
+
+var fetchLinksStmt = fmt.Sprintf(`FETCH FORWARD %d FROM export_links`, exportFetchSize)
+
+// ExportLinks streams matching links through a server-side cursor inside a
+// read-only transaction, so the export is a consistent snapshot. Links carry
+// every setting ImportLinks needs to recreate them, including the password
+// hash of protected links.
+func (s *ShortenerService) ExportLinks(req *gen.ExportLinksRequest, stream grpc.ServerStreamingServer[gen.Link]) error {
+	ctx := stream.Context()
+
+	var after, before *time.Time
+	if req.CreatedAfter != nil {
+		t := req.GetCreatedAfter().AsTime()
+		after = &t
+	}
+	if req.CreatedBefore != nil {
+		t := req.GetCreatedBefore().AsTime()
+		before = &t
+	}
+	if after != nil && before != nil && !after.Before(*before) {
+		return status.Error(codes.InvalidArgument, "created_after must be before created_before")
+	}
+
+	tx, err := s.dbPool.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly, IsoLevel: pgx.RepeatableRead})
+	if err != nil {
+		return status.Errorf(codes.Internal, "db begin failed: %v", err)
+	}
+	// Nothing is written, so rolling back just closes the cursor.
+	defer func() { _ = tx.Rollback(context.Background()) }()
+
+	const declare = `DECLARE export_links NO SCROLL CURSOR FOR
+		SELECT code, url, COALESCE(owner, ''), created_at, expires, COALESCE(password_hash, ''),
+			COALESCE(max_clicks, 0), clicks, not_before, not_after, COALESCE(fallback_url, ''), disabled_at, routing_rules
+		FROM links
+		WHERE ($1 = '' OR owner = $1)
+		  AND ($2::timestamptz IS NULL OR created_at >= $2)
+		  AND ($3::timestamptz IS NULL OR created_at < $3)
+		ORDER BY created_at, code`
+	dbCtx, span := telemetry.StartQuery(ctx, "DECLARE", "links", declare)
+	_, err = tx.Exec(dbCtx, declare, req.GetOwner(), after, before)
+	telemetry.EndQuery(span, err)
+	if err != nil {
+		return status.Errorf(codes.Internal, "db declare cursor failed: %v", err)
+	}
+
+	total := 0
+	for {
+		n, err := s.fetchLinks(ctx, tx, stream)
+		if err != nil {
+			return err
+		}
+		total += n
+		if n < exportFetchSize {
+			break
+		}
+	}
+	slog.InfoContext(ctx, "links exported", "links", total, "owner", req.GetOwner())
+	return nil
+}
+
+// fetchLinks sends the next page of the export_links cursor and returns how
+// many rows it contained.
+func (s *ShortenerService) fetchLinks(ctx context.Context, tx pgx.Tx, stream grpc.ServerStreamingServer[gen.Link]) (int, error) {
+	dbCtx, span := telemetry.StartQuery(ctx, "FETCH", "links", fetchLinksStmt)
+	rows, err := tx.Query(dbCtx, fetchLinksStmt)
+	if err != nil {
+		telemetry.EndQuery(span, err)
+		return 0, status.Errorf(codes.Internal, "db fetch failed: %v", err)
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		var (
+			link                                     gen.Link
+			createdAt                                time.Time
+			expires, notBefore, notAfter, disabledAt *time.Time
+			rules                                    []byte
+		)
+		if err := rows.Scan(&link.Code, &link.Url, &link.Owner, &createdAt, &expires, &link.PasswordHash,
+			&link.MaxClicks, &link.Clicks, &notBefore, &notAfter, &link.FallbackUrl, &disabledAt, &rules); err != nil {
+			telemetry.EndQuery(span, err)
+			return n, status.Errorf(codes.Internal, "db scan failed: %v", err)
+		}
+		link.CreatedAt = timestamppb.New(createdAt)
+		link.ExpiresAt = optionalTimestamp(expires)
+		link.NotBefore = optionalTimestamp(notBefore)
+		link.NotAfter = optionalTimestamp(notAfter)
+		link.DisabledAt = optionalTimestamp(disabledAt)
+		if rules != nil {
+			var stored []routing.Rule
+			if err := json.Unmarshal(rules, &stored); err != nil {
+				telemetry.EndQuery(span, err)
+				return n, status.Errorf(codes.Internal, "invalid routing rules for %s: %v", link.Code, err)
+			}
+			link.Rules = ruleMessages(stored)
+		}
+		if err := stream.Send(&link); err != nil {
+			telemetry.EndQuery(span, err)
+			return n, err
+		}
+		n++
+	}
+	err = rows.Err()
+	telemetry.EndQuery(span, err)
+	if err != nil {
+		return n, status.Errorf(codes.Internal, "db fetch failed: %v", err)
+	}
+	return n, nil
+}
+
+// optionalTimestamp converts a nullable column, leaving NULL unset.
+func optionalTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/JohnBPerkins/url-shortener/gen"
+	"github.com/JohnBPerkins/url-shortener/internal/telemetry"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ImportLinks stores links under codes chosen elsewhere. Codes must follow
+// the alias rules; codes that already exist are reported as conflicts and
+// left untouched, so re-running an import is safe.
+func (s *ShortenerService) ImportLinks(ctx context.Context, req *gen.ImportLinksRequest) (*gen.ImportLinksResponse, error) {
+	links := req.GetLinks()
+	if len(links) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no links given")
+	}
+	if len(links) > maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "batch of %d links exceeds the limit of %d", len(links), maxBatchSize)
+	}
+
+	now := time.Now()
+	results := make([]*gen.ImportLinkResult, len(links))
+	seen := make(map[string]bool, len(links))
+	var valid []int
+	for i, l := range links {
+		results[i] = &gen.ImportLinkResult{Index: int32(i), Code: l.GetCode()}
+		if err := validateImport(l, now); err != nil {
+			results[i].Status = gen.ImportStatus_IMPORT_STATUS_INVALID
+			results[i].Error = err.Error()
+			continue
+		}
+		if seen[l.GetCode()] {
+			results[i].Status = gen.ImportStatus_IMPORT_STATUS_CONFLICT
+			results[i].Error = "code appears earlier in the request"
+			continue
+		}
+		seen[l.GetCode()] = true
+		valid = append(valid, i)
+	}
+
+	existing, err := s.existingURLs(ctx, links, valid)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "db query failed: %v", err)
+	}
+	var pending []int
+	for _, i := range valid {
+		if url, ok := existing[links[i].GetCode()]; ok {
+			results[i].Status = gen.ImportStatus_IMPORT_STATUS_CONFLICT
+			results[i].Error = "code already exists"
+			results[i].ExistingUrl = url
+			continue
+		}
+		pending = append(pending, i)
+	}
+
+	inserted := make(map[string]bool, len(pending))
+	if req.GetDryRun() {
+		for _, i := range pending {
+			inserted[links[i].GetCode()] = true
+		}
+	} else if len(pending) > 0 {
+		if inserted, err = s.insertImported(ctx, links, pending, now); err != nil {
+			return nil, status.Errorf(codes.Internal, "db insert failed: %v", err)
+		}
+	}
+
+	var cached []cachedLink
+	for _, i := range pending {
+		if !inserted[links[i].GetCode()] {
+			// Lost a race with a concurrent insert of the same code.
+			results[i].Status = gen.ImportStatus_IMPORT_STATUS_CONFLICT
+			results[i].Error = "code already exists"
+			continue
+		}
+		results[i].Status = gen.ImportStatus_IMPORT_STATUS_IMPORTED
+		if ttl := importedLink(links[i], now).cacheTTL(now); ttl > 0 && !req.GetDryRun() {
+			cached = append(cached, cachedLink{code: links[i].GetCode(), url: links[i].GetUrl(), ttl: ttl})
+		}
+	}
+	s.cacheLinks(ctx, cached)
+
+	resp := &gen.ImportLinksResponse{Results: results}
+	for _, r := range results {
+		switch r.GetStatus() {
+		case gen.ImportStatus_IMPORT_STATUS_IMPORTED:
+			resp.Imported++
+		case gen.ImportStatus_IMPORT_STATUS_INVALID:
+			resp.Invalid++
+		case gen.ImportStatus_IMPORT_STATUS_CONFLICT:
+			resp.Conflicts++
+		}
+	}
+	if !req.GetDryRun() {
+		s.metrics.ImportedLinks.WithLabelValues("imported").Add(float64(resp.Imported))
+		s.metrics.ImportedLinks.WithLabelValues("invalid").Add(float64(resp.Invalid))
+		s.metrics.ImportedLinks.WithLabelValues("conflict").Add(float64(resp.Conflicts))
+	}
+	slog.InfoContext(ctx, "links imported", "dry_run", req.GetDryRun(),
+		"imported", resp.Imported, "invalid", resp.Invalid, "conflicts", resp.Conflicts)
+	return resp, nil
+}
+
+// validateImport checks one link against the alias and URL rules and the
+// same limits Shorten applies to its settings. Windows that have already
+// ended are allowed, so a restored backup keeps its ended links.
+func validateImport(l *gen.ImportLink, now time.Time) error {
+	if err := validateAlias(l.GetCode()); err != nil {
+		return err
+	}
+	if !isValidURL(l.GetUrl()) {
+		return fmt.Errorf("invalid URL: %q", l.GetUrl())
+	}
+	created := now
+	if l.CreatedAt != nil {
+		created = l.GetCreatedAt().AsTime()
+		if created.After(now) {
+			return errors.New("created_at is in the future")
+		}
+	}
+	if l.ExpiresAt != nil && !l.GetExpiresAt().AsTime().After(created) {
+		return errors.New("expires_at must be after created_at")
+	}
+	if hash := l.GetPasswordHash(); hash != "" {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return errors.New("password_hash is not a bcrypt hash")
+		}
+	}
+	if l.GetMaxClicks() < 0 || l.GetClicks() < 0 {
+		return errors.New("max_clicks and clicks must not be negative")
+	}
+	if l.NotBefore != nil && l.NotAfter != nil && !l.GetNotAfter().AsTime().After(l.GetNotBefore().AsTime()) {
+		return errors.New("not_after must be after not_before")
+	}
+	if l.GetFallbackUrl() != "" && !isValidURL(l.GetFallbackUrl()) {
+		return fmt.Errorf("invalid fallback URL: %q", l.GetFallbackUrl())
+	}
+	if _, _, err := parseRules(l.GetRules()); err != nil {
+		return errors.New(status.Convert(err).Message())
+	}
+	return nil
+}
+
+// importedLink is l as Resolve will read it back once stored.
+func importedLink(l *gen.ImportLink, now time.Time) link {
+	stored := link{
+		code:         l.GetCode(),
+		url:          l.GetUrl(),
+		passwordHash: l.GetPasswordHash(),
+		maxClicks:    l.GetMaxClicks(),
+		clicks:       l.GetClicks(),
+		disabled:     l.DisabledAt != nil,
+		fallbackURL:  l.GetFallbackUrl(),
+	}
+	if l.NotBefore != nil {
+		stored.notBefore = l.GetNotBefore().AsTime()
+	}
+	if l.NotAfter != nil {
+		stored.notAfter = l.GetNotAfter().AsTime()
+	}
+	stored.expires = expiry(stored.notBefore, stored.notAfter, stored.maxClicks, now)
+	if l.ExpiresAt != nil {
+		stored.expires = l.GetExpiresAt().AsTime()
+	}
+	stored.rules, _, _ = parseRules(l.GetRules())
+	return stored
+}
+
+// existingURLs returns the stored URL of every code at the given indexes that
+// is already taken.
+func (s *ShortenerService) existingURLs(ctx context.Context, links []*gen.ImportLink, indexes []int) (map[string]string, error) {
+	existing := make(map[string]string)
+	if len(indexes) == 0 {
+		return existing, nil
+	}
+	codeList := make([]string, len(indexes))
+	for j, i := range indexes {
+		codeList[j] = links[i].GetCode()
+	}
+
+	const stmt = `SELECT code, url FROM links WHERE code = ANY($1)`
+	dbCtx, span := telemetry.StartQuery(ctx, "SELECT", "links", stmt)
+	rows, err := s.dbPool.Query(dbCtx, stmt, codeList)
+	if err != nil {
+		telemetry.EndQuery(span, err)
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var code, url string
+		if err := rows.Scan(&code, &url); err != nil {
+			telemetry.EndQuery(span, err)
+			return nil, err
+		}
+		existing[code] = url
+	}
+	err = rows.Err()
+	telemetry.EndQuery(span, err)
+	return existing, err
+}
+
+// insertImported inserts the links at the given indexes in one statement and
+// returns the set of codes that were actually inserted. A missing created_at
+// falls back to the table's default, and a missing expires_at to what
+// Shorten would store for the link at now.
+func (s *ShortenerService) insertImported(ctx context.Context, links []*gen.ImportLink, indexes []int, now time.Time) (map[string]bool, error) {
+	var (
+		codeList, urls, owners, hashes, fallbacks, rules []string
+		maxClicks, clicks                                []int32
+		created, expires, notBefore, notAfter, disabled  []*time.Time
+	)
+	for _, i := range indexes {
+		l := links[i]
+		codeList = append(codeList, l.GetCode())
+		urls = append(urls, l.GetUrl())
+		owners = append(owners, l.GetOwner())
+		created = append(created, optionalTime(l.CreatedAt != nil, l.GetCreatedAt().AsTime()))
+		expires = append(expires, nullTime(importedLink(l, now).expires))
+		hashes = append(hashes, l.GetPasswordHash())
+		maxClicks = append(maxClicks, l.GetMaxClicks())
+		clicks = append(clicks, l.GetClicks())
+		notBefore = append(notBefore, optionalTime(l.NotBefore != nil, l.GetNotBefore().AsTime()))
+		notAfter = append(notAfter, optionalTime(l.NotAfter != nil, l.GetNotAfter().AsTime()))
+		fallbacks = append(fallbacks, l.GetFallbackUrl())
+		disabled = append(disabled, optionalTime(l.DisabledAt != nil, l.GetDisabledAt().AsTime()))
+		// validateImport already checked the rules.
+		_, stored, _ := parseRules(l.GetRules())
+		rules = append(rules, string(stored))
+	}
+
+	const stmt = `INSERT INTO links (code, url, owner, created_at, expires, password_hash, max_clicks, clicks,
+			not_before, not_after, fallback_url, disabled_at, routing_rules)
+		SELECT code, url, NULLIF(owner, ''), COALESCE(created_at, NOW()), expires,
+			NULLIF(password_hash, ''), NULLIF(max_clicks, 0), clicks, not_before, not_after, NULLIF(fallback_url, ''),
+			disabled_at, NULLIF(routing_rules, '')::jsonb
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::timestamptz[], $5::timestamptz[], $6::text[], $7::int[],
+				$8::int[], $9::timestamptz[], $10::timestamptz[], $11::text[], $12::timestamptz[], $13::text[])
+			AS t(code, url, owner, created_at, expires, password_hash, max_clicks, clicks,
+				not_before, not_after, fallback_url, disabled_at, routing_rules)
+		ON CONFLICT (code) DO NOTHING
+		RETURNING code`
+	dbCtx, span := telemetry.StartQuery(ctx, "INSERT", "links", stmt)
+	rows, err := s.dbPool.Query(dbCtx, stmt, codeList, urls, owners, created, expires, hashes, maxClicks, clicks,
+		notBefore, notAfter, fallbacks, disabled, rules)
+	if err != nil {
+		telemetry.EndQuery(span, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	inserted := make(map[string]bool, len(indexes))
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			telemetry.EndQuery(span, err)
+			return nil, err
+		}
+		inserted[code] = true
+	}
+	err = rows.Err()
+	telemetry.EndQuery(span, err)
+	return inserted, err
+}
+
+func optionalTime(set bool, t time.Time) *time.Time {
+	if !set {
+		return nil
+	}
+	return &t
+}
@@ -5,14 +5,27 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/JohnBPerkins/url-shortener/gen"
+	"github.com/JohnBPerkins/url-shortener/internal/codegen"
 	"github.com/JohnBPerkins/url-shortener/modules/flake"
 	"github.com/go-redis/redis/v8"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 var (
@@ -21,6 +34,9 @@ var (
     testURL   = "example.com/foo"
 )
 
+// minCodeLength is the code length the generators are configured with.
+const minCodeLength = 8
+
 func TestMain(m *testing.M) {
     dsn := os.Getenv("DATABASE_DSN")
     if dsn == "" {
@@ -42,9 +58,32 @@ func TestMain(m *testing.M) {
       CREATE TABLE IF NOT EXISTS links (
         code TEXT PRIMARY KEY,
         url TEXT      NOT NULL,
-        created_at TIMESTAMPTZ NOT NULL
+        owner TEXT,
+        created_at TIMESTAMPTZ NOT NULL,
+        expires TIMESTAMPTZ DEFAULT now() + INTERVAL '24 hours',
+        password_hash TEXT,
+        max_clicks INTEGER,
+        clicks INTEGER NOT NULL DEFAULT 0,
+        not_before TIMESTAMPTZ,
+        not_after TIMESTAMPTZ,
+        fallback_url TEXT,
+        disabled_at TIMESTAMPTZ,
+        routing_rules JSONB
       );
+      ALTER TABLE links ALTER COLUMN expires DROP NOT NULL;
       TRUNCATE TABLE links;
+      CREATE TABLE IF NOT EXISTS owner_fallbacks (
+        owner TEXT PRIMARY KEY,
+        url TEXT NOT NULL,
+        updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+      );
+      TRUNCATE TABLE owner_fallbacks;
+      CREATE TABLE IF NOT EXISTS sonyflake_machine_ids (
+        machine_id INTEGER PRIMARY KEY,
+        holder TEXT NOT NULL,
+        expires_at TIMESTAMPTZ NOT NULL
+      );
+      TRUNCATE TABLE sonyflake_machine_ids;
     `)
     if err != nil {
         fmt.Fprintf(os.Stderr, "failed to prepare DB: %v\n", err)
@@ -61,8 +100,12 @@ func TestMain(m *testing.M) {
         os.Exit(1)
     }
 
-    sf := flake.NewSonyflake()
-    svc = NewShortenerService(pgPool, rdb, sf)
+    codeSet, err := codegen.NewSet(flake.NewSonyflake(), codegen.Options{Default: codegen.Sonyflake, Length: minCodeLength})
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "failed to set up code generators: %v\n", err)
+        os.Exit(1)
+    }
+    svc = NewShortenerService(pgPool, rdb, codeSet, prometheus.NewRegistry(), Options{})
     code := m.Run()
 
     os.Exit(code)
@@ -73,8 +116,8 @@ func TestIntegration_ShrinkThenResolve(t *testing.T) {
     if err != nil {
         t.Fatalf("Shorten failed: %v", err)
     }
-    if len(resp.Code) != codeLength {
-        t.Errorf("expected code length %d, got %d", codeLength, len(resp.Code))
+    if len(resp.Code) < minCodeLength {
+        t.Errorf("expected code length of at least %d, got %d", minCodeLength, len(resp.Code))
     }
 
     res2, err := svc.Resolve(ctx, &gen.ResolveRequest{Code: resp.Code})
@@ -107,3 +150,591 @@ func TestIntegration_CacheHit(t *testing.T) {
         t.Errorf("cache[%s]=%q; want %q", code, cached, testURL)
     }
 }
+
+func TestIntegration_ResolveMetrics(t *testing.T) {
+    resp, err := svc.Shorten(ctx, &gen.ShortenRequest{Url: testURL})
+    if err != nil {
+        t.Fatalf("Shorten failed: %v", err)
+    }
+
+    m := svc.(*ShortenerService).metrics
+    hitsBefore := testutil.ToFloat64(m.ResolveHits)
+    createdBefore := testutil.ToFloat64(m.ShortenRequests.WithLabelValues(outcomeCreated))
+
+    if _, err := svc.Resolve(ctx, &gen.ResolveRequest{Code: resp.Code}); err != nil {
+        t.Fatalf("Resolve failed: %v", err)
+    }
+    if _, err := svc.Shorten(ctx, &gen.ShortenRequest{Url: testURL}); err != nil {
+        t.Fatalf("Shorten failed: %v", err)
+    }
+
+    if got := testutil.ToFloat64(m.ResolveHits) - hitsBefore; got != 1 {
+        t.Errorf("resolve cache hits increased by %v; want 1", got)
+    }
+    if got := testutil.ToFloat64(m.ShortenRequests.WithLabelValues(outcomeCreated)) - createdBefore; got != 1 {
+        t.Errorf("created shorten requests increased by %v; want 1", got)
+    }
+}
+
+func TestIntegration_BatchShorten(t *testing.T) {
+    urls := []string{testURL, "not a url", "example.org/bar"}
+    resp, err := svc.BatchShorten(ctx, &gen.BatchShortenRequest{Urls: urls})
+    if err != nil {
+        t.Fatalf("BatchShorten failed: %v", err)
+    }
+    if len(resp.Results) != len(urls) {
+        t.Fatalf("got %d results; want %d", len(resp.Results), len(urls))
+    }
+    if resp.Results[1].Error == "" || resp.Results[1].Code != "" {
+        t.Errorf("invalid URL result = %+v; want an error and no code", resp.Results[1])
+    }
+
+    rdb := svc.(*ShortenerService).cache
+    for _, i := range []int{0, 2} {
+        r := resp.Results[i]
+        if r.Error != "" || len(r.Code) < minCodeLength {
+            t.Fatalf("result %d = %+v; want a code", i, r)
+        }
+        if cached, err := rdb.Get(ctx, r.Code).Result(); err != nil || cached != urls[i] {
+            t.Errorf("cache[%s] = %q, %v; want %q", r.Code, cached, err, urls[i])
+        }
+        res, err := svc.Resolve(ctx, &gen.ResolveRequest{Code: r.Code})
+        if err != nil || res.Url != urls[i] {
+            t.Errorf("Resolve(%s) = %v, %v; want %q", r.Code, res, err, urls[i])
+        }
+    }
+}
+
+// exportStream collects what ExportLinks sends.
+type exportStream struct {
+    grpc.ServerStream
+    links []*gen.Link
+}
+
+func (s *exportStream) Context() context.Context { return ctx }
+
+func (s *exportStream) Send(l *gen.Link) error {
+    s.links = append(s.links, l)
+    return nil
+}
+
+func TestIntegration_ExportLinks(t *testing.T) {
+    owner := fmt.Sprintf("export-%d", time.Now().UnixNano())
+    start := time.Now().Add(-time.Second)
+    resp, err := svc.BatchShorten(ctx, &gen.BatchShortenRequest{Urls: []string{testURL, "example.org/bar"}, Owner: owner})
+    if err != nil {
+        t.Fatalf("BatchShorten failed: %v", err)
+    }
+
+    stream := &exportStream{}
+    req := &gen.ExportLinksRequest{Owner: owner, CreatedAfter: timestamppb.New(start)}
+    if err := svc.ExportLinks(req, stream); err != nil {
+        t.Fatalf("ExportLinks failed: %v", err)
+    }
+    if len(stream.links) != len(resp.Results) {
+        t.Fatalf("exported %d links; want %d", len(stream.links), len(resp.Results))
+    }
+    for _, l := range stream.links {
+        if l.Owner != owner || l.CreatedAt.AsTime().Before(start) {
+            t.Errorf("exported link %+v does not match the filters", l)
+        }
+    }
+
+    stream = &exportStream{}
+    req = &gen.ExportLinksRequest{Owner: owner, CreatedBefore: timestamppb.New(start)}
+    if err := svc.ExportLinks(req, stream); err != nil {
+        t.Fatalf("ExportLinks failed: %v", err)
+    }
+    if len(stream.links) != 0 {
+        t.Errorf("exported %d links created before the batch; want 0", len(stream.links))
+    }
+
+    // A link with every setting survives an export and an import under a
+    // new code. Link and ImportLink share field numbers, so an exported
+    // link converts as is.
+    full, err := svc.Shorten(ctx, &gen.ShortenRequest{
+        Url: "example.org/secret", Owner: owner, Password: "hunter2", MaxClicks: 3,
+        NotBefore:   timestamppb.New(time.Now().Add(-time.Hour).Truncate(time.Second)),
+        NotAfter:    timestamppb.New(time.Now().Add(time.Hour).Truncate(time.Second)),
+        FallbackUrl: "example.org/gone",
+        Rules:       []*gen.RoutingRule{{Platforms: []gen.Platform{gen.Platform_PLATFORM_IOS}, Url: "example.org/ios"}},
+    })
+    if err != nil {
+        t.Fatalf("Shorten failed: %v", err)
+    }
+    pool := svc.(*ShortenerService).dbPool
+    if _, err := pool.Exec(ctx, `UPDATE links SET clicks = 1, disabled_at = now() WHERE code = $1`, full.Code); err != nil {
+        t.Fatalf("disable %s: %v", full.Code, err)
+    }
+    exported := exportedLink(t, owner, full.Code)
+    if exported.PasswordHash == "" {
+        t.Fatalf("exported protected link %+v without its password hash", exported)
+    }
+
+    b, err := proto.Marshal(exported)
+    if err != nil {
+        t.Fatalf("marshal %+v: %v", exported, err)
+    }
+    imp := &gen.ImportLink{}
+    if err := proto.Unmarshal(b, imp); err != nil {
+        t.Fatalf("unmarshal ImportLink: %v", err)
+    }
+    imp.Code = fmt.Sprintf("rt-%d", time.Now().UnixNano())
+    resp2, err := svc.ImportLinks(ctx, &gen.ImportLinksRequest{Links: []*gen.ImportLink{imp}})
+    if err != nil || resp2.Imported != 1 {
+        t.Fatalf("ImportLinks = %+v, %v; want 1 imported", resp2, err)
+    }
+    reimported := exportedLink(t, owner, imp.Code)
+    reimported.Code = exported.Code
+    if !proto.Equal(reimported, exported) {
+        t.Errorf("re-imported link = %+v; want %+v", reimported, exported)
+    }
+}
+
+// exportedLink returns the link ExportLinks sends for code.
+func exportedLink(t *testing.T, owner, code string) *gen.Link {
+    t.Helper()
+    stream := &exportStream{}
+    if err := svc.ExportLinks(&gen.ExportLinksRequest{Owner: owner}, stream); err != nil {
+        t.Fatalf("ExportLinks failed: %v", err)
+    }
+    for _, l := range stream.links {
+        if l.Code == code {
+            return l
+        }
+    }
+    t.Fatalf("ExportLinks did not send %s", code)
+    return nil
+}
+
+func TestIntegration_ImportLinks(t *testing.T) {
+    suffix := time.Now().UnixNano()
+    existing, err := svc.Shorten(ctx, &gen.ShortenRequest{Url: testURL})
+    if err != nil {
+        t.Fatalf("Shorten failed: %v", err)
+    }
+    imported := fmt.Sprintf("imp-%d", suffix)
+    created := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+    req := &gen.ImportLinksRequest{Links: []*gen.ImportLink{
+        {Code: imported, Url: "example.org/imported", CreatedAt: timestamppb.New(created)},
+        {Code: existing.Code, Url: "example.org/other"},
+        {Code: imported, Url: "example.org/again"},
+        {Code: "api", Url: "example.org/reserved"},
+    }}
+
+    req.DryRun = true
+    resp, err := svc.ImportLinks(ctx, req)
+    if err != nil {
+        t.Fatalf("dry-run ImportLinks failed: %v", err)
+    }
+    if resp.Imported != 1 || resp.Conflicts != 2 || resp.Invalid != 1 {
+        t.Fatalf("dry run = %+v; want 1 imported, 2 conflicts, 1 invalid", resp)
+    }
+    if resp.Results[1].ExistingUrl != testURL {
+        t.Errorf("conflict existing_url = %q; want %q", resp.Results[1].ExistingUrl, testURL)
+    }
+    if _, err := svc.Resolve(ctx, &gen.ResolveRequest{Code: imported}); status.Code(err) != codes.NotFound {
+        t.Fatalf("dry run wrote %s: Resolve error = %v", imported, err)
+    }
+
+    req.DryRun = false
+    resp, err = svc.ImportLinks(ctx, req)
+    if err != nil {
+        t.Fatalf("ImportLinks failed: %v", err)
+    }
+    if resp.Results[0].Status != gen.ImportStatus_IMPORT_STATUS_IMPORTED {
+        t.Fatalf("result = %+v; want imported", resp.Results[0])
+    }
+    res, err := svc.Resolve(ctx, &gen.ResolveRequest{Code: imported})
+    if err != nil || res.Url != "example.org/imported" {
+        t.Errorf("Resolve(%s) = %v, %v; want example.org/imported", imported, res, err)
+    }
+
+    var gotCreated time.Time
+    pool := svc.(*ShortenerService).dbPool
+    if err := pool.QueryRow(ctx, `SELECT created_at FROM links WHERE code = $1`, imported).Scan(&gotCreated); err != nil {
+        t.Fatalf("select created_at: %v", err)
+    }
+    if !gotCreated.Equal(created) {
+        t.Errorf("created_at = %v; want %v", gotCreated, created)
+    }
+
+    resp, err = svc.ImportLinks(ctx, req)
+    if err != nil || resp.Results[0].Status != gen.ImportStatus_IMPORT_STATUS_CONFLICT {
+        t.Errorf("re-import = %+v, %v; want a conflict", resp, err)
+    }
+}
+
+func TestIntegration_CodeStrategies(t *testing.T) {
+    patterns := map[gen.CodeStrategy]*regexp.Regexp{
+        gen.CodeStrategy_CODE_STRATEGY_SONYFLAKE: regexp.MustCompile(`^[0-9A-Za-z]{8,11}$`),
+        gen.CodeStrategy_CODE_STRATEGY_RANDOM:    regexp.MustCompile(`^[0-9A-Za-z]{8}$`),
+        gen.CodeStrategy_CODE_STRATEGY_HASHIDS:   regexp.MustCompile(`^[0-9A-Za-z]{8,12}$`),
+        gen.CodeStrategy_CODE_STRATEGY_WORDS:     regexp.MustCompile(`^[a-z]+-[a-z]+-[0-9]{4}$`),
+    }
+    for strategy, pattern := range patterns {
+        resp, err := svc.Shorten(ctx, &gen.ShortenRequest{Url: testURL, CodeStrategy: strategy})
+        if err != nil {
+            t.Fatalf("Shorten with %v failed: %v", strategy, err)
+        }
+        if !pattern.MatchString(resp.Code) {
+            t.Errorf("%v code = %q; want it to match %s", strategy, resp.Code, pattern)
+        }
+        res, err := svc.Resolve(ctx, &gen.ResolveRequest{Code: resp.Code})
+        if err != nil || res.Url != testURL {
+            t.Errorf("Resolve(%s) = %v, %v; want %q", resp.Code, res, err, testURL)
+        }
+    }
+
+    _, err := svc.Shorten(ctx, &gen.ShortenRequest{Url: testURL, CodeStrategy: gen.CodeStrategy(99)})
+    if status.Code(err) != codes.InvalidArgument {
+        t.Errorf("Shorten with unknown strategy error = %v; want InvalidArgument", err)
+    }
+}
+
+func TestIntegration_CaseInsensitiveAlphabet(t *testing.T) {
+    base := svc.(*ShortenerService)
+    codeSet, err := codegen.NewSet(flake.NewSonyflake(), codegen.Options{
+        Default: codegen.Random, Length: 10, Alphabet: codegen.Base36,
+    })
+    if err != nil {
+        t.Fatalf("NewSet failed: %v", err)
+    }
+    lower := &ShortenerService{dbPool: base.dbPool, cache: base.cache, codes: codeSet, metrics: base.metrics}
+
+    resp, err := lower.Shorten(ctx, &gen.ShortenRequest{Url: testURL})
+    if err != nil {
+        t.Fatalf("Shorten failed: %v", err)
+    }
+    if resp.Code != strings.ToLower(resp.Code) {
+        t.Errorf("code %q is not lowercase", resp.Code)
+    }
+    res, err := lower.Resolve(ctx, &gen.ResolveRequest{Code: strings.ToUpper(resp.Code)})
+    if err != nil || res.Url != testURL {
+        t.Errorf("Resolve(%s) = %v, %v; want %q", strings.ToUpper(resp.Code), res, err, testURL)
+    }
+
+    // Mixed-case codes made under the old alphabet still resolve exactly.
+    old, err := svc.Shorten(ctx, &gen.ShortenRequest{Url: testURL, CodeStrategy: gen.CodeStrategy_CODE_STRATEGY_SONYFLAKE})
+    if err != nil {
+        t.Fatalf("Shorten failed: %v", err)
+    }
+    if res, err := lower.Resolve(ctx, &gen.ResolveRequest{Code: old.Code}); err != nil || res.Url != testURL {
+        t.Errorf("Resolve(%s) = %v, %v; want %q", old.Code, res, err, testURL)
+    }
+}
+
+func TestIntegration_MachineIDRegistries(t *testing.T) {
+    s := svc.(*ShortenerService)
+    registries := map[string]flake.Registry{
+        "redis":    flake.RedisRegistry{Client: s.cache},
+        "postgres": flake.PostgresRegistry{Pool: s.dbPool},
+    }
+    for name, reg := range registries {
+        t.Run(name, func(t *testing.T) {
+            const id = 4242
+            if err := reg.Claim(ctx, id, "a", time.Minute); err != nil {
+                t.Fatalf("Claim failed: %v", err)
+            }
+            if err := reg.Claim(ctx, id, "a", time.Minute); err != nil {
+                t.Errorf("renewing Claim failed: %v", err)
+            }
+            if err := reg.Claim(ctx, id, "b", time.Minute); !errors.Is(err, flake.ErrInUse) {
+                t.Errorf("Claim by another holder error = %v; want ErrInUse", err)
+            }
+            if err := reg.Release(ctx, id, "b"); err != nil {
+                t.Fatalf("Release failed: %v", err)
+            }
+            if err := reg.Claim(ctx, id, "b", time.Minute); !errors.Is(err, flake.ErrInUse) {
+                t.Errorf("Release by a non-holder freed the ID: %v", err)
+            }
+            if err := reg.Release(ctx, id, "a"); err != nil {
+                t.Fatalf("Release failed: %v", err)
+            }
+            if err := reg.Claim(ctx, id, "b", 10*time.Millisecond); err != nil {
+                t.Fatalf("Claim after release failed: %v", err)
+            }
+            time.Sleep(50 * time.Millisecond)
+            if err := reg.Claim(ctx, id, "a", time.Minute); err != nil {
+                t.Errorf("Claim after expiry failed: %v", err)
+            }
+            reg.Release(ctx, id, "a")
+        })
+    }
+
+    // Claim keys share Redis with the link cache but never resolve as links.
+    if err := s.cache.Set(ctx, "sonyflake:machine:1", "host/1/abcd", time.Minute).Err(); err != nil {
+        t.Fatalf("redis SET failed: %v", err)
+    }
+    _, err := svc.Resolve(ctx, &gen.ResolveRequest{Code: "sonyflake:machine:1"})
+    if status.Code(err) != codes.NotFound {
+        t.Errorf("Resolve of a claim key error = %v; want NotFound", err)
+    }
+}
+
+func TestIntegration_PasswordProtected(t *testing.T) {
+    resp, err := svc.Shorten(ctx, &gen.ShortenRequest{Url: testURL, Password: "hunter2"})
+    if err != nil {
+        t.Fatalf("Shorten failed: %v", err)
+    }
+
+    // Protected links are never cached, so the check cannot be skipped.
+    rdb := svc.(*ShortenerService).cache
+    if n, err := rdb.Exists(ctx, resp.Code).Result(); err != nil || n != 0 {
+        t.Errorf("protected link cached: exists = %d, %v", n, err)
+    }
+
+    for _, tt := range []struct {
+        password string
+        want     codes.Code
+    }{
+        {"", codes.Unauthenticated},
+        {"hunter3", codes.PermissionDenied},
+        {"hunter2", codes.OK},
+    } {
+        res, err := svc.Resolve(ctx, &gen.ResolveRequest{Code: resp.Code, Password: tt.password})
+        if status.Code(err) != tt.want {
+            t.Errorf("Resolve with password %q error = %v; want %s", tt.password, err, tt.want)
+        }
+        if err == nil && res.Url != testURL {
+            t.Errorf("Resolve with password %q = %q; want %q", tt.password, res.Url, testURL)
+        }
+    }
+    if n, _ := rdb.Exists(ctx, resp.Code).Result(); n != 0 {
+        t.Error("Resolve cached a protected link")
+    }
+
+    // Once one address reaches the limit even the right password is refused
+    // from it, while other visitors still get in.
+    const attacker = "203.0.113.9"
+    for i := 0; i < maxPasswordFailures; i++ {
+        if _, err := svc.Resolve(ctx, &gen.ResolveRequest{Code: resp.Code, Password: "guess", ClientIp: attacker}); status.Code(err) != codes.PermissionDenied {
+            t.Fatalf("wrong password %d error = %v; want PermissionDenied", i+1, err)
+        }
+    }
+    if _, err := svc.Resolve(ctx, &gen.ResolveRequest{Code: resp.Code, Password: "hunter2", ClientIp: attacker}); status.Code(err) != codes.ResourceExhausted {
+        t.Errorf("Resolve after %d wrong passwords error = %v; want ResourceExhausted", maxPasswordFailures, err)
+    }
+    if _, err := svc.Resolve(ctx, &gen.ResolveRequest{Code: resp.Code, Password: "hunter2", ClientIp: "198.51.100.7"}); err != nil {
+        t.Errorf("Resolve from another address during the lockout failed: %v", err)
+    }
+    if ttl, err := rdb.PTTL(ctx, PasswordFailuresKey(resp.Code, attacker)).Result(); err != nil || ttl <= 0 || ttl > passwordFailureWindow {
+        t.Errorf("failure counter TTL = %v, %v; want at most %s", ttl, err, passwordFailureWindow)
+    }
+}
+
+func TestIntegration_MaxClicks(t *testing.T) {
+    const maxClicks = 3
+    resp, err := svc.Shorten(ctx, &gen.ShortenRequest{Url: testURL, MaxClicks: maxClicks})
+    if err != nil {
+        t.Fatalf("Shorten failed: %v", err)
+    }
+
+    // Probes, such as HEAD requests, leave the quota alone and do not get
+    // the URL.
+    probe := WithVisit(ctx, VisitProbe)
+    for range maxClicks + 1 {
+        res, err := svc.Resolve(probe, &gen.ResolveRequest{Code: resp.Code})
+        if err != nil || res.GetUrl() != "" {
+            t.Fatalf("probe Resolve = %v, %v; want no URL", res, err)
+        }
+    }
+
+    // Replicas share Redis, so concurrent resolves through one service
+    // exercise the same race.
+    const callers = 20
+    var wg sync.WaitGroup
+    var mu sync.Mutex
+    resolved, refused := 0, 0
+    for range callers {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            _, err := svc.Resolve(ctx, &gen.ResolveRequest{Code: resp.Code})
+            mu.Lock()
+            defer mu.Unlock()
+            switch status.Code(err) {
+            case codes.OK:
+                resolved++
+            case codes.NotFound:
+                refused++
+            default:
+                t.Errorf("Resolve error = %v", err)
+            }
+        }()
+    }
+    wg.Wait()
+    if resolved != maxClicks || refused != callers-maxClicks {
+        t.Errorf("resolved %d and refused %d; want %d and %d", resolved, refused, maxClicks, callers-maxClicks)
+    }
+
+    s := svc.(*ShortenerService)
+    var clicks int
+    if err := s.dbPool.QueryRow(ctx, `SELECT clicks FROM links WHERE code = $1`, resp.Code).Scan(&clicks); err != nil {
+        t.Fatalf("reading clicks failed: %v", err)
+    }
+    if clicks != maxClicks {
+        t.Errorf("Postgres clicks = %d; want %d", clicks, maxClicks)
+    }
+
+    // A lost counter is rebuilt from Postgres, not reset to the full quota.
+    if err := s.cache.Del(ctx, ClicksKey(resp.Code)).Err(); err != nil {
+        t.Fatalf("redis DEL failed: %v", err)
+    }
+    if _, err := svc.Resolve(ctx, &gen.ResolveRequest{Code: resp.Code}); status.Code(err) != codes.NotFound {
+        t.Errorf("Resolve after losing the counter error = %v; want NotFound", err)
+    }
+    if _, err := svc.Resolve(probe, &gen.ResolveRequest{Code: resp.Code}); status.Code(err) != codes.NotFound {
+        t.Errorf("probe Resolve of a used-up link error = %v; want NotFound", err)
+    }
+}
+
+func TestIntegration_ActivationWindow(t *testing.T) {
+    base := svc.(*ShortenerService)
+    now := time.Now()
+    campaign, err := svc.Shorten(ctx, &gen.ShortenRequest{
+        Url:       testURL,
+        NotBefore: timestamppb.New(now.Add(time.Hour)),
+        NotAfter:  timestamppb.New(now.Add(3 * time.Hour)),
+    })
+    if err != nil {
+        t.Fatalf("Shorten failed: %v", err)
+    }
+
+    _, err = svc.Resolve(ctx, &gen.ResolveRequest{Code: campaign.Code})
+    if reason, _ := ErrorReason(err); status.Code(err) != codes.NotFound || reason != ReasonNotYetActive {
+        t.Fatalf("Resolve before launch error = %v; want NotFound with %s", err, ReasonNotYetActive)
+    }
+    if n, _ := base.cache.Exists(ctx, campaign.Code).Result(); n != 0 {
+        t.Error("link was cached before launch")
+    }
+
+    // Services whose clocks read later stand in for the passing of time.
+    at := func(d time.Duration, inactiveURL string) *ShortenerService {
+        return &ShortenerService{dbPool: base.dbPool, cache: base.cache, codes: base.codes, metrics: base.metrics,
+            inactiveURL: inactiveURL, now: func() time.Time { return now.Add(d) }}
+    }
+    res, err := at(2*time.Hour, "").Resolve(ctx, &gen.ResolveRequest{Code: campaign.Code})
+    if err != nil || res.GetUrl() != testURL {
+        t.Fatalf("Resolve during the window = %v, %v; want %s", res, err, testURL)
+    }
+    // The cache entry must not outlive the window.
+    if ttl, err := base.cache.PTTL(ctx, campaign.Code).Result(); err != nil || ttl <= 0 || ttl > time.Hour {
+        t.Errorf("cache TTL = %v, %v; want at most the hour left in the window", ttl, err)
+    }
+
+    if err := base.cache.Del(ctx, campaign.Code).Err(); err != nil {
+        t.Fatalf("redis DEL failed: %v", err)
+    }
+    _, err = at(4*time.Hour, "").Resolve(ctx, &gen.ResolveRequest{Code: campaign.Code})
+    if reason, _ := ErrorReason(err); status.Code(err) != codes.NotFound || reason != ReasonEnded {
+        t.Errorf("Resolve after the window error = %v; want NotFound with %s", err, ReasonEnded)
+    }
+
+    const fallback = "https://example.com/campaign-over"
+    res, err = at(4*time.Hour, fallback).Resolve(ctx, &gen.ResolveRequest{Code: campaign.Code})
+    if err != nil || res.GetUrl() != fallback {
+        t.Errorf("Resolve after the window with a fallback = %v, %v; want %s", res, err, fallback)
+    }
+
+    // A window opening after the default expiry of a day still opens, and
+    // without not_after the link never expires.
+    later, err := svc.Shorten(ctx, &gen.ShortenRequest{Url: testURL, NotBefore: timestamppb.New(now.Add(48 * time.Hour))})
+    if err != nil {
+        t.Fatalf("Shorten failed: %v", err)
+    }
+    res, err = at(49*time.Hour, "").Resolve(ctx, &gen.ResolveRequest{Code: later.Code})
+    if err != nil || res.GetUrl() != testURL {
+        t.Errorf("Resolve two days later = %v, %v; want %s", res, err, testURL)
+    }
+    var expires *time.Time
+    if err := base.dbPool.QueryRow(ctx, `SELECT expires FROM links WHERE code = $1`, later.Code).Scan(&expires); err != nil || expires != nil {
+        t.Errorf("expires = %v, %v; want NULL", expires, err)
+    }
+    if exported := exportedLink(t, "", later.Code); exported.ExpiresAt != nil {
+        t.Errorf("exported expires_at = %v; want unset", exported.ExpiresAt)
+    }
+}
+
+func TestIntegration_Fallbacks(t *testing.T) {
+    base := svc.(*ShortenerService)
+    const (
+        owner         = "fallback-team"
+        ownerFallback = "https://example.com/team"
+        linkFallback  = "https://example.com/link"
+    )
+    if _, err := base.dbPool.Exec(ctx, `INSERT INTO owner_fallbacks (owner, url) VALUES ($1, $2)`, owner, ownerFallback); err != nil {
+        t.Fatalf("inserting owner fallback failed: %v", err)
+    }
+    own, err := svc.Shorten(ctx, &gen.ShortenRequest{Url: testURL, Owner: owner, FallbackUrl: linkFallback})
+    if err != nil {
+        t.Fatalf("Shorten failed: %v", err)
+    }
+    shared, err := svc.Shorten(ctx, &gen.ShortenRequest{Url: testURL, Owner: owner, MaxClicks: 1})
+    if err != nil {
+        t.Fatalf("Shorten failed: %v", err)
+    }
+    bare, err := svc.Shorten(ctx, &gen.ShortenRequest{Url: testURL, Password: "secret"})
+    if err != nil {
+        t.Fatalf("Shorten failed: %v", err)
+    }
+
+    // Disabling works like the CLI: set disabled_at and evict.
+    for _, code := range []string{own.Code, bare.Code} {
+        if _, err := base.dbPool.Exec(ctx, `UPDATE links SET disabled_at = now() WHERE code = $1`, code); err != nil {
+            t.Fatalf("disabling %s failed: %v", code, err)
+        }
+        if err := base.cache.Del(ctx, code).Err(); err != nil {
+            t.Fatalf("redis DEL failed: %v", err)
+        }
+    }
+    res, err := svc.Resolve(ctx, &gen.ResolveRequest{Code: own.Code})
+    if err != nil || res.GetUrl() != linkFallback || !res.GetFallback() {
+        t.Errorf("Resolve disabled link = %v, %v; want its own fallback %s", res, err, linkFallback)
+    }
+    // No fallback: the disabled reason comes before the password prompt.
+    _, err = svc.Resolve(ctx, &gen.ResolveRequest{Code: bare.Code})
+    if reason, _ := ErrorReason(err); status.Code(err) != codes.NotFound || reason != ReasonDisabled {
+        t.Errorf("Resolve disabled link without fallback error = %v; want NotFound with %s", err, ReasonDisabled)
+    }
+
+    if res, err := svc.Resolve(ctx, &gen.ResolveRequest{Code: shared.Code}); err != nil || res.GetFallback() {
+        t.Fatalf("first Resolve = %v, %v; want the link itself", res, err)
+    }
+    res, err = svc.Resolve(ctx, &gen.ResolveRequest{Code: shared.Code})
+    if err != nil || res.GetUrl() != ownerFallback || !res.GetFallback() {
+        t.Errorf("Resolve after the last click = %v, %v; want the owner's fallback %s", res, err, ownerFallback)
+    }
+}
+
+func TestIntegration_RoutingRules(t *testing.T) {
+    const (
+        appStore = "https://apps.apple.com/app/id123"
+        play     = "https://play.google.com/store/apps/details?id=com.example"
+    )
+    resp, err := svc.Shorten(ctx, &gen.ShortenRequest{Url: testURL, Rules: []*gen.RoutingRule{
+        {Platforms: []gen.Platform{gen.Platform_PLATFORM_IOS}, Url: appStore},
+        {Platforms: []gen.Platform{gen.Platform_PLATFORM_ANDROID}, Url: play},
+    }})
+    if err != nil {
+        t.Fatalf("Shorten failed: %v", err)
+    }
+    if n, _ := svc.(*ShortenerService).cache.Exists(ctx, resp.Code).Result(); n != 0 {
+        t.Error("routed link was cached by URL")
+    }
+
+    for _, tt := range []struct {
+        userAgent string
+        wantURL   string
+        wantRule  int32
+    }{
+        {"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X)", appStore, 1},
+        {"Mozilla/5.0 (Linux; Android 14; Pixel 8)", play, 2},
+        {"Mozilla/5.0 (Windows NT 10.0; Win64; x64)", testURL, 0},
+    } {
+        res, err := svc.Resolve(ctx, &gen.ResolveRequest{Code: resp.Code, UserAgent: tt.userAgent})
+        if err != nil || res.GetUrl() != tt.wantURL || res.GetRule() != tt.wantRule {
+            t.Errorf("Resolve as %q = %v, %v; want %s from rule %d", tt.userAgent, res, err, tt.wantURL, tt.wantRule)
+        }
+    }
+}
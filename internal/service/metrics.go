@@ -0,0 +1,136 @@
+package service
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Metrics holds the Prometheus collectors for ShortenerService. Each service
+// gets its own set so that tests can build one against a fresh registry and
+// assert on exact values.
+type Metrics struct {
+	ResolveHits          prometheus.Counter
+	ResolveMisses        prometheus.Counter
+	ResolveErrors        prometheus.Counter
+	ResolveDuration      prometheus.Histogram
+	ResolveClickLimited  prometheus.Counter
+	ResolveOutsideWindow *prometheus.CounterVec
+	ResolveFallbacks     *prometheus.CounterVec
+	ResolveRoutes        *prometheus.CounterVec
+	ShortenRequests      *prometheus.CounterVec
+	ShortenCollisions    prometheus.Counter
+	ShortenDuration      prometheus.Histogram
+	ImportedLinks        *prometheus.CounterVec
+}
+
+// NewMetrics creates the service collectors and registers them on reg. A nil
+// reg leaves them unregistered.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		ResolveHits: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: "url_shortener",
+				Name:      "resolve_cache_hits_total",
+				Help:      "Total number of cache hits in Resolve().",
+			},
+		),
+		ResolveMisses: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: "url_shortener",
+				Name:      "resolve_cache_misses_total",
+				Help:      "Total number of cache misses in Resolve().",
+			},
+		),
+		ResolveErrors: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: "url_shortener",
+				Name:      "resolve_cache_errors_total",
+				Help:      "Total number of unexpected cache errors in Resolve().",
+			},
+		),
+		ResolveDuration: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Namespace: "url_shortener",
+				Name:      "resolve_duration_seconds",
+				Help:      "Histogram of latencies for Resolve() rpc.",
+				Buckets:   prometheus.DefBuckets,
+			},
+		),
+		ResolveClickLimited: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: "url_shortener",
+				Name:      "resolve_click_limit_reached_total",
+				Help:      "Total number of Resolve() calls refused because the link had used all of its clicks.",
+			},
+		),
+		ResolveOutsideWindow: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "url_shortener",
+				Name:      "resolve_outside_window_total",
+				Help:      "Total number of Resolve() calls for links outside their activation window, by reason.",
+			},
+			[]string{"reason"},
+		),
+		ResolveFallbacks: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "url_shortener",
+				Name:      "resolve_fallbacks_total",
+				Help:      "Total number of Resolve() calls answered with a fallback URL, by whose fallback it was.",
+			},
+			[]string{"source"},
+		),
+		ResolveRoutes: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "url_shortener",
+				Name:      "resolve_routes_total",
+				Help:      "Total number of Resolve() calls of links with routing rules, by whether a rule or the link's own URL was chosen.",
+			},
+			[]string{"matched"},
+		),
+		ShortenRequests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "url_shortener",
+				Name:      "shorten_requests_total",
+				Help:      "Total number of Shorten() calls by outcome.",
+			},
+			[]string{"outcome"},
+		),
+		ShortenCollisions: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: "url_shortener",
+				Name:      "shorten_code_collisions_total",
+				Help:      "Total number of generated codes that already existed and were retried in Shorten().",
+			},
+		),
+		ShortenDuration: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Namespace: "url_shortener",
+				Name:      "shorten_duration_seconds",
+				Help:      "Histogram of latencies for Shorten() rpc.",
+				Buckets:   prometheus.DefBuckets,
+			},
+		),
+		ImportedLinks: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "url_shortener",
+				Name:      "import_links_total",
+				Help:      "Total number of links passed to ImportLinks() outside dry runs, by result.",
+			},
+			[]string{"status"},
+		),
+	}
+	if reg != nil {
+		reg.MustRegister(
+			m.ResolveHits,
+			m.ResolveMisses,
+			m.ResolveErrors,
+			m.ResolveDuration,
+			m.ResolveClickLimited,
+			m.ResolveOutsideWindow,
+			m.ResolveFallbacks,
+			m.ResolveRoutes,
+			m.ShortenRequests,
+			m.ShortenCollisions,
+			m.ShortenDuration,
+			m.ImportedLinks,
+		)
+	}
+	return m
+}
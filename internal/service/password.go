@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/JohnBPerkins/url-shortener/gen"
+	"github.com/JohnBPerkins/url-shortener/internal/logging"
+	"github.com/go-redis/redis/v8"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// maxPasswordLength is bcrypt's input limit. Longer passwords would be
+// silently truncated, so they are refused instead.
+const maxPasswordLength = 72
+
+// A protected link refuses every password, right or wrong, from an address
+// that tried maxPasswordFailures wrong ones within passwordFailureWindow of
+// the first. Counting per address keeps one visitor from locking everyone
+// else out of a link.
+const (
+	maxPasswordFailures   = 10
+	passwordFailureWindow = 15 * time.Minute
+)
+
+// ClientAddrMetadata carries the visitor's address from the REST gateway and
+// the Connect proxy, which call the gRPC server over loopback.
+const ClientAddrMetadata = "x-client-addr"
+
+// PasswordFailuresKey is the Redis key counting wrong passwords for a
+// protected link from addr. Codes cannot contain ':', so it never clashes
+// with a cached link, and an addr of "*" matches the keys of every address.
+func PasswordFailuresKey(code, addr string) string {
+	return "pwfail:" + code + ":" + addr
+}
+
+// callerAddr returns the address wrong passwords are counted against. The
+// redirect handler calls Resolve in-process with the visitor's address in
+// the request, and the gateway and Connect proxy send it as metadata over
+// loopback. Other gRPC callers are known by their peer address, so they
+// cannot pick a new one for every guess.
+func callerAddr(ctx context.Context, req *gen.ResolveRequest) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return req.GetClientIp()
+	}
+	host := p.Addr.String()
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && ip.IsLoopback() {
+		if v := metadata.ValueFromIncomingContext(ctx, ClientAddrMetadata); len(v) > 0 {
+			return v[len(v)-1]
+		}
+	}
+	return host
+}
+
+// countFailureScript counts a wrong password. The window starts at the first
+// failure and is not extended by later ones.
+var countFailureScript = redis.NewScript(`
+local n = redis.call("INCR", KEYS[1])
+if n == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// hashPassword returns the bcrypt hash stored for a protected link.
+func hashPassword(password string) (string, error) {
+	if len(password) > maxPasswordLength {
+		return "", status.Errorf(codes.InvalidArgument, "password must be at most %d bytes", maxPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "failed to hash password: %v", err)
+	}
+	return string(hash), nil
+}
+
+// checkLinkPassword checks password against l like checkPassword, failing
+// with ResourceExhausted while the caller has tried too many wrong passwords.
+func (s *ShortenerService) checkLinkPassword(ctx context.Context, l link, req *gen.ResolveRequest) error {
+	password := req.GetPassword()
+	if l.passwordHash == "" || password == "" {
+		return checkPassword(l.passwordHash, password)
+	}
+	key := PasswordFailuresKey(l.code, callerAddr(ctx, req))
+	failures, err := s.cache.Get(ctx, key).Int()
+	if err != nil && err != redis.Nil {
+		s.metrics.ResolveErrors.Inc()
+		return status.Errorf(codes.Unavailable, "password attempt counter failed: %v", err)
+	}
+	if failures >= maxPasswordFailures {
+		return status.Errorf(codes.ResourceExhausted, "too many incorrect passwords for %s; try again later", l.code)
+	}
+	err = checkPassword(l.passwordHash, password)
+	if status.Code(err) == codes.PermissionDenied {
+		if err := countFailureScript.Run(ctx, s.cache, []string{key}, passwordFailureWindow.Milliseconds()).Err(); err != nil {
+			slog.WarnContext(ctx, "failed to count an incorrect password", logging.KeyCode, l.code, "error", err)
+		}
+	}
+	return err
+}
+
+// checkPassword reports whether password opens a link stored with hash. An
+// empty hash means the link is not protected.
+func checkPassword(hash, password string) error {
+	if hash == "" {
+		return nil
+	}
+	if password == "" {
+		return status.Error(codes.Unauthenticated, "link is password protected")
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return status.Error(codes.PermissionDenied, "incorrect password")
+	}
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to check password: %v", err)
+	}
+	return nil
+}
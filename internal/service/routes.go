@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/netip"
+	"strings"
+
+	"github.com/JohnBPerkins/url-shortener/gen"
+	"github.com/JohnBPerkins/url-shortener/internal/logging"
+	"github.com/JohnBPerkins/url-shortener/internal/routing"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// platforms maps the request enum to routing platforms.
+var platforms = map[gen.Platform]routing.Platform{
+	gen.Platform_PLATFORM_IOS:     routing.IOS,
+	gen.Platform_PLATFORM_ANDROID: routing.Android,
+	gen.Platform_PLATFORM_WINDOWS: routing.Windows,
+	gen.Platform_PLATFORM_MACOS:   routing.MacOS,
+	gen.Platform_PLATFORM_LINUX:   routing.Linux,
+}
+
+// Values of the matched label on Metrics.ResolveRoutes.
+const (
+	routeRule    = "rule"
+	routeDefault = "default"
+)
+
+// parseRules validates the routing rules of a ShortenRequest or ImportLink
+// and returns them, along with their JSON for links.routing_rules. Both are
+// nil if there are none.
+func parseRules(msgs []*gen.RoutingRule) ([]routing.Rule, []byte, error) {
+	if len(msgs) == 0 {
+		return nil, nil, nil
+	}
+	rules := make([]routing.Rule, len(msgs))
+	for i, r := range msgs {
+		if !isValidURL(r.GetUrl()) {
+			return nil, nil, status.Errorf(codes.InvalidArgument, "rule %d: invalid URL: %q", i+1, r.GetUrl())
+		}
+		rules[i] = routing.Rule{Languages: r.GetLanguages(), Countries: r.GetCountries(), URL: r.GetUrl()}
+		for _, p := range r.GetPlatforms() {
+			platform, ok := platforms[p]
+			if !ok {
+				return nil, nil, status.Errorf(codes.InvalidArgument, "rule %d: unknown platform %s", i+1, p)
+			}
+			rules[i].Platforms = append(rules[i].Platforms, platform)
+		}
+	}
+	if err := routing.Validate(rules); err != nil {
+		return nil, nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	b, err := json.Marshal(rules)
+	if err != nil {
+		return nil, nil, status.Errorf(codes.Internal, "failed to encode routing rules: %v", err)
+	}
+	return rules, b, nil
+}
+
+// ruleMessages converts stored routing rules back to their messages, for
+// ExportLinks.
+func ruleMessages(rules []routing.Rule) []*gen.RoutingRule {
+	msgs := make([]*gen.RoutingRule, len(rules))
+	for i, r := range rules {
+		msgs[i] = &gen.RoutingRule{Languages: r.Languages, Countries: r.Countries, Url: r.URL}
+		for _, p := range r.Platforms {
+			for value, platform := range platforms {
+				if platform == p {
+					msgs[i].Platforms = append(msgs[i].Platforms, value)
+				}
+			}
+		}
+	}
+	return msgs
+}
+
+// RouteEvent records where a link with routing rules sent a visitor.
+type RouteEvent struct {
+	Code string
+	// URL is the chosen destination and Rule the 1-based position of the
+	// rule that chose it, or 0 for the link's own URL.
+	URL     string
+	Rule    int
+	Visitor routing.Visitor
+}
+
+// Analytics records the destinations routed links choose for visitors the
+// redirect handler sends there; API lookups and probes are not recorded. It
+// is called on the resolve path, so implementations should hand events off
+// rather than block.
+type Analytics interface {
+	RecordRoute(ctx context.Context, e RouteEvent)
+}
+
+// LogAnalytics records each event as a "link routed" log line, for
+// pipelines that build analytics from logs. It is the default and persists
+// nothing; counts per link or rule need another Analytics.
+type LogAnalytics struct{}
+
+func (LogAnalytics) RecordRoute(ctx context.Context, e RouteEvent) {
+	slog.InfoContext(ctx, "link routed",
+		logging.KeyCode, e.Code,
+		"rule", e.Rule,
+		"url", e.URL,
+		"platform", string(e.Visitor.Platform),
+		"language", e.Visitor.Language,
+		"country", e.Visitor.Country,
+	)
+}
+
+// visitor describes the visitor behind req for routing. A country from
+// the caller wins over the GeoIP lookup.
+func (s *ShortenerService) visitor(req *gen.ResolveRequest) routing.Visitor {
+	v := routing.Visitor{
+		Platform: routing.ParsePlatform(req.GetUserAgent()),
+		Language: routing.PreferredLanguage(req.GetAcceptLanguage()),
+		Country:  strings.ToUpper(req.GetCountry()),
+	}
+	if v.Country == "" && s.geoIP != nil {
+		if addr, err := netip.ParseAddr(req.GetClientIp()); err == nil {
+			v.Country, _ = s.geoIP.Country(addr)
+		}
+	}
+	return v
+}
+
+// route returns where l sends the visitor behind req, and the 1-based rule
+// that chose it or 0.
+func (s *ShortenerService) route(ctx context.Context, l link, req *gen.ResolveRequest) (string, int) {
+	if len(l.rules) == 0 {
+		return l.url, 0
+	}
+	v := s.visitor(req)
+	url, rule := l.url, 0
+	if i, ok := routing.Select(l.rules, v); ok {
+		url, rule = l.rules[i].URL, i+1
+		s.metrics.ResolveRoutes.WithLabelValues(routeRule).Inc()
+	} else {
+		s.metrics.ResolveRoutes.WithLabelValues(routeDefault).Inc()
+	}
+	if s.analytics != nil && VisitFrom(ctx) == VisitRedirect {
+		s.analytics.RecordRoute(ctx, RouteEvent{Code: l.code, URL: url, Rule: rule, Visitor: v})
+	}
+	return url, rule
+}
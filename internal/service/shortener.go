@@ -2,16 +2,22 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
-	"log"
+	"log/slog"
 	"regexp"
 	"time"
 	"unicode"
 	"unicode/utf8"
 
 	"github.com/JohnBPerkins/url-shortener/gen"
+	"github.com/JohnBPerkins/url-shortener/internal/codegen"
+	"github.com/JohnBPerkins/url-shortener/internal/logging"
+	"github.com/JohnBPerkins/url-shortener/internal/routing"
+	"github.com/JohnBPerkins/url-shortener/internal/telemetry"
 	"github.com/JohnBPerkins/url-shortener/modules/db"
-	"github.com/sony/sonyflake"
+	"github.com/JohnBPerkins/url-shortener/modules/flake"
+	"github.com/JohnBPerkins/url-shortener/modules/geoip"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/jackc/pgconn"
@@ -25,8 +31,11 @@ import (
 const (
 	maxURLLength = 2048
 	maxAttempts = 5
-	codeLength = 8
-	base62Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	// linkCacheTTL is how long a resolved link stays in Redis.
+	linkCacheTTL = 24 * time.Hour
+	// defaultLinkTTL is how long a plain link lives, as the links table's
+	// default for expires.
+	defaultLinkTTL = 24 * time.Hour
 )
 
 var urlRegex = regexp.MustCompile(`(?i)^` +             // case‐insensitive
@@ -43,78 +52,161 @@ type ShortenerService struct {
 	gen.UnimplementedShortenerServer
 	dbPool *db.Pool
 	cache *redis.Client
-	flake *sonyflake.Sonyflake
-}
-
-var (
-    ResolveHits = prometheus.NewCounter(
-        prometheus.CounterOpts{
-            Namespace: "url_shortener",
-            Name:      "resolve_cache_hits_total",
-            Help:      "Total number of cache hits in Resolve().",
-        },
-    )
-    ResolveMisses = prometheus.NewCounter(
-        prometheus.CounterOpts{
-            Namespace: "url_shortener",
-            Name:      "resolve_cache_misses_total",
-            Help:      "Total number of cache misses in Resolve().",
-        },
-    )
-    ResolveErrors = prometheus.NewCounter(
-        prometheus.CounterOpts{
-            Namespace: "url_shortener",
-            Name:      "resolve_cache_errors_total",
-            Help:      "Total number of unexpected cache errors in Resolve().",
-        },
-    )
-    ResolveDuration = prometheus.NewHistogram(
-        prometheus.HistogramOpts{
-            Namespace: "url_shortener",
-            Name:      "resolve_duration_seconds",
-            Help:      "Histogram of latencies for Resolve() rpc.",
-            Buckets:   prometheus.DefBuckets,
-        },
-    )
+	codes *codegen.Set
+	metrics *Metrics
+	inactiveURL string
+	geoIP geoip.Locator
+	analytics Analytics
+	now func() time.Time
+}
+
+// Options configures a ShortenerService.
+type Options struct {
+	// InactiveURL is what Resolve returns for links outside their activation
+	// window that have no fallback URL of their own or their owner's. Empty
+	// makes them fail with NotFound instead.
+	InactiveURL string
+	// GeoIP finds visitors' countries for routing rules. Without one, only
+	// callers that pass a country match country rules.
+	GeoIP geoip.Locator
+	// Analytics records where routed links send visitors. Nil logs them
+	// with LogAnalytics.
+	Analytics Analytics
+}
+
+// Values of the outcome label on Metrics.ShortenRequests.
+const (
+    outcomeCreated    = "created"
+    outcomeInvalidURL = "invalid_url"
+    outcomeIDError    = "id_error"
+    outcomeDBError    = "db_error"
+    outcomeExhausted  = "collisions_exhausted"
 )
 
-func NewShortenerService(dbPool *db.Pool, cache *redis.Client, flake *sonyflake.Sonyflake) gen.ShortenerServer {
-	return &ShortenerService{dbPool: dbPool, cache: cache, flake: flake}
+// NewShortenerService registers the service's metrics on reg, which should be
+// a private registry (see NewMetrics).
+func NewShortenerService(dbPool *db.Pool, cache *redis.Client, codes *codegen.Set, reg prometheus.Registerer, opts Options) gen.ShortenerServer {
+	if opts.Analytics == nil {
+		opts.Analytics = LogAnalytics{}
+	}
+	return &ShortenerService{
+		dbPool:      dbPool,
+		cache:       cache,
+		codes:       codes,
+		metrics:     NewMetrics(reg),
+		inactiveURL: opts.InactiveURL,
+		geoIP:       opts.GeoIP,
+		analytics:   opts.Analytics,
+		now:         time.Now,
+	}
+}
+
+// strategies maps the request enum to code generation strategies.
+// Unspecified maps to "", which selects the configured default.
+var strategies = map[gen.CodeStrategy]codegen.Strategy{
+	gen.CodeStrategy_CODE_STRATEGY_UNSPECIFIED: "",
+	gen.CodeStrategy_CODE_STRATEGY_SONYFLAKE:   codegen.Sonyflake,
+	gen.CodeStrategy_CODE_STRATEGY_RANDOM:      codegen.Random,
+	gen.CodeStrategy_CODE_STRATEGY_HASHIDS:     codegen.Hashids,
+	gen.CodeStrategy_CODE_STRATEGY_WORDS:       codegen.Words,
+}
+
+// generator returns the code generator a request asked for.
+func (s *ShortenerService) generator(strategy gen.CodeStrategy) (codegen.CodeGenerator, error) {
+	name, ok := strategies[strategy]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown code strategy %d", strategy)
+	}
+	g, err := s.codes.Get(name)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return g, nil
 }
 
 func (s *ShortenerService) Shorten(ctx context.Context, req *gen.ShortenRequest) (*gen.ShortenResponse, error) {
+	timer := prometheus.NewTimer(s.metrics.ShortenDuration)
+	defer timer.ObserveDuration()
+
 	if !isValidURL(req.GetUrl()) {
+        s.metrics.ShortenRequests.WithLabelValues(outcomeInvalidURL).Inc()
         return nil, status.Errorf(codes.InvalidArgument, "invalid URL: %q", req.GetUrl())
     }
+	if req.GetMaxClicks() < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "max_clicks must not be negative, got %d", req.GetMaxClicks())
+	}
+	if req.GetFallbackUrl() != "" && !isValidURL(req.GetFallbackUrl()) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid fallback URL: %q", req.GetFallbackUrl())
+	}
+	notBefore, notAfter, err := parseWindow(req, s.clock())
+	if err != nil {
+		return nil, err
+	}
+	rules, storedRules, err := parseRules(req.GetRules())
+	if err != nil {
+		return nil, err
+	}
+	expires := expiry(notBefore, notAfter, req.GetMaxClicks(), s.clock())
+	generator, err := s.generator(req.GetCodeStrategy())
+	if err != nil {
+		return nil, err
+	}
+	var passwordHash string
+	if req.GetPassword() != "" {
+		if passwordHash, err = hashPassword(req.GetPassword()); err != nil {
+			return nil, err
+		}
+	}
 
 	for i := 0; i < maxAttempts; i++ {
-		id, err := s.flake.NextID()
+		code, err := generator.NextCode()
 		if err != nil {
-			return nil, status.Errorf(codes.Internal, "failed to generate ID: %v", err)
+			s.metrics.ShortenRequests.WithLabelValues(outcomeIDError).Inc()
+			return nil, codeError(err)
 		}
-		code := encodeBase62(id)
 
-		_, err = s.dbPool.Exec(ctx,
-			`INSERT INTO links (code, url, created_at) VALUES ($1, $2, NOW())`,
-			code, req.GetUrl(),
-		)
+		const stmt = `INSERT INTO links (code, url, owner, password_hash, max_clicks, not_before, not_after, fallback_url, routing_rules, expires, created_at)
+			VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, 0), $6, $7, NULLIF($8, ''), $9, $10, NOW())`
+		dbCtx, span := telemetry.StartQuery(ctx, "INSERT", "links", stmt)
+		_, err = s.dbPool.Exec(dbCtx, stmt, code, req.GetUrl(), req.GetOwner(), passwordHash, req.GetMaxClicks(),
+			nullTime(notBefore), nullTime(notAfter), req.GetFallbackUrl(), storedRules, nullTime(expires))
+		telemetry.EndQuery(span, err)
 		if err == nil {
-			if err := s.cache.Set(ctx, code, req.GetUrl(), 24*time.Hour).Err(); err != nil {
-                log.Printf("shortener.go: warning: redis SET failed for code=%s url=%q: %v", code, req.GetUrl(), err)
-            }
+			l := link{passwordHash: passwordHash, maxClicks: req.GetMaxClicks(), notBefore: notBefore, notAfter: notAfter,
+				expires: expires, rules: rules}
+			if ttl := l.cacheTTL(s.clock()); ttl > 0 {
+				if err := s.cache.Set(ctx, code, req.GetUrl(), ttl).Err(); err != nil {
+					slog.WarnContext(ctx, "redis SET failed", logging.KeyCode, code, "url", req.GetUrl(), "error", err)
+				}
+			}
+			slog.InfoContext(ctx, "link created", logging.KeyCode, code)
+			s.metrics.ShortenRequests.WithLabelValues(outcomeCreated).Inc()
 			return &gen.ShortenResponse{Code: code}, nil
 		}
 		if isUniqueViolation(err) {
+            slog.WarnContext(ctx, "code collision, retrying", logging.KeyCode, code, "attempt", i+1)
+            s.metrics.ShortenCollisions.Inc()
             continue
         }
+		s.metrics.ShortenRequests.WithLabelValues(outcomeDBError).Inc()
 		return nil, status.Errorf(codes.Internal, "db insert failed: %v", err)
 	}
 
-    
+	s.metrics.ShortenRequests.WithLabelValues(outcomeExhausted).Inc()
 	return nil, status.Errorf(codes.Internal,
         "could not generate a unique code after %d attempts", maxAttempts)
 }
 
+// codeError converts a code generation failure to a status. A clock that is
+// behind catches up and an instance that lost its machine ID is shutting
+// down, so clients may retry; a Sonyflake past its lifetime cannot recover.
+func codeError(err error) error {
+	if errors.Is(err, flake.ErrClockBackwards) || errors.Is(err, flake.ErrMachineIDLost) {
+		return status.Errorf(codes.Unavailable, "failed to generate code: %v", err)
+	}
+	return status.Errorf(codes.Internal, "failed to generate code: %v", err)
+}
+
 func isValidURL(candidate string) bool {
     if len(candidate) == 0 || len(candidate) > maxURLLength {
         return false
@@ -137,64 +229,189 @@ func isUniqueViolation(err error) bool {
     return false
 }
 
-func encodeBase62(num uint64) string {
-    var encoded []byte
-    if num == 0 {
-        encoded = []byte{base62Chars[0]}
-    } else {
-        for num > 0 {
-            rem := num % 62
-            encoded = append(encoded, base62Chars[rem])
-            num /= 62
-        }
-        for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
-            encoded[i], encoded[j] = encoded[j], encoded[i]
-        }
-    }
+func (s *ShortenerService) Resolve(ctx context.Context, req *gen.ResolveRequest) (*gen.ResolveResponse, error) {
+	timer := prometheus.NewTimer(s.metrics.ResolveDuration)
+	defer timer.ObserveDuration()
 
-    if len(encoded) < codeLength {
-        padding := make([]byte, codeLength-len(encoded))
-        for i := range padding {
-            padding[i] = base62Chars[0]
-        }
-        encoded = append(padding, encoded...)
-    } else if len(encoded) > codeLength {
-        encoded = encoded[len(encoded)-codeLength:]
-    }
+	code := req.GetCode()
+	// Redis holds more than links, such as Sonyflake machine ID claims, so
+	// only strings that could be codes are looked up.
+	if len(code) > maxAliasLength || !aliasRegex.MatchString(code) {
+		return nil, status.Errorf(codes.NotFound, "code not found: %s", code)
+	}
+	l, err := s.lookup(ctx, code)
+	if status.Code(err) == codes.NotFound {
+		// Codes from a single-case alphabet resolve whatever case they are
+		// typed in. The exact code goes first so mixed-case custom codes and
+		// codes made before the alphabet changed keep resolving.
+		if folded, ok := s.codes.FoldCase(code); ok {
+			l, err = s.lookup(ctx, folded)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Links that cannot resolve send visitors to a fallback before any
+	// password is asked for.
+	if err := checkDisabled(l); err != nil {
+		return s.fallback(l, err)
+	}
+	if err := checkWindow(l, s.clock()); err != nil {
+		reason, _ := ErrorReason(err)
+		if reason == ReasonNotYetActive {
+			s.metrics.ResolveOutsideWindow.WithLabelValues(windowNotYetActive).Inc()
+		} else {
+			s.metrics.ResolveOutsideWindow.WithLabelValues(windowEnded).Inc()
+		}
+		return s.fallback(l, err)
+	}
+	if err := s.checkLinkPassword(ctx, l, req); err != nil {
+		return nil, err
+	}
+	if l.maxClicks > 0 {
+		probe := VisitFrom(ctx) == VisitProbe
+		check := s.takeClick
+		if probe {
+			check = s.checkClicks
+		}
+		if err := check(ctx, l); err != nil {
+			if reason, _ := ErrorReason(err); reason == ReasonClicksExhausted {
+				return s.fallback(l, err)
+			}
+			return nil, err
+		}
+		if probe {
+			// A probe that spent nothing must not learn where a one-time
+			// link goes.
+			return &gen.ResolveResponse{NoStore: true}, nil
+		}
+	}
+	url, rule := s.route(ctx, l, req)
+	return &gen.ResolveResponse{Url: url, Rule: int32(rule), NoStore: l.cacheTTL(s.clock()) == 0}, nil
+}
 
-    return string(encoded)
+// link is what Resolve needs to know about a stored link.
+type link struct {
+	code, url    string
+	passwordHash string
+	// maxClicks is 0 for unlimited links. clicks is the count last
+	// reconciled to Postgres.
+	maxClicks, clicks int32
+	// notBefore and notAfter bound when the link resolves; zero leaves
+	// that side open. expires is the row's TTL, which ends the link like
+	// notAfter does; zero means it never expires.
+	notBefore, notAfter, expires time.Time
+	disabled            bool
+	// fallbackURL is the link's own fallback and ownerFallbackURL its
+	// owner's; either may be empty.
+	fallbackURL, ownerFallbackURL string
+	// rules route visitors to other URLs.
+	rules []routing.Rule
 }
 
-func (s *ShortenerService) Resolve(ctx context.Context, req *gen.ResolveRequest) (*gen.ResolveResponse, error) {
-	code := req.GetCode()
-	
+// cacheable reports whether the link may be cached by URL alone. Cache
+// entries carry nothing else, so protected, click-limited, disabled and
+// routed links stay out and every Resolve of them reaches Postgres.
+func (l link) cacheable() bool {
+	return l.passwordHash == "" && l.maxClicks == 0 && !l.disabled && len(l.rules) == 0
+}
+
+// ends returns when l stops resolving, the earlier of notAfter and expires,
+// or the zero time if neither is set.
+func (l link) ends() time.Time {
+	if l.expires.IsZero() || (!l.notAfter.IsZero() && l.notAfter.Before(l.expires)) {
+		return l.notAfter
+	}
+	return l.expires
+}
+
+// cacheTTL is how long l may be cached at now, or 0 if it must not be. A
+// cache hit skips the window check, so entries expire when the link ends
+// and links are only cached once they have started.
+func (l link) cacheTTL(now time.Time) time.Duration {
+	if !l.cacheable() || now.Before(l.notBefore) {
+		return 0
+	}
+	ttl := linkCacheTTL
+	if end := l.ends(); !end.IsZero() {
+		ttl = min(ttl, end.Sub(now))
+	}
+	if ttl < time.Millisecond {
+		// Redis rounds shorter TTLs down to no expiry at all.
+		return 0
+	}
+	return ttl
+}
+
+// clock returns the current time. Services built as struct literals in
+// tests may leave now unset.
+func (s *ShortenerService) clock() time.Time {
+	if s.now == nil {
+		return time.Now()
+	}
+	return s.now()
+}
+
+// nullTime stores a zero time as NULL.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// lookup returns the link stored under code, reading through the cache.
+func (s *ShortenerService) lookup(ctx context.Context, code string) (link, error) {
 	urlStr, err := s.cache.Get(ctx, code).Result()
     if err == nil {
-        log.Printf("[INFO] Cache hit")
-        ResolveHits.Inc()
-        return &gen.ResolveResponse{Url: urlStr}, nil
+        slog.DebugContext(ctx, "cache hit", logging.KeyCode, code)
+        s.metrics.ResolveHits.Inc()
+        return link{code: code, url: urlStr}, nil
     }
     if err != redis.Nil {
-        ResolveErrors.Inc()
-        return nil, status.Errorf(codes.Internal, "cache lookup failed: %v", err)
+        s.metrics.ResolveErrors.Inc()
+        return link{}, status.Errorf(codes.Unavailable, "cache lookup failed: %v", err)
     }
-    log.Printf("[INFO] Cache miss")
-    ResolveMisses.Inc()
+    slog.DebugContext(ctx, "cache miss", logging.KeyCode, code)
+    s.metrics.ResolveMisses.Inc()
 
-	var dbURL string
-    err = s.dbPool.QueryRow(ctx,
-        `SELECT url FROM links WHERE code = $1`, code,
-    ).Scan(&dbURL)
+	const stmt = `SELECT l.url, COALESCE(l.password_hash, ''), COALESCE(l.max_clicks, 0), l.clicks, l.not_before, l.not_after,
+			l.expires, l.disabled_at IS NOT NULL, COALESCE(l.fallback_url, ''), COALESCE(f.url, ''), l.routing_rules
+		FROM links l LEFT JOIN owner_fallbacks f ON f.owner = l.owner
+		WHERE l.code = $1`
+	l := link{code: code}
+	var notBefore, notAfter, expires *time.Time
+	var rules []byte
+	dbCtx, span := telemetry.StartQuery(ctx, "SELECT", "links", stmt)
+	err = s.dbPool.QueryRow(dbCtx, stmt, code).Scan(&l.url, &l.passwordHash, &l.maxClicks, &l.clicks, &notBefore, &notAfter,
+		&expires, &l.disabled, &l.fallbackURL, &l.ownerFallbackURL, &rules)
+	telemetry.EndQuery(span, err)
     if err != nil {
         if errors.Is(err, pgx.ErrNoRows) {
-            return nil, status.Errorf(codes.NotFound, "code not found: %s", code)
+            return link{}, status.Errorf(codes.NotFound, "code not found: %s", code)
         }
-        return nil, status.Errorf(codes.Internal, "db query failed: %v", err)
+        return link{}, status.Errorf(codes.Internal, "db query failed: %v", err)
     }
+	if notBefore != nil {
+		l.notBefore = *notBefore
+	}
+	if notAfter != nil {
+		l.notAfter = *notAfter
+	}
+	if expires != nil {
+		l.expires = *expires
+	}
+	if rules != nil {
+		if err := json.Unmarshal(rules, &l.rules); err != nil {
+			return link{}, status.Errorf(codes.Internal, "invalid routing rules for %s: %v", code, err)
+		}
+	}
 
-	if err := s.cache.Set(ctx, code, dbURL, 24*time.Hour).Err(); err != nil {
-        log.Printf("warning: redis SET failed: %v", err)
-    }
+	if ttl := l.cacheTTL(s.clock()); ttl > 0 {
+		if err := s.cache.Set(ctx, code, l.url, ttl).Err(); err != nil {
+			slog.WarnContext(ctx, "redis SET failed", logging.KeyCode, code, "error", err)
+		}
+	}
 	
-    return &gen.ResolveResponse{Url: dbURL}, nil
+    return l, nil
 }
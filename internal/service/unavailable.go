@@ -0,0 +1,78 @@
+package service
+
+import (
+	"github.com/JohnBPerkins/url-shortener/gen"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Reasons in the ErrorInfo detail of a Resolve refused although the link
+// exists. The web layer uses them to pick a page.
+const (
+	ReasonNotYetActive    = "LINK_NOT_YET_ACTIVE"
+	ReasonEnded           = "LINK_ENDED"
+	ReasonClicksExhausted = "LINK_CLICKS_EXHAUSTED"
+	ReasonDisabled        = "LINK_DISABLED"
+
+	// ErrorDomain is the ErrorInfo domain of the service's errors.
+	ErrorDomain = "url-shortener"
+	// MetadataNotBefore holds the link's not_before, in RFC 3339, on
+	// ReasonNotYetActive errors.
+	MetadataNotBefore = "not_before"
+)
+
+// Values of the source label on Metrics.ResolveFallbacks.
+const (
+	fallbackLink   = "link"
+	fallbackOwner  = "owner"
+	fallbackServer = "server"
+)
+
+// reasonError attaches an ErrorInfo with reason to st.
+func reasonError(st *status.Status, reason string, metadata map[string]string) error {
+	withInfo, err := st.WithDetails(&errdetails.ErrorInfo{Reason: reason, Domain: ErrorDomain, Metadata: metadata})
+	if err != nil {
+		return st.Err()
+	}
+	return withInfo.Err()
+}
+
+// ErrorReason returns the reason and metadata of the ErrorInfo on a service
+// error, or "" if it has none.
+func ErrorReason(err error) (string, map[string]string) {
+	for _, d := range status.Convert(err).Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && info.GetDomain() == ErrorDomain {
+			return info.GetReason(), info.GetMetadata()
+		}
+	}
+	return "", nil
+}
+
+// checkDisabled fails with NotFound if l was disabled.
+func checkDisabled(l link) error {
+	if !l.disabled {
+		return nil
+	}
+	return reasonError(status.Newf(codes.NotFound, "link %s is disabled", l.code), ReasonDisabled, nil)
+}
+
+// fallback answers a Resolve of l that failed with err, a reason error, with
+// the link's fallback URL, else its owner's, else for links outside their
+// window the server's. Without any it returns err.
+func (s *ShortenerService) fallback(l link, err error) (*gen.ResolveResponse, error) {
+	url, source := l.fallbackURL, fallbackLink
+	if url == "" {
+		url, source = l.ownerFallbackURL, fallbackOwner
+	}
+	if url == "" {
+		if reason, _ := ErrorReason(err); reason == ReasonNotYetActive || reason == ReasonEnded {
+			url, source = s.inactiveURL, fallbackServer
+		}
+	}
+	if url == "" {
+		return nil, err
+	}
+	s.metrics.ResolveFallbacks.WithLabelValues(source).Inc()
+	return &gen.ResolveResponse{Url: url, Fallback: true, NoStore: true}, nil
+}
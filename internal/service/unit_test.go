@@ -1,47 +1,24 @@
 package service
 
 import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
 	"strings"
 	"testing"
-)
-
-func TestEncodeBase62(t *testing.T) {
-	type tc struct {
-		num  uint64
-		want string
-	}
-	tests := []tc{
-		{num: 0, want: strings.Repeat("0", codeLength)},
-		{num: 1, want: strings.Repeat("0", codeLength-1) + "1"},
-		{num: 61, want: strings.Repeat("0", codeLength-1) + string(base62Chars[61])},
-		{num: func() uint64 {
-			var v uint64 = 1
-			for i := 0; i < codeLength-1; i++ {
-				v *= 62
-			}
-			return v
-		}(), want: "10000000"},
-	}
-
-	for _, tc := range tests {
-		got := encodeBase62(tc.num)
-		if got != tc.want {
-			t.Errorf("encodeBase62(%d) = %q; want %q", tc.num, got, tc.want)
-		}
-	}
+	"time"
 
-	oversize := func() uint64 {
-		var v uint64 = 1
-		for i := 0; i < codeLength+1; i++ {
-			v *= 62
-		}
-		return v
-	}()
-	got := encodeBase62(oversize)
-	if len(got) != codeLength {
-		t.Errorf("encodeBase62(oversize) length = %d; want %d", len(got), codeLength)
-	}
-}
+	"github.com/JohnBPerkins/url-shortener/gen"
+	"github.com/JohnBPerkins/url-shortener/internal/routing"
+	"github.com/JohnBPerkins/url-shortener/modules/flake"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
 
 func TestIsValidURL_ASCII(t *testing.T) {
 	// Contains an accented 'á'
@@ -108,4 +85,457 @@ func TestIsValidURL_TooLong(t *testing.T) {
     if isValidURL(tooLong) {
         t.Errorf("expected URL longer than %d to be invalid, got valid", maxURLLength)
     }
-}
\ No newline at end of file
+}
+
+func TestBatchShortenRejectsBatchSize(t *testing.T) {
+    svc := &ShortenerService{metrics: NewMetrics(nil)}
+    for _, n := range []int{0, maxBatchSize + 1} {
+        urls := make([]string, n)
+        for i := range urls {
+            urls[i] = "example.com"
+        }
+        _, err := svc.BatchShorten(context.Background(), &gen.BatchShortenRequest{Urls: urls})
+        if status.Code(err) != codes.InvalidArgument {
+            t.Errorf("BatchShorten(%d URLs) error = %v; want InvalidArgument", n, err)
+        }
+    }
+}
+
+func TestValidateAlias(t *testing.T) {
+    for code, valid := range map[string]bool{
+        "abc":                   true,
+        "A7f3eG9b":              true,
+        "spring-sale_2025":      true,
+        "ab":                    false,
+        strings.Repeat("a", 65): false,
+        "has space":             false,
+        "dot.ted":               false,
+        "api":                   false,
+        "Healthz":               false,
+    } {
+        if err := validateAlias(code); (err == nil) != valid {
+            t.Errorf("validateAlias(%q) = %v; want valid %v", code, err, valid)
+        }
+    }
+}
+
+func TestValidateImport(t *testing.T) {
+    now := time.Now()
+    past := timestamppb.New(now.Add(-time.Hour))
+    hash, err := hashPassword("hunter2")
+    if err != nil {
+        t.Fatalf("hashPassword failed: %v", err)
+    }
+    tests := []struct {
+        link  *gen.ImportLink
+        valid bool
+    }{
+        {&gen.ImportLink{Code: "abc", Url: "example.com"}, true},
+        {&gen.ImportLink{Code: "abc", Url: "example.com", CreatedAt: past, ExpiresAt: timestamppb.New(now.Add(time.Hour))}, true},
+        {&gen.ImportLink{Code: "abc", Url: "not a url"}, false},
+        {&gen.ImportLink{Code: "a/b", Url: "example.com"}, false},
+        {&gen.ImportLink{Code: "abc", Url: "example.com", CreatedAt: timestamppb.New(now.Add(time.Hour))}, false},
+        {&gen.ImportLink{Code: "abc", Url: "example.com", CreatedAt: past, ExpiresAt: past}, false},
+        {&gen.ImportLink{Code: "abc", Url: "example.com", PasswordHash: hash, MaxClicks: 1, Clicks: 1,
+            NotBefore: timestamppb.New(now.Add(-2 * time.Hour)), NotAfter: past, FallbackUrl: "example.org", DisabledAt: past,
+            Rules: []*gen.RoutingRule{{Countries: []string{"DE"}, Url: "example.de"}}}, true},
+        {&gen.ImportLink{Code: "abc", Url: "example.com", PasswordHash: "hunter2"}, false},
+        {&gen.ImportLink{Code: "abc", Url: "example.com", Clicks: -1}, false},
+        {&gen.ImportLink{Code: "abc", Url: "example.com", NotBefore: timestamppb.New(now), NotAfter: past}, false},
+        {&gen.ImportLink{Code: "abc", Url: "example.com", FallbackUrl: "not a url"}, false},
+        {&gen.ImportLink{Code: "abc", Url: "example.com", Rules: []*gen.RoutingRule{{Url: "not a url"}}}, false},
+    }
+    for _, tt := range tests {
+        if err := validateImport(tt.link, now); (err == nil) != tt.valid {
+            t.Errorf("validateImport(%v) = %v; want valid %v", tt.link, err, tt.valid)
+        }
+    }
+}
+
+func TestImportedLinkCacheTTL(t *testing.T) {
+    now := time.Now()
+    tests := []struct {
+        name string
+        link *gen.ImportLink
+        want time.Duration
+    }{
+        {"plain", &gen.ImportLink{Code: "abc", Url: "example.com"}, linkCacheTTL},
+        {"expires soon", &gen.ImportLink{Code: "abc", Url: "example.com", ExpiresAt: timestamppb.New(now.Add(time.Hour))}, time.Hour},
+        {"started window", &gen.ImportLink{Code: "abc", Url: "example.com", NotBefore: timestamppb.New(now.Add(-time.Hour))}, linkCacheTTL},
+        {"window ends soon", &gen.ImportLink{Code: "abc", Url: "example.com", NotAfter: timestamppb.New(now.Add(time.Hour))}, time.Hour},
+        {"protected", &gen.ImportLink{Code: "abc", Url: "example.com", PasswordHash: "$2a$10$..."}, 0},
+        {"click-limited", &gen.ImportLink{Code: "abc", Url: "example.com", MaxClicks: 1}, 0},
+        {"disabled", &gen.ImportLink{Code: "abc", Url: "example.com", DisabledAt: timestamppb.New(now)}, 0},
+        {"routed", &gen.ImportLink{Code: "abc", Url: "example.com", Rules: []*gen.RoutingRule{{Url: "example.de"}}}, 0},
+    }
+    for _, tt := range tests {
+        if got := importedLink(tt.link, now).cacheTTL(now); got != tt.want {
+            t.Errorf("%s: cacheTTL = %v; want %v", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestCodeError(t *testing.T) {
+    tests := []struct {
+        err  error
+        want codes.Code
+    }{
+        {fmt.Errorf("generate ID: %w", flake.ErrClockBackwards), codes.Unavailable},
+        {fmt.Errorf("generate ID: %w", flake.ErrLifetimeExceeded), codes.Internal},
+        {fmt.Errorf("generate ID: %w", flake.ErrMachineIDLost), codes.Unavailable},
+    }
+    for _, tt := range tests {
+        if got := status.Code(codeError(tt.err)); got != tt.want {
+            t.Errorf("codeError(%v) = %s; want %s", tt.err, got, tt.want)
+        }
+    }
+}
+
+func TestPasswordHashing(t *testing.T) {
+    hash, err := hashPassword("hunter2")
+    if err != nil {
+        t.Fatalf("hashPassword failed: %v", err)
+    }
+    if strings.Contains(hash, "hunter2") {
+        t.Fatalf("hash %q contains the password", hash)
+    }
+
+    tests := []struct {
+        hash, password string
+        want           codes.Code
+    }{
+        {"", "", codes.OK},
+        {"", "anything", codes.OK},
+        {hash, "hunter2", codes.OK},
+        {hash, "", codes.Unauthenticated},
+        {hash, "hunter3", codes.PermissionDenied},
+        {"not a hash", "hunter2", codes.Internal},
+    }
+    for _, tt := range tests {
+        if got := status.Code(checkPassword(tt.hash, tt.password)); got != tt.want {
+            t.Errorf("checkPassword(%q, %q) = %s; want %s", tt.hash, tt.password, got, tt.want)
+        }
+    }
+
+    if _, err := hashPassword(strings.Repeat("x", maxPasswordLength+1)); status.Code(err) != codes.InvalidArgument {
+        t.Errorf("hashPassword of %d bytes error = %v; want InvalidArgument", maxPasswordLength+1, err)
+    }
+}
+
+func TestShortenRejectsNegativeMaxClicks(t *testing.T) {
+    svc := &ShortenerService{metrics: NewMetrics(nil)}
+    _, err := svc.Shorten(context.Background(), &gen.ShortenRequest{Url: "example.com", MaxClicks: -1})
+    if status.Code(err) != codes.InvalidArgument {
+        t.Errorf("Shorten(max_clicks=-1) error = %v; want InvalidArgument", err)
+    }
+}
+
+func TestLinkCacheable(t *testing.T) {
+    tests := []struct {
+        l    link
+        want bool
+    }{
+        {link{url: "example.com"}, true},
+        {link{url: "example.com", passwordHash: "$2a$10$..."}, false},
+        {link{url: "example.com", maxClicks: 1}, false},
+    }
+    for _, tt := range tests {
+        if got := tt.l.cacheable(); got != tt.want {
+            t.Errorf("%+v cacheable = %v; want %v", tt.l, got, tt.want)
+        }
+    }
+}
+
+func TestLinkCacheTTL(t *testing.T) {
+    now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+    tests := []struct {
+        name string
+        l    link
+        want time.Duration
+    }{
+        {"no window", link{}, linkCacheTTL},
+        {"protected", link{passwordHash: "$2a$10$..."}, 0},
+        {"not started", link{notBefore: now.Add(time.Minute)}, 0},
+        {"started", link{notBefore: now.Add(-time.Minute)}, linkCacheTTL},
+        {"ends soon", link{notAfter: now.Add(time.Hour)}, time.Hour},
+        {"ends later", link{notAfter: now.Add(48 * time.Hour)}, linkCacheTTL},
+        {"ended", link{notAfter: now}, 0},
+        {"expires soon", link{expires: now.Add(time.Hour)}, time.Hour},
+        {"expires before it ends", link{notAfter: now.Add(2 * time.Hour), expires: now.Add(time.Hour)}, time.Hour},
+        {"expired", link{expires: now.Add(-time.Second)}, 0},
+    }
+    for _, tt := range tests {
+        if got := tt.l.cacheTTL(now); got != tt.want {
+            t.Errorf("%s: cacheTTL = %v; want %v", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestExpiry(t *testing.T) {
+    now := time.Now()
+    tests := []struct {
+        name                string
+        notBefore, notAfter time.Time
+        maxClicks           int32
+        want                time.Time
+    }{
+        {"plain", time.Time{}, time.Time{}, 0, now.Add(defaultLinkTTL)},
+        {"opens in two days", now.Add(48 * time.Hour), time.Time{}, 0, time.Time{}},
+        {"window", now.Add(48 * time.Hour), now.Add(72 * time.Hour), 0, now.Add(72 * time.Hour)},
+        {"click-limited", time.Time{}, time.Time{}, 1, time.Time{}},
+    }
+    for _, tt := range tests {
+        if got := expiry(tt.notBefore, tt.notAfter, tt.maxClicks, now); !got.Equal(tt.want) {
+            t.Errorf("%s: expiry = %v; want %v", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestCheckWindow(t *testing.T) {
+    now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+    tests := []struct {
+        name       string
+        l          link
+        wantReason string
+    }{
+        {"open", link{code: "abc"}, ""},
+        {"active", link{code: "abc", notBefore: now, notAfter: now.Add(time.Hour)}, ""},
+        {"not started", link{code: "abc", notBefore: now.Add(time.Second)}, ReasonNotYetActive},
+        {"ended", link{code: "abc", notAfter: now}, ReasonEnded},
+        {"not expired", link{code: "abc", expires: now.Add(time.Second)}, ""},
+        {"expired", link{code: "abc", expires: now}, ReasonEnded},
+        {"expired before it ends", link{code: "abc", notAfter: now.Add(time.Hour), expires: now.Add(-time.Hour)}, ReasonEnded},
+    }
+    for _, tt := range tests {
+        err := checkWindow(tt.l, now)
+        reason, metadata := ErrorReason(err)
+        if reason != tt.wantReason {
+            t.Errorf("%s: reason = %q; want %q", tt.name, reason, tt.wantReason)
+        }
+        if tt.wantReason == "" {
+            if err != nil {
+                t.Errorf("%s: checkWindow = %v; want nil", tt.name, err)
+            }
+            continue
+        }
+        if status.Code(err) != codes.NotFound {
+            t.Errorf("%s: code = %v; want NotFound", tt.name, status.Code(err))
+        }
+        if tt.wantReason == ReasonNotYetActive && metadata[MetadataNotBefore] != "2030-01-01T12:00:01Z" {
+            t.Errorf("%s: metadata = %v; want not_before 2030-01-01T12:00:01Z", tt.name, metadata)
+        }
+    }
+}
+
+func TestShortenRejectsBadWindow(t *testing.T) {
+    now := time.Now()
+    svc := &ShortenerService{metrics: NewMetrics(nil)}
+    tests := []struct {
+        name                string
+        notBefore, notAfter *timestamppb.Timestamp
+    }{
+        {"ended", nil, timestamppb.New(now.Add(-time.Minute))},
+        {"inverted", timestamppb.New(now.Add(2 * time.Hour)), timestamppb.New(now.Add(time.Hour))},
+        {"empty", timestamppb.New(now.Add(time.Hour)), timestamppb.New(now.Add(time.Hour))},
+        {"invalid", &timestamppb.Timestamp{Nanos: -1}, nil},
+    }
+    for _, tt := range tests {
+        req := &gen.ShortenRequest{Url: "https://example.com", NotBefore: tt.notBefore, NotAfter: tt.notAfter}
+        if _, err := svc.Shorten(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+            t.Errorf("%s: Shorten = %v; want InvalidArgument", tt.name, err)
+        }
+    }
+}
+
+func TestFallback(t *testing.T) {
+    svc := &ShortenerService{metrics: NewMetrics(nil), inactiveURL: "https://example.com/inactive"}
+    ended := checkWindow(link{code: "abc", notAfter: time.Unix(1, 0)}, time.Unix(2, 0))
+    disabled := checkDisabled(link{code: "abc", disabled: true})
+    tests := []struct {
+        name string
+        l    link
+        err  error
+        want string
+    }{
+        {"link", link{fallbackURL: "https://example.com/link", ownerFallbackURL: "https://example.com/owner"}, disabled, "https://example.com/link"},
+        {"owner", link{ownerFallbackURL: "https://example.com/owner"}, disabled, "https://example.com/owner"},
+        {"server for windows", link{}, ended, "https://example.com/inactive"},
+        {"none", link{}, disabled, ""},
+    }
+    for _, tt := range tests {
+        res, err := svc.fallback(tt.l, tt.err)
+        if tt.want == "" {
+            if err != tt.err {
+                t.Errorf("%s: fallback error = %v; want %v", tt.name, err, tt.err)
+            }
+            continue
+        }
+        if err != nil || res.GetUrl() != tt.want || !res.GetFallback() || !res.GetNoStore() {
+            t.Errorf("%s: fallback = %v, %v; want %s", tt.name, res, err, tt.want)
+        }
+    }
+    if reason, _ := ErrorReason(disabled); reason != ReasonDisabled {
+        t.Errorf("disabled reason = %q; want %s", reason, ReasonDisabled)
+    }
+}
+
+func TestShortenRejectsInvalidFallbackURL(t *testing.T) {
+    svc := &ShortenerService{metrics: NewMetrics(nil)}
+    req := &gen.ShortenRequest{Url: "https://example.com", FallbackUrl: "not a url"}
+    if _, err := svc.Shorten(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+        t.Errorf("Shorten = %v; want InvalidArgument", err)
+    }
+}
+
+func TestParseRules(t *testing.T) {
+    req := &gen.ShortenRequest{Rules: []*gen.RoutingRule{
+        {Platforms: []gen.Platform{gen.Platform_PLATFORM_IOS}, Url: "https://apps.apple.com/app/id1"},
+        {Countries: []string{"DE"}, Languages: []string{"de"}, Url: "https://example.de"},
+    }}
+    rules, stored, err := parseRules(req.GetRules())
+    if err != nil {
+        t.Fatalf("parseRules failed: %v", err)
+    }
+    want := `[{"platforms":["ios"],"url":"https://apps.apple.com/app/id1"},{"languages":["de"],"countries":["DE"],"url":"https://example.de"}]`
+    if string(stored) != want || len(rules) != 2 {
+        t.Errorf("parseRules = %v, %s; want %s", rules, stored, want)
+    }
+
+    for _, rule := range []*gen.RoutingRule{
+        {Url: "not a url"},
+        {Platforms: []gen.Platform{gen.Platform_PLATFORM_UNSPECIFIED}, Url: "https://example.com"},
+        {Countries: []string{"Germany"}, Url: "https://example.com"},
+    } {
+        if _, _, err := parseRules([]*gen.RoutingRule{rule}); status.Code(err) != codes.InvalidArgument {
+            t.Errorf("parseRules(%v) = %v; want InvalidArgument", rule, err)
+        }
+    }
+}
+
+type fixedCountry string
+
+func (c fixedCountry) Country(netip.Addr) (string, bool) { return string(c), c != "" }
+
+type recordedRoutes []RouteEvent
+
+func (r *recordedRoutes) RecordRoute(_ context.Context, e RouteEvent) { *r = append(*r, e) }
+
+func TestRoute(t *testing.T) {
+    var events recordedRoutes
+    svc := &ShortenerService{metrics: NewMetrics(nil), geoIP: fixedCountry("FR"), analytics: &events}
+    l := link{code: "app", url: "https://example.com", rules: []routing.Rule{
+        {Platforms: []routing.Platform{routing.IOS}, URL: "https://apps.apple.com/app"},
+        {Countries: []string{"FR"}, URL: "https://example.fr"},
+    }}
+    const iPhone = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X)"
+    tests := []struct {
+        name     string
+        req      *gen.ResolveRequest
+        wantURL  string
+        wantRule int
+    }{
+        {"platform", &gen.ResolveRequest{UserAgent: iPhone}, "https://apps.apple.com/app", 1},
+        {"geoip", &gen.ResolveRequest{ClientIp: "192.0.2.1"}, "https://example.fr", 2},
+        {"caller's country", &gen.ResolveRequest{ClientIp: "192.0.2.1", Country: "de"}, "https://example.com", 0},
+        {"no address", &gen.ResolveRequest{}, "https://example.com", 0},
+    }
+    redirect := WithVisit(context.Background(), VisitRedirect)
+    for _, tt := range tests {
+        url, rule := svc.route(redirect, l, tt.req)
+        if url != tt.wantURL || rule != tt.wantRule {
+            t.Errorf("%s: route = %s, %d; want %s, %d", tt.name, url, rule, tt.wantURL, tt.wantRule)
+        }
+    }
+    if len(events) != len(tests) || events[1].Visitor.Country != "FR" || events[2].Visitor.Country != "DE" {
+        t.Errorf("recorded %+v; want one event per resolve with the visitor's country", events)
+    }
+    if got := testutil.ToFloat64(svc.metrics.ResolveRoutes.WithLabelValues(routeRule)); got != 2 {
+        t.Errorf("resolve_routes_total{matched=rule} = %v; want 2", got)
+    }
+
+    // Links without rules are not routed or recorded.
+    if url, rule := svc.route(redirect, link{url: "https://example.com"}, tests[0].req); url != "https://example.com" || rule != 0 {
+        t.Errorf("route without rules = %s, %d", url, rule)
+    }
+    if len(events) != len(tests) {
+        t.Error("a link without rules was recorded")
+    }
+
+    // Only redirects are recorded: API lookups and probes still route, but
+    // no visitor was sent anywhere.
+    for _, ctx := range []context.Context{context.Background(), WithVisit(context.Background(), VisitProbe)} {
+        if url, _ := svc.route(ctx, l, tests[0].req); url != tests[0].wantURL {
+            t.Errorf("route without a redirect = %s; want %s", url, tests[0].wantURL)
+        }
+    }
+    if len(events) != len(tests) {
+        t.Errorf("recorded %d events; want only the %d redirects", len(events), len(tests))
+    }
+}
+
+func TestAdminAuth(t *testing.T) {
+    auth, err := NewAdminAuth("s3cret")
+    if err != nil {
+        t.Fatalf("NewAdminAuth failed: %v", err)
+    }
+    md, err := auth.GetRequestMetadata(context.Background())
+    if err != nil {
+        t.Fatalf("GetRequestMetadata failed: %v", err)
+    }
+    withToken := metadata.NewIncomingContext(context.Background(), metadata.New(md))
+    wrongToken := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer guess"))
+
+    tests := []struct {
+        name   string
+        ctx    context.Context
+        method string
+        want   codes.Code
+    }{
+        {"public RPC", context.Background(), gen.Shortener_Shorten_FullMethodName, codes.OK},
+        {"no token", context.Background(), gen.Shortener_ExportLinks_FullMethodName, codes.Unauthenticated},
+        {"wrong token", wrongToken, gen.Shortener_ExportLinks_FullMethodName, codes.Unauthenticated},
+        {"token", withToken, gen.Shortener_ExportLinks_FullMethodName, codes.OK},
+        {"import without token", context.Background(), gen.Shortener_ImportLinks_FullMethodName, codes.Unauthenticated},
+        {"import with token", withToken, gen.Shortener_ImportLinks_FullMethodName, codes.OK},
+    }
+    for _, tt := range tests {
+        if got := status.Code(auth.check(tt.ctx, tt.method)); got != tt.want {
+            t.Errorf("%s: check = %s; want %s", tt.name, got, tt.want)
+        }
+    }
+
+    // Without a token, nothing but the AdminAuth itself can call admin RPCs.
+    random, err := NewAdminAuth("")
+    if err != nil {
+        t.Fatalf("NewAdminAuth failed: %v", err)
+    }
+    if err := random.check(metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer ")),
+        gen.Shortener_ExportLinks_FullMethodName); status.Code(err) != codes.Unauthenticated {
+        t.Errorf("check with an empty token = %v; want Unauthenticated", err)
+    }
+}
+
+func TestCallerAddr(t *testing.T) {
+    req := &gen.ResolveRequest{Code: "abc", ClientIp: "198.51.100.7"}
+    fromPeer := func(addr string, md metadata.MD) context.Context {
+        ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(addr), Port: 4242}})
+        return metadata.NewIncomingContext(ctx, md)
+    }
+    forwarded := metadata.Pairs(ClientAddrMetadata, "192.0.2.1", ClientAddrMetadata, "203.0.113.9")
+
+    tests := []struct {
+        name string
+        ctx  context.Context
+        want string
+    }{
+        {"in-process", context.Background(), "198.51.100.7"},
+        {"remote peer", fromPeer("192.0.2.50", forwarded), "192.0.2.50"},
+        {"loopback peer", fromPeer("127.0.0.1", forwarded), "203.0.113.9"},
+        {"loopback peer without metadata", fromPeer("::1", nil), "::1"},
+    }
+    for _, tt := range tests {
+        if got := callerAddr(tt.ctx, req); got != tt.want {
+            t.Errorf("%s: callerAddr = %q; want %q", tt.name, got, tt.want)
+        }
+    }
+}
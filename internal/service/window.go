@@ -0,0 +1,68 @@
+package service
+
+import (
+	"time"
+
+	"github.com/JohnBPerkins/url-shortener/gen"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Values of the reason label on Metrics.ResolveOutsideWindow.
+const (
+	windowNotYetActive = "not_yet_active"
+	windowEnded        = "ended"
+)
+
+// expiry returns when a new link's row expires, or the zero time for never.
+// Plain links get the table's default of a day. Links with a window or a
+// click limit last until not_after instead, or for good if they have none,
+// so the default cannot end them before they open or run out of clicks.
+func expiry(notBefore, notAfter time.Time, maxClicks int32, now time.Time) time.Time {
+	if notBefore.IsZero() && notAfter.IsZero() && maxClicks == 0 {
+		return now.Add(defaultLinkTTL)
+	}
+	return notAfter
+}
+
+// parseWindow validates the activation window of a ShortenRequest. Zero
+// times leave that side of the window open.
+func parseWindow(req *gen.ShortenRequest, now time.Time) (notBefore, notAfter time.Time, err error) {
+	if ts := req.GetNotBefore(); ts != nil {
+		if err := ts.CheckValid(); err != nil {
+			return time.Time{}, time.Time{}, status.Errorf(codes.InvalidArgument, "invalid not_before: %v", err)
+		}
+		notBefore = ts.AsTime()
+	}
+	if ts := req.GetNotAfter(); ts != nil {
+		if err := ts.CheckValid(); err != nil {
+			return time.Time{}, time.Time{}, status.Errorf(codes.InvalidArgument, "invalid not_after: %v", err)
+		}
+		notAfter = ts.AsTime()
+		if !notAfter.After(now) {
+			return time.Time{}, time.Time{}, status.Errorf(codes.InvalidArgument, "not_after %s is in the past", notAfter.Format(time.RFC3339))
+		}
+		if !notBefore.IsZero() && !notAfter.After(notBefore) {
+			return time.Time{}, time.Time{}, status.Errorf(codes.InvalidArgument, "not_after %s is not after not_before %s",
+				notAfter.Format(time.RFC3339), notBefore.Format(time.RFC3339))
+		}
+	}
+	return notBefore, notAfter, nil
+}
+
+// checkWindow fails with NotFound unless l resolves at now. The error
+// carries an ErrorInfo saying whether the link is yet to start or has ended,
+// at not_after or when it expired.
+func checkWindow(l link, now time.Time) error {
+	end := l.ends()
+	switch {
+	case !l.notBefore.IsZero() && now.Before(l.notBefore):
+		notBefore := l.notBefore.UTC().Format(time.RFC3339)
+		return reasonError(status.Newf(codes.NotFound, "link %s is not available until %s", l.code, notBefore),
+			ReasonNotYetActive, map[string]string{MetadataNotBefore: notBefore})
+	case !end.IsZero() && !now.Before(end):
+		return reasonError(status.Newf(codes.NotFound, "link %s ended at %s", l.code, end.UTC().Format(time.RFC3339)),
+			ReasonEnded, nil)
+	}
+	return nil
+}
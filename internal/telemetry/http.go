@@ -0,0 +1,19 @@
+package telemetry
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// HTTPHandler wraps next in a server span that continues any incoming W3C
+// trace context. Spans are named after the mux pattern the request matches
+// ("GET /" for redirects) so short codes don't explode span cardinality.
+func HTTPHandler(mux *http.ServeMux, next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "http",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			_, pattern := mux.Handler(r)
+			return r.Method + " " + pattern
+		}),
+	)
+}
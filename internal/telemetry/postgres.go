@@ -0,0 +1,38 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StartQuery opens a client span for a Postgres statement. pgx v4 has no
+// tracer hook, so callers wrap each query and pass the outcome to
+// EndQuery. The statement text is recorded; it only ever contains
+// placeholders, never bound values.
+func StartQuery(ctx context.Context, operation, table, stmt string) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, "postgres "+operation+" "+table,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNamePostgreSQL,
+			semconv.DBOperationName(operation),
+			semconv.DBCollectionName(table),
+			semconv.DBQueryText(stmt),
+		),
+	)
+}
+
+// EndQuery records err on span and ends it. pgx.ErrNoRows is an expected
+// outcome of a lookup, not a failure.
+func EndQuery(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,70 @@
+package telemetry
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/JohnBPerkins/url-shortener/internal/telemetry"
+
+// RedisHook creates a client span for every Redis command and pipeline. Keys
+// are recorded but values never are, since they are user-supplied URLs.
+type RedisHook struct{}
+
+var _ redis.Hook = RedisHook{}
+
+func (RedisHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	ctx, _ = otel.Tracer(instrumentationName).Start(ctx, "redis "+strings.ToUpper(cmd.Name()),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNameRedis,
+			semconv.DBOperationName(strings.ToUpper(cmd.Name())),
+		),
+	)
+	return ctx, nil
+}
+
+func (RedisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	endSpan(trace.SpanFromContext(ctx), cmd.Err())
+	return nil
+}
+
+func (RedisHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	ctx, _ = otel.Tracer(instrumentationName).Start(ctx, "redis pipeline",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNameRedis,
+			semconv.DBOperationName("PIPELINE"),
+			semconv.DBOperationBatchSize(len(cmds)),
+		),
+	)
+	return ctx, nil
+}
+
+func (RedisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmdErr := cmd.Err(); cmdErr != nil && cmdErr != redis.Nil {
+			err = cmdErr
+			break
+		}
+	}
+	endSpan(trace.SpanFromContext(ctx), err)
+	return nil
+}
+
+// endSpan records err on span and ends it. redis.Nil is a cache miss, not a
+// failure.
+func endSpan(span trace.Span, err error) {
+	if err != nil && err != redis.Nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,68 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	sr := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return sr
+}
+
+func TestRedisHook(t *testing.T) {
+	sr := recordSpans(t)
+	ctx := context.Background()
+	hook := RedisHook{}
+
+	miss := redis.NewStringCmd(ctx, "get", "abc")
+	miss.SetErr(redis.Nil)
+	spanCtx, _ := hook.BeforeProcess(ctx, miss)
+	_ = hook.AfterProcess(spanCtx, miss)
+
+	failed := redis.NewStatusCmd(ctx, "set", "abc", "example.com")
+	failed.SetErr(errors.New("connection refused"))
+	spanCtx, _ = hook.BeforeProcess(ctx, failed)
+	_ = hook.AfterProcess(spanCtx, failed)
+
+	spans := sr.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].Name() != "redis GET" || spans[0].Status().Code == codes.Error {
+		t.Errorf("cache miss span = %q (%v); want non-error \"redis GET\"", spans[0].Name(), spans[0].Status().Code)
+	}
+	if spans[1].Name() != "redis SET" || spans[1].Status().Code != codes.Error {
+		t.Errorf("failed SET span = %q (%v); want error \"redis SET\"", spans[1].Name(), spans[1].Status().Code)
+	}
+}
+
+func TestQuerySpan(t *testing.T) {
+	sr := recordSpans(t)
+
+	_, span := StartQuery(context.Background(), "SELECT", "links", "SELECT url FROM links WHERE code = $1")
+	EndQuery(span, pgx.ErrNoRows)
+
+	spans := sr.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if spans[0].Name() != "postgres SELECT links" {
+		t.Errorf("span name = %q; want %q", spans[0].Name(), "postgres SELECT links")
+	}
+	if spans[0].Status().Code == codes.Error {
+		t.Errorf("ErrNoRows should not mark the span as failed")
+	}
+}
@@ -0,0 +1,80 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"connectrpc.com/connect"
+	pb "github.com/JohnBPerkins/url-shortener/gen"
+	"github.com/JohnBPerkins/url-shortener/gen/genconnect"
+	"github.com/JohnBPerkins/url-shortener/internal/logging"
+	"github.com/JohnBPerkins/url-shortener/internal/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// NewConnectHandler serves the Shortener service over the Connect and
+// gRPC-Web protocols so browsers can call the same public RPCs as backend
+// clients. Like the REST gateway, calls are forwarded to the gRPC server over
+// conn; admin RPCs answer Unimplemented. The returned pattern is the path
+// prefix to mount the handler on.
+func NewConnectHandler(conn *grpc.ClientConn) (string, http.Handler) {
+	return genconnect.NewShortenerHandler(&connectProxy{client: pb.NewShortenerClient(conn)})
+}
+
+// connectProxy adapts the gRPC client to the Connect handler interface.
+// Admin RPCs fall through to the embedded Unimplemented handler.
+type connectProxy struct {
+	genconnect.UnimplementedShortenerHandler
+	client pb.ShortenerClient
+}
+
+func (p *connectProxy) Shorten(ctx context.Context, req *connect.Request[pb.ShortenRequest]) (*connect.Response[pb.ShortenResponse], error) {
+	resp, err := p.client.Shorten(outgoingContext(ctx), req.Msg)
+	if err != nil {
+		return nil, connectError(ctx, err)
+	}
+	return connect.NewResponse(resp), nil
+}
+
+func (p *connectProxy) Resolve(ctx context.Context, req *connect.Request[pb.ResolveRequest]) (*connect.Response[pb.ResolveResponse], error) {
+	// Wrong passwords are counted per visitor, so the gRPC server needs
+	// their address rather than ours.
+	out := metadata.AppendToOutgoingContext(outgoingContext(ctx),
+		service.ClientAddrMetadata, clientIPFrom(req.Header(), req.Peer().Addr))
+	resp, err := p.client.Resolve(out, req.Msg)
+	if err != nil {
+		return nil, connectError(ctx, err)
+	}
+	return connect.NewResponse(resp), nil
+}
+
+func (p *connectProxy) BatchShorten(ctx context.Context, req *connect.Request[pb.BatchShortenRequest]) (*connect.Response[pb.BatchShortenResponse], error) {
+	resp, err := p.client.BatchShorten(outgoingContext(ctx), req.Msg)
+	if err != nil {
+		return nil, connectError(ctx, err)
+	}
+	return connect.NewResponse(resp), nil
+}
+
+// outgoingContext carries the HTTP request's ID over to the gRPC call.
+func outgoingContext(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, strings.ToLower(logging.RequestIDHeader), logging.RequestID(ctx))
+}
+
+// connectError converts a gRPC status error to a Connect error. Connect codes
+// share gRPC's numbering. Server-side failures are masked as in
+// writeStatusError.
+func connectError(ctx context.Context, err error) error {
+	st := status.Convert(err)
+	message := st.Message()
+	if httpStatus := HTTPStatusFromCode(st.Code()); httpStatus >= http.StatusInternalServerError {
+		slog.ErrorContext(ctx, "request failed", logging.KeyStatus, st.Code().String(), "error", err)
+		message = http.StatusText(httpStatus)
+	}
+	return connect.NewError(connect.Code(st.Code()), errors.New(message))
+}
@@ -0,0 +1,39 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/JohnBPerkins/url-shortener/internal/logging"
+	"github.com/rs/cors"
+)
+
+// WithCORS answers preflight requests and sets CORS headers for every route
+// on the public listener. Allowed headers cover the REST API as well as the
+// Connect and gRPC-Web protocols. Without allowed origins, next is returned
+// as is and browsers only call it from its own origin.
+func WithCORS(allowedOrigins []string, next http.Handler) http.Handler {
+	if len(allowedOrigins) == 0 {
+		// The cors package would take an empty list to mean any origin.
+		return next
+	}
+	return cors.New(cors.Options{
+		AllowedOrigins: allowedOrigins,
+		AllowedMethods: []string{http.MethodGet, http.MethodPost},
+		AllowedHeaders: []string{
+			"Content-Type",
+			"Connect-Protocol-Version",
+			"Connect-Timeout-Ms",
+			"Grpc-Timeout",
+			"X-Grpc-Web",
+			"X-User-Agent",
+			logging.RequestIDHeader,
+		},
+		ExposedHeaders: []string{
+			"Grpc-Status",
+			"Grpc-Message",
+			"Grpc-Status-Details-Bin",
+			logging.RequestIDHeader,
+		},
+		MaxAge: 7200,
+	}).Handler(next)
+}
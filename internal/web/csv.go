@@ -0,0 +1,90 @@
+package web
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+
+	pb "github.com/JohnBPerkins/url-shortener/gen"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+)
+
+const mimeCSV = "text/csv"
+
+// bulkRoute is the gateway route csvMarshaler is meant for.
+const bulkRoute = "/api/shorten/bulk"
+
+// csvMarshaler lets the bulk endpoint take and return CSV through the
+// gateway. Requests decode into the list of URLs bound to the request body,
+// read from the first column with an optional "url" header row; only
+// BatchShortenResponse can be encoded.
+type csvMarshaler struct{}
+
+// hasMediaType reports whether any of the header values is mediaType,
+// ignoring parameters such as charset.
+func hasMediaType(values []string, mediaType string) bool {
+	for _, v := range values {
+		if mt, _, err := mime.ParseMediaType(v); err == nil && mt == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+func (csvMarshaler) ContentType(any) string { return mimeCSV + "; charset=utf-8" }
+
+func (m csvMarshaler) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := m.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (m csvMarshaler) Unmarshal(data []byte, v any) error {
+	return m.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func (csvMarshaler) NewDecoder(r io.Reader) runtime.Decoder {
+	return runtime.DecoderFunc(func(v any) error {
+		urls, ok := v.(*[]string)
+		if !ok {
+			return fmt.Errorf("csv: cannot decode into %T", v)
+		}
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		cr.TrimLeadingSpace = true
+		for first := true; ; first = false {
+			record, err := cr.Read()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			u := strings.TrimSpace(record[0])
+			if first && strings.EqualFold(u, "url") {
+				continue
+			}
+			*urls = append(*urls, u)
+		}
+	})
+}
+
+func (csvMarshaler) NewEncoder(w io.Writer) runtime.Encoder {
+	return runtime.EncoderFunc(func(v any) error {
+		resp, ok := v.(*pb.BatchShortenResponse)
+		if !ok {
+			return fmt.Errorf("csv: cannot encode %T", v)
+		}
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"index", "url", "code", "error"})
+		for _, r := range resp.GetResults() {
+			_ = cw.Write([]string{strconv.Itoa(int(r.GetIndex())), r.GetUrl(), r.GetCode(), r.GetError()})
+		}
+		cw.Flush()
+		return cw.Error()
+	})
+}
@@ -0,0 +1,78 @@
+package web
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/JohnBPerkins/url-shortener/internal/logging"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorResponse is the body of every JSON error returned by the HTTP API.
+type ErrorResponse struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// HTTPStatusFromCode maps a gRPC status code to the HTTP status the public
+// API returns for it, following the mapping in google/rpc/code.proto.
+func HTTPStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499 // Client Closed Request
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default: // Unknown, Internal, DataLoss
+		return http.StatusInternalServerError
+	}
+}
+
+// writeStatusError writes err, a gRPC status error from the service, as a
+// JSON error with the mapped HTTP status. Messages of server-side failures
+// are replaced so driver errors never reach clients; the full error is
+// logged against the request ID instead.
+func writeStatusError(w http.ResponseWriter, r *http.Request, err error) {
+	st := status.Convert(err)
+	httpStatus := HTTPStatusFromCode(st.Code())
+	message := st.Message()
+	if httpStatus >= http.StatusInternalServerError {
+		slog.ErrorContext(r.Context(), "request failed", logging.KeyStatus, httpStatus, "error", err)
+		message = http.StatusText(httpStatus)
+	}
+	writeError(w, r, httpStatus, st.Code().String(), message)
+}
+
+// writeError writes an ErrorResponse carrying the request's ID.
+func writeError(w http.ResponseWriter, r *http.Request, httpStatus int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	body := ErrorResponse{
+		Code:      code,
+		Message:   message,
+		RequestID: logging.RequestID(r.Context()),
+	}
+	if encodeErr := json.NewEncoder(w).Encode(body); encodeErr != nil {
+		slog.ErrorContext(r.Context(), "failed to write JSON response", logging.KeyStatus, httpStatus, "error", encodeErr)
+	}
+}
@@ -0,0 +1,189 @@
+package web
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/JohnBPerkins/url-shortener/gen"
+	"github.com/JohnBPerkins/url-shortener/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// exportFlushEvery is how many rows are buffered before the export response
+// is flushed to the client.
+const exportFlushEvery = 1000
+
+// NewExportHandler serves GET /api/links/export on the admin listener by
+// streaming ExportLinks as NDJSON, or as CSV when ?format=csv is given or
+// the client accepts text/csv. The owner, created_after and created_before
+// (RFC 3339) query parameters become the request filters. ExportLinks is an
+// admin RPC, so conn must carry the admin token.
+func NewExportHandler(conn *grpc.ClientConn) http.HandlerFunc {
+	client := pb.NewShortenerClient(conn)
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		req := &pb.ExportLinksRequest{Owner: q.Get("owner")}
+		var err error
+		if req.CreatedAfter, err = parseTimestamp(q.Get("created_after")); err != nil {
+			writeError(w, r, http.StatusBadRequest, codes.InvalidArgument.String(), "created_after: "+err.Error())
+			return
+		}
+		if req.CreatedBefore, err = parseTimestamp(q.Get("created_before")); err != nil {
+			writeError(w, r, http.StatusBadRequest, codes.InvalidArgument.String(), "created_before: "+err.Error())
+			return
+		}
+
+		stream, err := client.ExportLinks(outgoingContext(r.Context()), req)
+		if err != nil {
+			writeStatusError(w, r, err)
+			return
+		}
+		// Wait for the first link so that filter and database errors still
+		// get a proper status code.
+		link, err := stream.Recv()
+		if err != nil && !errors.Is(err, io.EOF) {
+			writeStatusError(w, r, err)
+			return
+		}
+
+		enc := newLinkEncoder(w, wantsCSV(r))
+		w.Header().Set("Content-Type", enc.contentType)
+		rc := http.NewResponseController(w)
+		for n := 1; link != nil; n++ {
+			if err := enc.encode(link); err != nil {
+				slog.WarnContext(r.Context(), "export aborted by client", "error", err)
+				return
+			}
+			if n%exportFlushEvery == 0 {
+				enc.flush()
+				_ = rc.Flush()
+			}
+			link, err = stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				// Headers are gone; abort so the client sees a truncated
+				// response rather than a silently short export.
+				slog.ErrorContext(r.Context(), "export failed mid-stream", logging.KeyStatus, http.StatusInternalServerError, "error", err)
+				panic(http.ErrAbortHandler)
+			}
+		}
+		enc.flush()
+	}
+}
+
+func parseTimestamp(raw string) (*timestamppb.Timestamp, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, err
+	}
+	return timestamppb.New(t), nil
+}
+
+func wantsCSV(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return strings.EqualFold(format, "csv")
+	}
+	return strings.Contains(r.Header.Get("Accept"), mimeCSV)
+}
+
+// linkColumns heads the CSV export. The import subcommand reads the same
+// columns, so an export can be imported as is.
+var linkColumns = []string{
+	"code", "url", "owner", "created_at", "expires_at", "password_hash", "max_clicks", "clicks",
+	"not_before", "not_after", "fallback_url", "disabled_at", "rules",
+}
+
+// formatTimestamp formats ts as RFC 3339, or "" if it is unset.
+func formatTimestamp(ts *timestamppb.Timestamp) string {
+	if ts == nil {
+		return ""
+	}
+	return ts.AsTime().Format(time.RFC3339)
+}
+
+// formatCount formats n, or "" if it is 0.
+func formatCount(n int32) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(int(n))
+}
+
+// marshalRules encodes routing rules as a JSON array for a CSV cell, or ""
+// if there are none.
+func marshalRules(opts protojson.MarshalOptions, rules []*pb.RoutingRule) (string, error) {
+	if len(rules) == 0 {
+		return "", nil
+	}
+	parts := make([]string, len(rules))
+	for i, r := range rules {
+		b, err := opts.Marshal(r)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = string(b)
+	}
+	return "[" + strings.Join(parts, ",") + "]", nil
+}
+
+// linkEncoder writes links as NDJSON or CSV rows.
+type linkEncoder struct {
+	contentType string
+	encode      func(*pb.Link) error
+	flush       func()
+}
+
+func newLinkEncoder(w io.Writer, asCSV bool) *linkEncoder {
+	opts := protojson.MarshalOptions{UseProtoNames: true}
+	if asCSV {
+		cw := csv.NewWriter(w)
+		_ = cw.Write(linkColumns)
+		return &linkEncoder{
+			contentType: mimeCSV + "; charset=utf-8",
+			encode: func(l *pb.Link) error {
+				rules, err := marshalRules(opts, l.GetRules())
+				if err != nil {
+					return err
+				}
+				if err := cw.Write([]string{
+					l.GetCode(), l.GetUrl(), l.GetOwner(),
+					formatTimestamp(l.GetCreatedAt()), formatTimestamp(l.GetExpiresAt()),
+					l.GetPasswordHash(), formatCount(l.GetMaxClicks()), formatCount(l.GetClicks()),
+					formatTimestamp(l.GetNotBefore()), formatTimestamp(l.GetNotAfter()),
+					l.GetFallbackUrl(), formatTimestamp(l.GetDisabledAt()), rules,
+				}); err != nil {
+					return err
+				}
+				return cw.Error()
+			},
+			flush: cw.Flush,
+		}
+	}
+
+	return &linkEncoder{
+		contentType: "application/x-ndjson",
+		encode: func(l *pb.Link) error {
+			b, err := opts.Marshal(l)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(append(b, '\n'))
+			return err
+		},
+		flush: func() {},
+	}
+}
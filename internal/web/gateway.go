@@ -0,0 +1,89 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	pb "github.com/JohnBPerkins/url-shortener/gen"
+	"github.com/JohnBPerkins/url-shortener/internal/logging"
+	"github.com/JohnBPerkins/url-shortener/internal/service"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// NewGateway returns a JSON/REST handler for every RPC carrying a
+// google.api.http annotation in proto/shortener.proto. Calls are forwarded to
+// the gRPC server over conn, so they go through the same interceptors as
+// native gRPC clients and new RPCs are exposed without touching this package.
+func NewGateway(ctx context.Context, conn *grpc.ClientConn) (http.Handler, error) {
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions:   protojson.MarshalOptions{UseProtoNames: true},
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		}),
+		runtime.WithMarshalerOption(mimeCSV, csvMarshaler{}),
+		runtime.WithErrorHandler(func(_ context.Context, _ *runtime.ServeMux, _ runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+			writeStatusError(w, r, err)
+		}),
+		runtime.WithMetadata(func(ctx context.Context, r *http.Request) metadata.MD {
+			return metadata.Pairs(
+				strings.ToLower(logging.RequestIDHeader), logging.RequestID(ctx),
+				service.ClientAddrMetadata, clientIP(r),
+			)
+		}),
+		// WithRequestLogging already set X-Request-ID on the response.
+		runtime.WithOutgoingHeaderMatcher(func(key string) (string, bool) {
+			if strings.EqualFold(key, logging.RequestIDHeader) {
+				return "", false
+			}
+			return runtime.DefaultHeaderMatcher(key)
+		}),
+		runtime.WithMiddlewares(func(next runtime.HandlerFunc) runtime.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+				var route string
+				if pattern, ok := runtime.HTTPPattern(r.Context()); ok {
+					route = pattern.String()
+					setRoute(r.Context(), route)
+				}
+				// Only the bulk endpoint speaks CSV; csvMarshaler cannot
+				// encode any other message.
+				if route != bulkRoute {
+					if hasMediaType(r.Header.Values("Accept"), mimeCSV) {
+						writeError(w, r, http.StatusNotAcceptable, codes.InvalidArgument.String(),
+							"text/csv is only served by "+bulkRoute)
+						return
+					}
+					if hasMediaType(r.Header.Values("Content-Type"), mimeCSV) {
+						writeError(w, r, http.StatusUnsupportedMediaType, codes.InvalidArgument.String(),
+							"text/csv is only accepted by "+bulkRoute)
+						return
+					}
+				}
+				// URLs end up in access logs, proxies and browser history.
+				if r.URL.Query().Has("password") {
+					writeError(w, r, http.StatusBadRequest, codes.InvalidArgument.String(),
+						"password must be sent in the request body, not the URL")
+					return
+				}
+				next(w, r, pathParams)
+			}
+		}),
+	)
+	if err := pb.RegisterShortenerHandlerClient(ctx, mux, pb.NewShortenerClient(conn)); err != nil {
+		return nil, err
+	}
+	return mux, nil
+}
+
+// NewOpenAPIHandler serves the OpenAPI v2 document generated alongside the
+// gateway.
+func NewOpenAPIHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(pb.OpenAPISpec)
+	}
+}
@@ -1,100 +1,163 @@
 package web
 
 import (
-	"encoding/json"
-	"log"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 
 	pb "github.com/JohnBPerkins/url-shortener/gen"
+	"github.com/JohnBPerkins/url-shortener/internal/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-type ShortenRequest struct {
-	URL string `json:"url"`
-}
-
-type ShortenResponse struct {
-	Code string `json:"code"`
-}
-
-type ResolveRequest struct {
-	Code string `json:"code"`
-}
-
-type ResolveResponse struct {
-	URL string `json:"url"`
-}
-
-type ErrorResponse struct {
-	Error string `json:"error"`
-}
-
-func NewShrinkHandler(svc pb.ShortenerServer) http.HandlerFunc {
+// NewResolveHandler serves the public redirect, GET /{code}. The JSON API,
+// including a non-redirecting resolve at /api/links/{code}, is served by
+// NewGateway.
+//
+// Password-protected links get an HTML form instead, which POSTs back to
+// /{code}. A correct password redirects and is remembered in a cookie
+// sealed by cookies. Unknown codes, and links that cannot resolve and have
+// no fallback URL, get one of pages rather than a JSON error.
+//
+// The visitor's User-Agent, Accept-Language and address go with every
+// Resolve, for links with routing rules. Only redirects of visitors spend a
+// click of a click-limited link: HEAD requests and link preview bots do not,
+// and get 204 without the URL.
+func NewResolveHandler(svc pb.ShortenerServer, cookies *PasswordCookies, pages *Pages) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("→  HTTP %s %s\n", r.Method, r.URL.Path)
+		code := r.URL.Path[1:] // Strip leading "/"
 
-		w.Header().Set("Content-Type", "application/json")
-		
-		if r.Method != http.MethodPost {
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			if encodeErr := json.NewEncoder(w).Encode(ErrorResponse{Error: "Method not allowed"}); encodeErr != nil {
-				log.Printf("handlers.go: failed to write 405 JSON: %v", encodeErr)
-			}
+		if code == "" {
+			pages.writeNotFound(w, r, code)
 			return
 		}
 
-		var req ShortenRequest
-		if decodeErr := json.NewDecoder(r.Body).Decode(&req); decodeErr != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			if encodeErr := json.NewEncoder(w).Encode(ErrorResponse{Error: "Invalid JSON"}); encodeErr != nil {
-				log.Printf("handlers.go: failed to write 400 JSON: %v", encodeErr)
+		var password string
+		fromForm := r.Method == http.MethodPost
+		if fromForm {
+			var err error
+			if password, err = readPassword(w, r); err != nil {
+				writePasswordForm(w, r, http.StatusBadRequest, code, "Enter the password.")
+				return
 			}
-			return
+		} else {
+			password, _ = cookies.open(r, code)
 		}
 
-		if req.URL == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			if encodeErr := json.NewEncoder(w).Encode(ErrorResponse{Error: "URL is required"}); encodeErr != nil {
-				log.Printf("handlers.go: failed to write 400 JSON: %v", encodeErr)
+		grpcReq := &pb.ResolveRequest{
+			Code:           code,
+			Password:       password,
+			UserAgent:      r.UserAgent(),
+			AcceptLanguage: r.Header.Get("Accept-Language"),
+			ClientIp:       clientIP(r),
+		}
+		// HEAD requests and link previews must not use up a click-limited
+		// link's clicks before a visitor gets to it.
+		visit := service.VisitRedirect
+		if r.Method == http.MethodHead || isLinkPreview(r.UserAgent()) {
+			visit = service.VisitProbe
+		}
+		grpcResp, err := svc.Resolve(service.WithVisit(r.Context(), visit), grpcReq)
+		switch status.Code(err) {
+		case codes.OK:
+		case codes.Unauthenticated:
+			writePasswordForm(w, r, http.StatusUnauthorized, code, "")
+			return
+		case codes.PermissionDenied:
+			if fromForm {
+				writePasswordForm(w, r, http.StatusForbidden, code, "Incorrect password.")
+				return
 			}
+			// The password changed since the cookie was set.
+			cookies.clear(w, r, code)
+			writePasswordForm(w, r, http.StatusUnauthorized, code, "")
 			return
-		}
-
-        grpcReq := &pb.ShortenRequest{Url: req.URL}
-        grpcResp, err := svc.Shorten(r.Context(), grpcReq)
-        if err != nil {
-            w.WriteHeader(http.StatusInternalServerError)
-            if encodeErr := json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error()}); encodeErr != nil {
-				log.Printf("handlers.go: failed to write 500 JSON: %v", encodeErr)
+		case codes.ResourceExhausted:
+			// Too many wrong passwords were tried for this link.
+			writePasswordForm(w, r, http.StatusTooManyRequests, code, "Too many incorrect passwords. Try again later.")
+			return
+		case codes.NotFound:
+			switch reason, metadata := service.ErrorReason(err); reason {
+			case service.ReasonNotYetActive:
+				notBefore, _ := time.Parse(time.RFC3339, metadata[service.MetadataNotBefore])
+				pages.writeNotYetActive(w, r, code, notBefore)
+			case service.ReasonEnded, service.ReasonClicksExhausted, service.ReasonDisabled:
+				pages.writeGone(w, r, code)
+			default:
+				pages.writeNotFound(w, r, code)
 			}
-            return
-        }
+			return
+		default:
+			writeStatusError(w, r, err)
+			return
+		}
 
-        w.WriteHeader(http.StatusOK)
-        encodeErr := json.NewEncoder(w).Encode(ShortenResponse{
-            Code: grpcResp.GetCode(),
-        })
-		if encodeErr != nil {
-			log.Printf("handlers.go: failed to write 200 JSON: %v", encodeErr)
+		if grpcResp.GetUrl() == "" {
+			// A probe of a click-limited link: it resolves, but where to
+			// is only told to visitors who spend a click.
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if password == "" {
+			http.Redirect(w, r, grpcResp.GetUrl(), http.StatusFound)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		if fromForm {
+			cookies.set(w, r, code, password)
+			http.Redirect(w, r, grpcResp.GetUrl(), http.StatusSeeOther)
+			return
 		}
+		http.Redirect(w, r, grpcResp.GetUrl(), http.StatusFound)
 	}
 }
 
-func NewResolveHandler(svc pb.ShortenerServer) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		code := r.URL.Path[1:] // Strip leading "/"
+// linkPreviewAgents are User-Agent fragments of the bots that chat apps and
+// social networks send to unfurl a link as soon as it is posted.
+var linkPreviewAgents = []string{
+	"Slackbot-LinkExpanding",
+	"facebookexternalhit",
+	"Twitterbot",
+	"LinkedInBot",
+	"Discordbot",
+	"TelegramBot",
+	"WhatsApp",
+	"SkypeUriPreview",
+}
 
-		if code == "" {
-			http.Error(w, "Not Found", http.StatusNotFound)
-			return
+// isLinkPreview reports whether userAgent is a link preview bot rather than
+// someone following the link.
+func isLinkPreview(userAgent string) bool {
+	for _, agent := range linkPreviewAgents {
+		if strings.Contains(userAgent, agent) {
+			return true
 		}
+	}
+	return false
+}
 
-		grpcReq := &pb.ResolveRequest{Code: code}
-		grpcResp, err := svc.Resolve(r.Context(), grpcReq)
-		if err != nil {
-			http.NotFound(w, r)
-			return
-		}
+// clientIP returns the visitor's address: the last X-Forwarded-For entry,
+// which the load balancer in front of us appends, or else the peer. Earlier
+// entries come from the client and could be anything.
+func clientIP(r *http.Request) string {
+	return clientIPFrom(r.Header, r.RemoteAddr)
+}
 
-		http.Redirect(w, r, grpcResp.GetUrl(), http.StatusFound)
+// clientIPFrom is clientIP for handlers that only have the headers and peer
+// address, such as Connect's.
+func clientIPFrom(header http.Header, remoteAddr string) string {
+	if xff := header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(xff[len(xff)-1], ",")
+		if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+			return ip
+		}
 	}
-}
\ No newline at end of file
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
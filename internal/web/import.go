@@ -0,0 +1,53 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	pb "github.com/JohnBPerkins/url-shortener/gen"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// maxImportBody matches the gRPC server's default 4 MB message limit, which
+// the request has to fit through anyway.
+const maxImportBody = 4 << 20
+
+// NewImportHandler serves POST /api/links/import on the admin listener. The
+// body is an ImportLinksRequest as JSON and the response the
+// ImportLinksResponse. ImportLinks is an admin RPC, so conn must carry the
+// admin token.
+func NewImportHandler(conn *grpc.ClientConn) http.HandlerFunc {
+	client := pb.NewShortenerClient(conn)
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBody))
+		if err != nil {
+			if maxErr := (*http.MaxBytesError)(nil); errors.As(err, &maxErr) {
+				writeError(w, r, http.StatusRequestEntityTooLarge, codes.ResourceExhausted.String(), "request body too large")
+				return
+			}
+			writeError(w, r, http.StatusBadRequest, codes.InvalidArgument.String(), err.Error())
+			return
+		}
+		req := &pb.ImportLinksRequest{}
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(body, req); err != nil {
+			writeError(w, r, http.StatusBadRequest, codes.InvalidArgument.String(), err.Error())
+			return
+		}
+
+		resp, err := client.ImportLinks(outgoingContext(r.Context()), req)
+		if err != nil {
+			writeStatusError(w, r, err)
+			return
+		}
+		b, err := (protojson.MarshalOptions{UseProtoNames: true}).Marshal(resp)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, codes.Internal.String(), http.StatusText(http.StatusInternalServerError))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(b)
+	}
+}
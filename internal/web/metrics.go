@@ -0,0 +1,85 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HTTPMetrics holds the per-route request collectors for the public mux.
+type HTTPMetrics struct {
+	Requests *prometheus.CounterVec
+	Duration *prometheus.HistogramVec
+}
+
+// NewHTTPMetrics creates the HTTP collectors and registers them on reg. A nil
+// reg leaves them unregistered.
+func NewHTTPMetrics(reg prometheus.Registerer) *HTTPMetrics {
+	m := &HTTPMetrics{
+		Requests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "url_shortener",
+				Name:      "http_requests_total",
+				Help:      "Total number of HTTP requests by route, method and status code.",
+			},
+			[]string{"route", "method", "status"},
+		),
+		Duration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: "url_shortener",
+				Name:      "http_request_duration_seconds",
+				Help:      "Histogram of HTTP request latencies by route and method.",
+				Buckets:   prometheus.DefBuckets,
+			},
+			[]string{"route", "method"},
+		),
+	}
+	if reg != nil {
+		reg.MustRegister(m.Requests, m.Duration)
+	}
+	return m
+}
+
+// knownMethods bounds the method label; anything else is reported as OTHER.
+var knownMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+type routeKey struct{}
+
+// setRoute lets a nested router, such as the gateway, report a more specific
+// route label than the pattern it was mounted under.
+func setRoute(ctx context.Context, route string) {
+	if holder, ok := ctx.Value(routeKey{}).(*string); ok {
+		*holder = route
+	}
+}
+
+// Middleware records Requests and Duration for every request. The route
+// label is the mux pattern the request matched, so every redirect is counted
+// under "/" rather than under its short code.
+func (m *HTTPMetrics) Middleware(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, route := mux.Handler(r)
+		method := r.Method
+		if !knownMethods[method] {
+			method = "OTHER"
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), routeKey{}, &route)))
+
+		m.Duration.WithLabelValues(route, method).Observe(time.Since(start).Seconds())
+		m.Requests.WithLabelValues(route, method, strconv.Itoa(rec.status)).Inc()
+	})
+}
@@ -0,0 +1,52 @@
+package web
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/JohnBPerkins/url-shortener/internal/logging"
+)
+
+// statusRecorder captures the status code written by the wrapped handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// WithRequestLogging propagates X-Request-ID (generating one if absent),
+// echoes it on the response and writes one access log line per request.
+func WithRequestLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(logging.RequestIDHeader)
+		if !logging.ValidRequestID(id) {
+			id = logging.NewRequestID()
+		}
+		w.Header().Set(logging.RequestIDHeader, id)
+		ctx := logging.WithRequestID(r.Context(), id)
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		level := slog.LevelInfo
+		if rec.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.Log(ctx, level, "http request",
+			logging.KeyMethod, r.Method,
+			"path", r.URL.Path,
+			logging.KeyStatus, rec.status,
+			logging.KeyLatency, time.Since(start),
+		)
+	})
+}
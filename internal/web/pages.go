@@ -0,0 +1,134 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+// PageFiles names HTML template files that replace the built-in pages of
+// the redirect endpoint, to carry an organisation's branding. Empty names
+// keep the built-in page. Every page is executed with .Code.
+type PageFiles struct {
+	// NotYetActive is served for links whose activation window has not
+	// started, with .NotBefore, a time.Time that is zero if unknown.
+	NotYetActive string
+	// NotFound is served with status 404 for codes that do not exist.
+	NotFound string
+	// Gone is served with status 410 for links that ended, ran out of
+	// clicks or were disabled, and have no fallback URL.
+	Gone string
+}
+
+// Pages are the HTML pages the redirect endpoint serves in place of JSON
+// errors, since its visitors are people following a link.
+type Pages struct {
+	notYetActive, notFound, gone *template.Template
+}
+
+// NewPages parses the templates in files, falling back to the built-in
+// pages.
+func NewPages(files PageFiles) (*Pages, error) {
+	p := &Pages{}
+	for _, page := range []struct {
+		dst     **template.Template
+		path    string
+		builtin *template.Template
+	}{
+		{&p.notYetActive, files.NotYetActive, notYetActivePage},
+		{&p.notFound, files.NotFound, notFoundPage},
+		{&p.gone, files.Gone, gonePage},
+	} {
+		t, err := loadPage(page.path, page.builtin)
+		if err != nil {
+			return nil, err
+		}
+		*page.dst = t
+	}
+	return p, nil
+}
+
+func loadPage(path string, builtin *template.Template) (*template.Template, error) {
+	if path == "" {
+		return builtin, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t, err := template.New(path).Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return t, nil
+}
+
+// writeNotYetActive serves the page for a link that opens at notBefore. It
+// is not cached, so visitors returning after the launch are redirected.
+func (p *Pages) writeNotYetActive(w http.ResponseWriter, r *http.Request, code string, notBefore time.Time) {
+	data := struct {
+		Code      string
+		NotBefore time.Time
+	}{code, notBefore}
+	writePage(w, r, p.notYetActive, http.StatusNotFound, data)
+}
+
+// writeNotFound serves the page for a code that does not exist.
+func (p *Pages) writeNotFound(w http.ResponseWriter, r *http.Request, code string) {
+	writePage(w, r, p.notFound, http.StatusNotFound, struct{ Code string }{code})
+}
+
+// writeGone serves the page for a link that no longer resolves.
+func (p *Pages) writeGone(w http.ResponseWriter, r *http.Request, code string) {
+	writePage(w, r, p.gone, http.StatusGone, struct{ Code string }{code})
+}
+
+func writePage(w http.ResponseWriter, r *http.Request, t *template.Template, httpStatus int, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(httpStatus)
+	if err := t.Execute(w, data); err != nil {
+		slog.WarnContext(r.Context(), "failed to write page", "status", httpStatus, "error", err)
+	}
+}
+
+// pageLayout wraps the built-in pages, which define "title" and "body".
+var pageLayout = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{template "title" .}}</title>
+<style>
+body { font-family: system-ui, sans-serif; display: flex; justify-content: center; margin-top: 15vh; }
+main { width: 24rem; }
+</style>
+</head>
+<body>
+<main>
+<h1>{{template "title" .}}</h1>
+{{template "body" .}}
+</main>
+</body>
+</html>
+`))
+
+func builtinPage(content string) *template.Template {
+	return template.Must(template.Must(pageLayout.Clone()).Parse(content))
+}
+
+var (
+	notYetActivePage = builtinPage(`{{define "title"}}Not available yet{{end}}
+{{define "body"}}<p>This link is not live yet.{{if not .NotBefore.IsZero}} It opens on
+<time datetime="{{.NotBefore.Format "2006-01-02T15:04:05Z07:00"}}">{{.NotBefore.Format "2 January 2006 at 15:04 MST"}}</time>.{{end}}</p>{{end}}`)
+
+	notFoundPage = builtinPage(`{{define "title"}}Link not found{{end}}
+{{define "body"}}<p>There is no link at this address. Check that it was typed or scanned correctly.</p>{{end}}`)
+
+	gonePage = builtinPage(`{{define "title"}}Link no longer available{{end}}
+{{define "body"}}<p>This link has expired or been turned off by its owner.</p>{{end}}`)
+)
@@ -0,0 +1,165 @@
+package web
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// passwordCookie is the cookie that remembers the password of a protected
+// link. Its path is the link's own, so each link gets its own cookie.
+const passwordCookie = "link_password"
+
+// maxPasswordForm bounds the body of a password form POST.
+const maxPasswordForm = 4 << 10
+
+// PasswordCookies seals the password a visitor typed for a protected link
+// into a short-lived cookie, so they are not asked again on every visit. The
+// cookie is encrypted and authenticated with AES-GCM: visitors cannot forge
+// one or move it to another link, and the service still checks the password
+// on every redirect rather than trusting the web layer.
+type PasswordCookies struct {
+	aead cipher.AEAD
+	ttl  time.Duration
+	now  func() time.Time
+}
+
+// NewPasswordCookies derives the cookie key from secret. An empty secret
+// uses a random key, so cookies do not survive a restart or work across
+// replicas.
+func NewPasswordCookies(secret string, ttl time.Duration) (*PasswordCookies, error) {
+	var key [32]byte
+	if secret == "" {
+		if _, err := rand.Read(key[:]); err != nil {
+			return nil, err
+		}
+	} else {
+		key = sha256.Sum256([]byte(secret))
+	}
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &PasswordCookies{aead: aead, ttl: ttl, now: time.Now}, nil
+}
+
+// set stores password for code. The expiry is sealed in with it, since
+// clients may ignore Max-Age.
+func (c *PasswordCookies) set(w http.ResponseWriter, r *http.Request, code, password string) {
+	plaintext := binary.BigEndian.AppendUint64(nil, uint64(c.now().Add(c.ttl).Unix()))
+	plaintext = append(plaintext, password...)
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		slog.WarnContext(r.Context(), "failed to set password cookie", "error", err)
+		return
+	}
+	sealed := c.aead.Seal(nonce, nonce, plaintext, []byte(code))
+	http.SetCookie(w, c.cookie(r, code, base64.RawURLEncoding.EncodeToString(sealed), int(c.ttl.Seconds())))
+}
+
+// open returns the password stored for code, if the request carries a
+// valid, unexpired cookie.
+func (c *PasswordCookies) open(r *http.Request, code string) (string, bool) {
+	cookie, err := r.Cookie(passwordCookie)
+	if err != nil {
+		return "", false
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", false
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, []byte(code))
+	if err != nil || len(plaintext) < 8 {
+		return "", false
+	}
+	if expiry := int64(binary.BigEndian.Uint64(plaintext)); c.now().Unix() >= expiry {
+		return "", false
+	}
+	return string(plaintext[8:]), true
+}
+
+// clear deletes the cookie for code, once its password no longer works.
+func (c *PasswordCookies) clear(w http.ResponseWriter, r *http.Request, code string) {
+	http.SetCookie(w, c.cookie(r, code, "", -1))
+}
+
+func (c *PasswordCookies) cookie(r *http.Request, code, value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     passwordCookie,
+		Value:    value,
+		Path:     "/" + code,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// isHTTPS reports whether the visitor reached us over HTTPS, directly or
+// through a TLS-terminating load balancer.
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// readPassword reads the password field of a form POST.
+func readPassword(w http.ResponseWriter, r *http.Request) (string, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxPasswordForm)
+	if err := r.ParseForm(); err != nil {
+		return "", err
+	}
+	password := r.PostForm.Get("password")
+	if password == "" {
+		return "", errors.New("password is empty")
+	}
+	return password, nil
+}
+
+var passwordForm = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Password required</title>
+<style>
+body { font-family: system-ui, sans-serif; display: flex; justify-content: center; margin-top: 15vh; }
+form { display: flex; flex-direction: column; gap: 0.75rem; width: 18rem; }
+.error { color: #b00020; margin: 0; }
+</style>
+</head>
+<body>
+<form method="post" action="/{{.Code}}">
+<h1>Password required</h1>
+<p>This link is protected. Enter its password to continue.</p>
+{{if .Error}}<p class="error" role="alert">{{.Error}}</p>{{end}}
+<input type="password" name="password" aria-label="Password" autocomplete="current-password" required autofocus>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// writePasswordForm serves the form that asks for a link's password.
+func writePasswordForm(w http.ResponseWriter, r *http.Request, httpStatus int, code, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(httpStatus)
+	data := struct{ Code, Error string }{code, message}
+	if err := passwordForm.Execute(w, data); err != nil {
+		slog.WarnContext(r.Context(), "failed to write password form", "error", err)
+	}
+}
@@ -0,0 +1,67 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// RateLimiter allows each client address a number of requests per fixed
+// window. Counts are kept in memory, so every replica limits on its own.
+type RateLimiter struct {
+	limit  int
+	window time.Duration
+	now    func() time.Time
+
+	mu     sync.Mutex
+	start  time.Time
+	counts map[string]int
+}
+
+// NewRateLimiter allows limit requests per window from each client. A limit
+// of 0 or less allows everything.
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{limit: limit, window: window, now: time.Now, counts: make(map[string]int)}
+}
+
+// allow counts a request from key and reports whether it is within the
+// limit, and if not how long until the window resets.
+func (l *RateLimiter) allow(key string) (bool, time.Duration) {
+	if l.limit <= 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Sub(l.start) >= l.window {
+		// Starting over each window keeps memory bounded by the clients
+		// seen in one window.
+		l.start = now
+		clear(l.counts)
+	}
+	if l.counts[key] >= l.limit {
+		return false, l.start.Add(l.window).Sub(now)
+	}
+	l.counts[key]++
+	return true, 0
+}
+
+// Limit rejects requests over the limit with 429 Too Many Requests and a
+// Retry-After header. Clients are told apart by clientIP.
+func (l *RateLimiter) Limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, retryAfter := l.allow(clientIP(r))
+		if !ok {
+			seconds := int(retryAfter.Round(time.Second) / time.Second)
+			w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+			writeError(w, r, http.StatusTooManyRequests, codes.ResourceExhausted.String(),
+				fmt.Sprintf("rate limit of %d requests per %s exceeded", l.limit, l.window))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,928 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/JohnBPerkins/url-shortener/gen/genconnect"
+
+	pb "github.com/JohnBPerkins/url-shortener/gen"
+	"github.com/JohnBPerkins/url-shortener/internal/logging"
+	"github.com/JohnBPerkins/url-shortener/internal/service"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestHTTPMetricsLabelsByRoute(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/shorten", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://example.com", http.StatusFound)
+	})
+	m := NewHTTPMetrics(prometheus.NewRegistry())
+	handler := m.Middleware(mux, mux)
+
+	for _, code := range []string{"/abc12345", "/xyz98765"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, code, nil))
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/shorten", nil))
+
+	if got := testutil.ToFloat64(m.Requests.WithLabelValues("/", http.MethodGet, "302")); got != 2 {
+		t.Errorf("redirects counted under / = %v; want 2", got)
+	}
+	if got := testutil.ToFloat64(m.Requests.WithLabelValues("/api/shorten", http.MethodPost, "400")); got != 1 {
+		t.Errorf("bad shorten requests = %v; want 1", got)
+	}
+	if got := testutil.CollectAndCount(m.Requests); got != 2 {
+		t.Errorf("distinct series = %d; want 2", got)
+	}
+}
+
+func TestHTTPStatusFromCode(t *testing.T) {
+	tests := []struct {
+		code codes.Code
+		want int
+	}{
+		{codes.OK, http.StatusOK},
+		{codes.InvalidArgument, http.StatusBadRequest},
+		{codes.NotFound, http.StatusNotFound},
+		{codes.AlreadyExists, http.StatusConflict},
+		{codes.PermissionDenied, http.StatusForbidden},
+		{codes.Unauthenticated, http.StatusUnauthorized},
+		{codes.ResourceExhausted, http.StatusTooManyRequests},
+		{codes.Unavailable, http.StatusServiceUnavailable},
+		{codes.DeadlineExceeded, http.StatusGatewayTimeout},
+		{codes.Internal, http.StatusInternalServerError},
+		{codes.Unknown, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		if got := HTTPStatusFromCode(tt.code); got != tt.want {
+			t.Errorf("HTTPStatusFromCode(%v) = %d; want %d", tt.code, got, tt.want)
+		}
+	}
+}
+
+// stubShortener returns a fixed error from every RPC, or canned responses
+// when err is nil.
+type stubShortener struct {
+	pb.UnimplementedShortenerServer
+	err error
+}
+
+func (s stubShortener) Shorten(_ context.Context, req *pb.ShortenRequest) (*pb.ShortenResponse, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &pb.ShortenResponse{Code: "0000abcd"}, nil
+}
+
+func (s stubShortener) Resolve(ctx context.Context, req *pb.ResolveRequest) (*pb.ResolveResponse, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	// "locked" stands for a link protected by the password "hunter2", and
+	// "throttled" for a protected link that saw too many wrong passwords.
+	if req.GetCode() == "throttled" {
+		if req.GetPassword() == "" {
+			return nil, status.Error(codes.Unauthenticated, "link is password protected")
+		}
+		return nil, status.Error(codes.ResourceExhausted, "too many incorrect passwords for throttled; try again later")
+	}
+	if req.GetCode() == "locked" {
+		switch req.GetPassword() {
+		case "":
+			return nil, status.Error(codes.Unauthenticated, "link is password protected")
+		case "hunter2":
+		default:
+			return nil, status.Error(codes.PermissionDenied, "incorrect password")
+		}
+	}
+	switch req.GetCode() {
+	case "soon": // opens at soonStart
+		return nil, reasonError(service.ReasonNotYetActive, map[string]string{service.MetadataNotBefore: soonStart.Format(time.RFC3339)})
+	case "ended":
+		return nil, reasonError(service.ReasonEnded, nil)
+	case "limited": // a click-limited link, whose URL probes do not get
+		if service.VisitFrom(ctx) == service.VisitProbe {
+			return &pb.ResolveResponse{NoStore: true}, nil
+		}
+		return &pb.ResolveResponse{Url: "https://example.com/limited", NoStore: true}, nil
+	case "whoami": // echoes what routing rules would see, the Visit and the address from metadata
+		q := url.Values{"ua": {req.GetUserAgent()}, "lang": {req.GetAcceptLanguage()}, "ip": {req.GetClientIp()},
+			"visit": {strconv.Itoa(int(service.VisitFrom(ctx)))}}
+		if addr := metadata.ValueFromIncomingContext(ctx, service.ClientAddrMetadata); len(addr) > 0 {
+			q.Set("addr", addr[len(addr)-1])
+		}
+		return &pb.ResolveResponse{Url: "https://example.com/?" + q.Encode()}, nil
+	}
+	return &pb.ResolveResponse{Url: "https://example.com/" + req.GetCode()}, nil
+}
+
+// reasonError is the service's error for an existing link that cannot
+// resolve.
+func reasonError(reason string, metadata map[string]string) error {
+	st, _ := status.New(codes.NotFound, "link cannot resolve").WithDetails(&errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   service.ErrorDomain,
+		Metadata: metadata,
+	})
+	return st.Err()
+}
+
+var soonStart = time.Date(2030, 1, 2, 15, 4, 5, 0, time.UTC)
+
+func (s stubShortener) BatchShorten(_ context.Context, req *pb.BatchShortenRequest) (*pb.BatchShortenResponse, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	resp := &pb.BatchShortenResponse{}
+	for i, u := range req.GetUrls() {
+		r := &pb.BatchShortenResult{Index: int32(i), Url: u}
+		if strings.Contains(u, " ") {
+			r.Error = "invalid URL"
+		} else {
+			r.Code = fmt.Sprintf("0000000%d", i)
+		}
+		resp.Results = append(resp.Results, r)
+	}
+	return resp, nil
+}
+
+func (s stubShortener) ExportLinks(req *pb.ExportLinksRequest, stream grpc.ServerStreamingServer[pb.Link]) error {
+	if s.err != nil {
+		return s.err
+	}
+	created := timestamppb.New(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	expires := timestamppb.New(created.AsTime().Add(24 * time.Hour))
+	for _, code := range []string{"0000abcd", "0000abce"} {
+		link := &pb.Link{Code: code, Url: "https://example.com/" + code, Owner: req.GetOwner(), CreatedAt: created, ExpiresAt: expires}
+		if code == "0000abce" {
+			link.PasswordHash = "$2a$10$hash"
+			link.MaxClicks, link.Clicks = 5, 2
+			link.Rules = []*pb.RoutingRule{{Platforms: []pb.Platform{pb.Platform_PLATFORM_IOS}, Url: "https://apps.apple.com/app/id1"}}
+		}
+		if err := stream.Send(link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s stubShortener) ImportLinks(_ context.Context, req *pb.ImportLinksRequest) (*pb.ImportLinksResponse, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	resp := &pb.ImportLinksResponse{}
+	for i, l := range req.GetLinks() {
+		resp.Results = append(resp.Results, &pb.ImportLinkResult{Index: int32(i), Code: l.GetCode(), Status: pb.ImportStatus_IMPORT_STATUS_IMPORTED})
+		resp.Imported++
+	}
+	return resp, nil
+}
+
+// newTestConn serves svc over an in-memory gRPC connection.
+func newTestConn(t *testing.T, svc pb.ShortenerServer) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterShortenerServer(srv, svc)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("grpc.NewClient failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// newTestAPI mounts the public handlers like main does.
+func newTestAPI(t *testing.T, svc pb.ShortenerServer) http.Handler {
+	t.Helper()
+	conn := newTestConn(t, svc)
+	gateway, err := NewGateway(context.Background(), conn)
+	if err != nil {
+		t.Fatalf("NewGateway failed: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/api/", gateway)
+	mux.Handle(NewConnectHandler(conn))
+	cookies, err := NewPasswordCookies("test-key", time.Minute)
+	if err != nil {
+		t.Fatalf("NewPasswordCookies failed: %v", err)
+	}
+	pages, err := NewPages(PageFiles{})
+	if err != nil {
+		t.Fatalf("NewPages failed: %v", err)
+	}
+	mux.HandleFunc("/", NewResolveHandler(svc, cookies, pages))
+	return WithCORS([]string{"https://app.example"}, mux)
+}
+
+func TestBulkShorten(t *testing.T) {
+	api := newTestAPI(t, stubShortener{})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten/bulk", strings.NewReader(`["example.com","not a url"]`))
+	req.Header.Set("Content-Type", "application/json")
+	api.ServeHTTP(rec, req)
+	want := `{"results":[{"index":0,"url":"example.com","code":"00000000","error":""},{"index":1,"url":"not a url","code":"","error":"invalid URL"}]}`
+	if rec.Code != http.StatusOK {
+		t.Fatalf("JSON bulk status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var got, wantBody pb.BatchShortenResponse
+	if err := protojson.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("JSON bulk response: %v", err)
+	}
+	_ = protojson.Unmarshal([]byte(want), &wantBody)
+	if !proto.Equal(&got, &wantBody) {
+		t.Errorf("JSON bulk response = %s; want %s", rec.Body.String(), want)
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/api/shorten/bulk", strings.NewReader("url\nexample.com\n\"not a url\",ignored\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	api.ServeHTTP(rec, req)
+	wantCSV := "index,url,code,error\n0,example.com,00000000,\n1,not a url,,invalid URL\n"
+	if rec.Code != http.StatusOK || rec.Body.String() != wantCSV {
+		t.Errorf("CSV bulk = %d %q; want 200 %q", rec.Code, rec.Body.String(), wantCSV)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("CSV bulk Content-Type = %q; want text/csv", ct)
+	}
+}
+
+func TestCSVOnlyOnBulk(t *testing.T) {
+	api := newTestAPI(t, stubShortener{})
+
+	tests := []struct {
+		method, path, header, body string
+		want                       int
+	}{
+		{http.MethodPost, "/api/shorten", "Accept", `{"url":"example.com"}`, http.StatusNotAcceptable},
+		{http.MethodGet, "/api/links/abc", "Accept", "", http.StatusNotAcceptable},
+		{http.MethodPost, "/api/shorten", "Content-Type", "example.com", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		req.Header.Set(tt.header, "text/csv")
+		api.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s %s with %s: text/csv = %d; want %d", tt.method, tt.path, tt.header, rec.Code, tt.want)
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Errorf("%s %s with %s: text/csv Content-Type = %q; want JSON", tt.method, tt.path, tt.header, ct)
+		}
+	}
+}
+
+func TestExportLinks(t *testing.T) {
+	api := NewExportHandler(newTestConn(t, stubShortener{}))
+
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/links/export?owner=marketing", nil))
+	wantNDJSON := `{"code":"0000abcd","url":"https://example.com/0000abcd","owner":"marketing","created_at":"2025-01-02T03:04:05Z","expires_at":"2025-01-03T03:04:05Z"}` + "\n" +
+		`{"code":"0000abce","url":"https://example.com/0000abce","owner":"marketing","created_at":"2025-01-02T03:04:05Z","expires_at":"2025-01-03T03:04:05Z",` +
+		`"password_hash":"$2a$10$hash","max_clicks":5,"clicks":2,"rules":[{"platforms":["PLATFORM_IOS"],"url":"https://apps.apple.com/app/id1"}]}` + "\n"
+	if got := strings.ReplaceAll(rec.Body.String(), " ", ""); rec.Code != http.StatusOK || got != wantNDJSON {
+		t.Errorf("NDJSON export = %d %q; want 200 %q", rec.Code, got, wantNDJSON)
+	}
+
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/links/export?format=csv", nil))
+	wantCSV := "code,url,owner,created_at,expires_at,password_hash,max_clicks,clicks,not_before,not_after,fallback_url,disabled_at,rules\n" +
+		"0000abcd,https://example.com/0000abcd,,2025-01-02T03:04:05Z,2025-01-03T03:04:05Z,,,,,,,,\n" +
+		`0000abce,https://example.com/0000abce,,2025-01-02T03:04:05Z,2025-01-03T03:04:05Z,$2a$10$hash,5,2,,,,,` +
+		`"[{""platforms"":[""PLATFORM_IOS""],""url"":""https://apps.apple.com/app/id1""}]"` + "\n"
+	if got := strings.ReplaceAll(rec.Body.String(), " ", ""); rec.Code != http.StatusOK || got != wantCSV {
+		t.Errorf("CSV export = %d %q; want 200 %q", rec.Code, got, wantCSV)
+	}
+
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/links/export?created_after=yesterday", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("bad created_after status = %d; want 400", rec.Code)
+	}
+}
+
+func TestConnectProtocols(t *testing.T) {
+	srv := httptest.NewServer(newTestAPI(t, stubShortener{}))
+	defer srv.Close()
+
+	for name, opts := range map[string][]connect.ClientOption{
+		"connect":  nil,
+		"grpc-web": {connect.WithGRPCWeb()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client := genconnect.NewShortenerClient(srv.Client(), srv.URL, opts...)
+			resp, err := client.Resolve(context.Background(), connect.NewRequest(&pb.ResolveRequest{Code: "0000abcd"}))
+			if err != nil {
+				t.Fatalf("Resolve failed: %v", err)
+			}
+			if got := resp.Msg.GetUrl(); got != "https://example.com/0000abcd" {
+				t.Errorf("Resolve = %q; want https://example.com/0000abcd", got)
+			}
+		})
+	}
+}
+
+func TestImportLinks(t *testing.T) {
+	api := NewImportHandler(newTestConn(t, stubShortener{}))
+
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/links/import",
+		strings.NewReader(`{"links":[{"code":"promo","url":"https://example.com/promo"}],"dry_run":true}`)))
+	want := `{"results":[{"code":"promo","status":"IMPORT_STATUS_IMPORTED"}],"imported":1}`
+	if got := strings.ReplaceAll(rec.Body.String(), " ", ""); rec.Code != http.StatusOK || got != want {
+		t.Errorf("import = %d %q; want 200 %q", rec.Code, got, want)
+	}
+
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/links/import", strings.NewReader(`{"links":`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("malformed body status = %d; want 400", rec.Code)
+	}
+}
+
+func TestPublicAPIHasNoAdminRPCs(t *testing.T) {
+	srv := httptest.NewServer(newTestAPI(t, stubShortener{}))
+	defer srv.Close()
+
+	client := genconnect.NewShortenerClient(srv.Client(), srv.URL)
+	stream, err := client.ExportLinks(context.Background(), connect.NewRequest(&pb.ExportLinksRequest{}))
+	if err == nil {
+		for stream.Receive() {
+			t.Errorf("Connect ExportLinks sent %v", stream.Msg())
+		}
+		err = stream.Err()
+	}
+	if connect.CodeOf(err) != connect.CodeUnimplemented {
+		t.Errorf("Connect ExportLinks error = %v; want unimplemented", err)
+	}
+	_, err = client.ImportLinks(context.Background(), connect.NewRequest(&pb.ImportLinksRequest{
+		Links: []*pb.ImportLink{{Code: "promo", Url: "https://example.com/promo"}},
+	}))
+	if connect.CodeOf(err) != connect.CodeUnimplemented {
+		t.Errorf("Connect ImportLinks error = %v; want unimplemented", err)
+	}
+
+	resp, err := srv.Client().Get(srv.URL + "/api/links/export")
+	if err != nil {
+		t.Fatalf("GET /api/links/export failed: %v", err)
+	}
+	resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct == "application/x-ndjson" || strings.HasPrefix(ct, mimeCSV) {
+		t.Errorf("GET /api/links/export served an export (%s)", ct)
+	}
+
+	resp, err = srv.Client().Post(srv.URL+"/api/links/import", "application/json",
+		strings.NewReader(`{"links":[{"code":"promo","url":"https://example.com/promo"}]}`))
+	if err != nil {
+		t.Fatalf("POST /api/links/import failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		t.Errorf("POST /api/links/import = 200; want the public API to refuse it")
+	}
+}
+
+func TestConnectMasksInternalErrors(t *testing.T) {
+	srv := httptest.NewServer(newTestAPI(t, stubShortener{err: status.Error(codes.Internal, "db insert failed: password authentication failed")}))
+	defer srv.Close()
+
+	client := genconnect.NewShortenerClient(srv.Client(), srv.URL)
+	_, err := client.Shorten(context.Background(), connect.NewRequest(&pb.ShortenRequest{Url: "example.com"}))
+	if connect.CodeOf(err) != connect.CodeInternal {
+		t.Fatalf("code = %v; want internal", connect.CodeOf(err))
+	}
+	if strings.Contains(err.Error(), "password") {
+		t.Errorf("error leaks driver message: %v", err)
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	api := newTestAPI(t, stubShortener{})
+
+	req := httptest.NewRequest(http.MethodOptions, genconnect.ShortenerShortenProcedure, nil)
+	req.Header.Set("Origin", "https://app.example")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "connect-protocol-version,content-type")
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example" {
+		t.Errorf("Access-Control-Allow-Origin = %q; want https://app.example", got)
+	}
+
+	req.Header.Set("Origin", "https://evil.example")
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin = %q for a disallowed origin", got)
+	}
+
+	// No allowed origins means no CORS at all, not any origin.
+	rec = httptest.NewRecorder()
+	WithCORS(nil, http.NotFoundHandler()).ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin = %q without allowed origins", got)
+	}
+}
+
+func TestGatewayRoutes(t *testing.T) {
+	api := newTestAPI(t, stubShortener{})
+
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"example.com"}`)))
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"code":"0000abcd"}` {
+		t.Errorf("POST /api/shorten = %d %s; want 200 {\"code\":\"0000abcd\"}", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/links/0000abcd", nil))
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"url":"https://example.com/0000abcd"}` {
+		t.Errorf("GET /api/links/0000abcd = %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/0000abcd", nil))
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "https://example.com/0000abcd" {
+		t.Errorf("GET /0000abcd = %d Location %q; want 302", rec.Code, rec.Header().Get("Location"))
+	}
+}
+
+func TestHandlersMapServiceErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		method      string
+		path        string
+		body        string
+		wantStatus  int
+		wantCode    string
+		wantMessage string
+	}{
+		{
+			name: "shorten invalid URL", err: status.Error(codes.InvalidArgument, `invalid URL: "nope"`),
+			method: http.MethodPost, path: "/api/shorten", body: `{"url":"nope"}`,
+			wantStatus: http.StatusBadRequest, wantCode: "InvalidArgument", wantMessage: `invalid URL: "nope"`,
+		},
+		{
+			name: "shorten db failure", err: status.Error(codes.Internal, "db insert failed: password authentication failed"),
+			method: http.MethodPost, path: "/api/shorten", body: `{"url":"example.com"}`,
+			wantStatus: http.StatusInternalServerError, wantCode: "Internal", wantMessage: "Internal Server Error",
+		},
+		{
+			name: "shorten malformed JSON", err: nil,
+			method: http.MethodPost, path: "/api/shorten", body: `{"url":`,
+			wantStatus: http.StatusBadRequest, wantCode: "InvalidArgument",
+		},
+		{
+			name: "links not found", err: status.Error(codes.NotFound, "code not found: abc"),
+			method: http.MethodGet, path: "/api/links/abc",
+			wantStatus: http.StatusNotFound, wantCode: "NotFound", wantMessage: "code not found: abc",
+		},
+		{
+			name: "resolve cache down", err: status.Error(codes.Unavailable, "cache lookup failed: dial tcp 10.0.0.5:6379: connection refused"),
+			method: http.MethodGet, path: "/abc",
+			wantStatus: http.StatusServiceUnavailable, wantCode: "Unavailable", wantMessage: "Service Unavailable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t, stubShortener{err: tt.err})
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req = req.WithContext(logging.WithRequestID(req.Context(), "req-1"))
+			rec := httptest.NewRecorder()
+			api.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d; want %d", rec.Code, tt.wantStatus)
+			}
+			var body ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("error body is not JSON: %v", err)
+			}
+			if body.Code != tt.wantCode || body.RequestID != "req-1" {
+				t.Errorf("body = %+v; want code %q and request_id %q", body, tt.wantCode, "req-1")
+			}
+			if tt.wantMessage != "" && body.Message != tt.wantMessage {
+				t.Errorf("message = %q; want %q", body.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestPasswordProtectedRedirect(t *testing.T) {
+	cookies, err := NewPasswordCookies("test-key", time.Minute)
+	if err != nil {
+		t.Fatalf("NewPasswordCookies failed: %v", err)
+	}
+	pages, err := NewPages(PageFiles{})
+	if err != nil {
+		t.Fatalf("NewPages failed: %v", err)
+	}
+	handler := NewResolveHandler(stubShortener{}, cookies, pages)
+	post := func(password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/locked", strings.NewReader("password="+password))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	get := func(path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/locked", nil)
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), `<form method="post" action="/locked">`) {
+		t.Fatalf("GET /locked = %d %s; want 401 and the password form", rec.Code, rec.Body.String())
+	}
+	if cc := rec.Header().Get("Cache-Control"); cc != "no-store" {
+		t.Errorf("form Cache-Control = %q; want no-store", cc)
+	}
+
+	rec = post("wrong")
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "Incorrect password.") {
+		t.Errorf("POST wrong password = %d %s; want 403 and an error", rec.Code, rec.Body.String())
+	}
+	if len(rec.Result().Cookies()) != 0 {
+		t.Error("wrong password set a cookie")
+	}
+
+	rec = post("hunter2")
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "https://example.com/locked" {
+		t.Fatalf("POST right password = %d Location %q; want 303 to the URL", rec.Code, rec.Header().Get("Location"))
+	}
+	set := rec.Result().Cookies()
+	if len(set) != 1 || set[0].Path != "/locked" || !set[0].HttpOnly || set[0].MaxAge != 60 {
+		t.Fatalf("POST right password cookies = %+v; want one HttpOnly cookie for /locked", set)
+	}
+	if strings.Contains(set[0].Value, "hunter2") {
+		t.Error("cookie holds the password in the clear")
+	}
+	cookie := &http.Cookie{Name: set[0].Name, Value: set[0].Value}
+
+	if rec = get("/locked", cookie); rec.Code != http.StatusFound || rec.Header().Get("Location") != "https://example.com/locked" {
+		t.Errorf("GET /locked with cookie = %d Location %q; want 302 to the URL", rec.Code, rec.Header().Get("Location"))
+	}
+
+	// The cookie is bound to its link, its key and its expiry.
+	req := httptest.NewRequest(http.MethodGet, "/other", nil)
+	req.AddCookie(cookie)
+	if _, ok := cookies.open(req, "other"); ok {
+		t.Error("cookie for /locked opened for /other")
+	}
+	other, _ := NewPasswordCookies("other-key", time.Minute)
+	if _, ok := other.open(req, "locked"); ok {
+		t.Error("cookie opened with another key")
+	}
+	cookies.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if rec = get("/locked", cookie); rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET /locked with an expired cookie = %d; want 401", rec.Code)
+	}
+	cookies.now = time.Now
+
+	flipped := []byte(cookie.Value)
+	flipped[0] ^= 'A' ^ 'B'
+	tampered := &http.Cookie{Name: cookie.Name, Value: string(flipped)}
+	if rec = get("/locked", tampered); rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET /locked with a tampered cookie = %d; want 401", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/throttled", strings.NewReader("password=hunter2"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests || !strings.Contains(rec.Body.String(), "Too many incorrect passwords.") {
+		t.Errorf("POST /throttled = %d %s; want 429 and the form with an error", rec.Code, rec.Body.String())
+	}
+
+	if rec = get("/open", nil); rec.Code != http.StatusFound || rec.Header().Get("Cache-Control") != "" {
+		t.Errorf("GET /open = %d Cache-Control %q; want a plain 302", rec.Code, rec.Header().Get("Cache-Control"))
+	}
+}
+
+func TestNotYetActivePage(t *testing.T) {
+	api := newTestAPI(t, stubShortener{})
+
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/soon", nil))
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "2 January 2030 at 15:04 UTC") {
+		t.Errorf("GET /soon = %d %s; want 404 and the launch time", rec.Code, rec.Body.String())
+	}
+	if ct, cc := rec.Header().Get("Content-Type"), rec.Header().Get("Cache-Control"); !strings.HasPrefix(ct, "text/html") || cc != "no-store" {
+		t.Errorf("GET /soon Content-Type %q Cache-Control %q; want uncached HTML", ct, cc)
+	}
+
+	// The JSON API reports a plain NotFound.
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/links/soon", nil))
+	if rec.Code != http.StatusNotFound || !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") {
+		t.Errorf("GET /api/links/soon = %d %s; want a 404 JSON error", rec.Code, rec.Body.String())
+	}
+
+	path := filepath.Join(t.TempDir(), "soon.html")
+	if err := os.WriteFile(path, []byte(`<p>{{.Code}} launches {{.NotBefore.Year}}</p>`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	pages, err := NewPages(PageFiles{NotYetActive: path})
+	if err != nil {
+		t.Fatalf("NewPages failed: %v", err)
+	}
+	cookies, _ := NewPasswordCookies("test-key", time.Minute)
+	rec = httptest.NewRecorder()
+	NewResolveHandler(stubShortener{}, cookies, pages).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/soon", nil))
+	if got := rec.Body.String(); got != "<p>soon launches 2030</p>" {
+		t.Errorf("custom page = %q; want <p>soon launches 2030</p>", got)
+	}
+
+	if _, err := NewPages(PageFiles{NotYetActive: filepath.Join(t.TempDir(), "missing.html")}); err == nil {
+		t.Error("NewPages with a missing file succeeded")
+	}
+}
+
+func TestNotFoundAndGonePages(t *testing.T) {
+	get := func(handler http.Handler, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+	missing := newTestAPI(t, stubShortener{err: status.Error(codes.NotFound, "code not found: abc")})
+	for _, path := range []string{"/abc", "/"} {
+		rec := get(missing, path)
+		if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "<h1>Link not found</h1>") {
+			t.Errorf("GET %s = %d %s; want the 404 page", path, rec.Code, rec.Body.String())
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			t.Errorf("GET %s Content-Type = %q; want HTML", path, ct)
+		}
+	}
+
+	rec := get(newTestAPI(t, stubShortener{}), "/ended")
+	if rec.Code != http.StatusGone || !strings.Contains(rec.Body.String(), "<h1>Link no longer available</h1>") {
+		t.Errorf("GET /ended = %d %s; want the 410 page", rec.Code, rec.Body.String())
+	}
+
+	dir := t.TempDir()
+	files := PageFiles{NotFound: filepath.Join(dir, "404.html"), Gone: filepath.Join(dir, "410.html")}
+	for path, body := range map[string]string{files.NotFound: `<p>Acme: no {{.Code}}</p>`, files.Gone: `<p>Acme: {{.Code}} is gone</p>`} {
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pages, err := NewPages(files)
+	if err != nil {
+		t.Fatalf("NewPages failed: %v", err)
+	}
+	cookies, _ := NewPasswordCookies("test-key", time.Minute)
+	if got := get(NewResolveHandler(stubShortener{}, cookies, pages), "/ended").Body.String(); got != "<p>Acme: ended is gone</p>" {
+		t.Errorf("custom 410 page = %q", got)
+	}
+	missingPages := NewResolveHandler(stubShortener{err: status.Error(codes.NotFound, "code not found: abc")}, cookies, pages)
+	if got := get(missingPages, "/abc").Body.String(); got != "<p>Acme: no abc</p>" {
+		t.Errorf("custom 404 page = %q", got)
+	}
+}
+
+func TestResolvePassesVisitor(t *testing.T) {
+	api := newTestAPI(t, stubShortener{})
+	req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone)")
+	req.Header.Set("Accept-Language", "de-DE,de;q=0.9")
+	req.Header.Set("X-Forwarded-For", "203.0.113.9, 198.51.100.7")
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+
+	loc, err := url.Parse(rec.Header().Get("Location"))
+	if rec.Code != http.StatusFound || err != nil {
+		t.Fatalf("GET /whoami = %d Location %q; want a redirect", rec.Code, rec.Header().Get("Location"))
+	}
+	q := loc.Query()
+	if q.Get("ua") != "Mozilla/5.0 (iPhone)" || q.Get("lang") != "de-DE,de;q=0.9" || q.Get("ip") != "198.51.100.7" {
+		t.Errorf("Resolve saw %v; want the User-Agent, Accept-Language and last forwarded address", q)
+	}
+}
+
+func TestResolveMarksVisits(t *testing.T) {
+	api := newTestAPI(t, stubShortener{})
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		userAgent string
+		want      service.Visit
+	}{
+		{"GET redirect", http.MethodGet, "/whoami", "Mozilla/5.0 (iPhone)", service.VisitRedirect},
+		{"HEAD", http.MethodHead, "/whoami", "Mozilla/5.0 (iPhone)", service.VisitProbe},
+		{"link preview", http.MethodGet, "/whoami", "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", service.VisitProbe},
+		{"API lookup", http.MethodGet, "/api/links/whoami", "Mozilla/5.0 (iPhone)", service.VisitLookup},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("User-Agent", tt.userAgent)
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+
+		target := rec.Header().Get("Location")
+		if target == "" {
+			var body struct{ URL string }
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("%s: no Location and body %q", tt.name, rec.Body.String())
+			}
+			target = body.URL
+		}
+		loc, err := url.Parse(target)
+		if err != nil {
+			t.Fatalf("%s: bad URL %q", tt.name, target)
+		}
+		if got := loc.Query().Get("visit"); got != strconv.Itoa(int(tt.want)) {
+			t.Errorf("%s: visit = %s; want %d", tt.name, got, tt.want)
+		}
+	}
+
+	// Probes of a click-limited link learn that it resolves, not where.
+	for _, probe := range []struct{ method, userAgent string }{
+		{http.MethodHead, "Mozilla/5.0 (iPhone)"},
+		{http.MethodGet, "facebookexternalhit/1.1"},
+	} {
+		req := httptest.NewRequest(probe.method, "/limited", nil)
+		req.Header.Set("User-Agent", probe.userAgent)
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNoContent || rec.Header().Get("Location") != "" {
+			t.Errorf("%s /limited as %q = %d Location %q; want 204 and no Location",
+				probe.method, probe.userAgent, rec.Code, rec.Header().Get("Location"))
+		}
+	}
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/limited", nil))
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "https://example.com/limited" {
+		t.Errorf("GET /limited = %d Location %q; want a redirect", rec.Code, rec.Header().Get("Location"))
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		xff  []string
+		want string
+	}{
+		{nil, "192.0.2.1"},
+		{[]string{"203.0.113.9"}, "203.0.113.9"},
+		{[]string{"203.0.113.9, 198.51.100.7"}, "198.51.100.7"},
+		{[]string{"203.0.113.9", "2001:db8::1"}, "2001:db8::1"},
+		{[]string{""}, "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+		req.RemoteAddr = "192.0.2.1:4321"
+		for _, v := range tt.xff {
+			req.Header.Add("X-Forwarded-For", v)
+		}
+		if got := clientIP(req); got != tt.want {
+			t.Errorf("clientIP with X-Forwarded-For %q = %q; want %q", tt.xff, got, tt.want)
+		}
+	}
+}
+
+func TestGatewayResolvePassword(t *testing.T) {
+	api := newTestAPI(t, stubShortener{})
+
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/links/locked", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET /api/links/locked = %d %s; want 401", rec.Code, rec.Body.String())
+	}
+
+	// Passwords in URLs end up in access logs and browser history.
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/links/locked?password=hunter2", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("GET /api/links/locked?password=hunter2 = %d %s; want 400", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/links/locked/resolve", strings.NewReader(`{"password":"hunter2"}`))
+	req.Header.Set("Content-Type", "application/json")
+	api.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"url":"https://example.com/locked"}` {
+		t.Errorf("POST /api/links/locked/resolve = %d %s; want 200", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(2, time.Minute)
+	limiter.now = func() time.Time { return now }
+	mux := http.NewServeMux()
+	mux.Handle("POST /bulk", limiter.Limit(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})))
+
+	post := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/bulk", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	for i := 0; i < 2; i++ {
+		if rec := post("192.0.2.1:1234"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d = %d; want 200", i+1, rec.Code)
+		}
+	}
+	now = now.Add(20 * time.Second)
+	rec := post("192.0.2.1:5678")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "40" {
+		t.Errorf("third request = %d Retry-After %q; want 429 and 40", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	var body ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Code != "ResourceExhausted" {
+		t.Errorf("429 body = %+v, %v; want code ResourceExhausted", body, err)
+	}
+	if rec := post("192.0.2.2:1234"); rec.Code != http.StatusOK {
+		t.Errorf("another client = %d; want 200", rec.Code)
+	}
+
+	now = now.Add(time.Minute)
+	if rec := post("192.0.2.1:1234"); rec.Code != http.StatusOK {
+		t.Errorf("after the window = %d; want 200", rec.Code)
+	}
+
+	unlimited := NewRateLimiter(0, time.Minute)
+	for i := 0; i < 100; i++ {
+		if ok, _ := unlimited.allow("192.0.2.1"); !ok {
+			t.Fatal("a limit of 0 refused a request")
+		}
+	}
+}
+
+func TestAPIResolveSendsClientAddr(t *testing.T) {
+	srv := httptest.NewServer(newTestAPI(t, stubShortener{}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/links/whoami", nil)
+	req.Header.Set("X-Forwarded-For", "203.0.113.9, 198.51.100.7")
+	req.Header.Set("Grpc-Metadata-"+service.ClientAddrMetadata, "192.0.2.1")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET /api/links/whoami failed: %v", err)
+	}
+	var body struct{ URL string }
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("decoding the response failed: %v", err)
+	}
+	if u, err := url.Parse(body.URL); err != nil || u.Query().Get("addr") != "198.51.100.7" {
+		t.Errorf("REST Resolve URL = %q; want addr=198.51.100.7, the last forwarded address", body.URL)
+	}
+
+	client := genconnect.NewShortenerClient(srv.Client(), srv.URL)
+	creq := connect.NewRequest(&pb.ResolveRequest{Code: "whoami"})
+	creq.Header().Set("X-Forwarded-For", "198.51.100.8")
+	cresp, err := client.Resolve(context.Background(), creq)
+	if err != nil {
+		t.Fatalf("Connect Resolve failed: %v", err)
+	}
+	if u, err := url.Parse(cresp.Msg.GetUrl()); err != nil || u.Query().Get("addr") != "198.51.100.8" {
+		t.Errorf("Connect Resolve URL = %q; want addr=198.51.100.8", cresp.Msg.GetUrl())
+	}
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	pb "github.com/JohnBPerkins/url-shortener/gen"
+	"github.com/JohnBPerkins/url-shortener/internal/codegen"
+	"github.com/JohnBPerkins/url-shortener/internal/service"
+	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v4"
+)
+
+// runCreate shortens URLs through BatchShorten, or stores one URL under a
+// chosen code through ImportLinks.
+func runCreate(args []string) int {
+	fs := newFlagSet("create", "create [flags] URL...", "Shortens each URL through the gRPC API and prints its code.")
+	addr := apiAddrFlag(fs)
+	token := adminTokenFlag(fs)
+	owner := fs.String("owner", "", "owner to tag the links with")
+	code := fs.String("code", "", "custom code for a single URL; must follow the alias rules")
+	strategy := fs.String("strategy", "", "code strategy: sonyflake, random, hashids or words (default: the server's CODE_STRATEGY)")
+	timeout := commandTimeout(fs)
+	if err := fs.Parse(args); err != nil {
+		return flagExit(err)
+	}
+	if fs.NArg() == 0 || (*code != "" && fs.NArg() != 1) {
+		fs.Usage()
+		return 2
+	}
+	codeStrategy := pb.CodeStrategy_CODE_STRATEGY_UNSPECIFIED
+	if *strategy != "" {
+		s, err := codegen.ParseStrategy(*strategy)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		codeStrategy = pb.CodeStrategy(pb.CodeStrategy_value["CODE_STRATEGY_"+strings.ToUpper(string(s))])
+	}
+
+	client, conn, err := dialAPI(*addr, *token)
+	if err != nil {
+		return fail(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	if *code != "" {
+		resp, err := client.ImportLinks(ctx, &pb.ImportLinksRequest{Links: []*pb.ImportLink{
+			{Code: *code, Url: fs.Arg(0), Owner: *owner},
+		}})
+		if err != nil {
+			return fail(err)
+		}
+		if r := resp.GetResults()[0]; r.GetStatus() != pb.ImportStatus_IMPORT_STATUS_IMPORTED {
+			return fail(errors.New(describeImportResult(r)))
+		}
+		fmt.Printf("%s\t%s\n", *code, fs.Arg(0))
+		return 0
+	}
+
+	resp, err := client.BatchShorten(ctx, &pb.BatchShortenRequest{Urls: fs.Args(), Owner: *owner, CodeStrategy: codeStrategy})
+	if err != nil {
+		return fail(err)
+	}
+	status := 0
+	for _, r := range resp.GetResults() {
+		if r.GetError() != "" {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", r.GetUrl(), r.GetError())
+			status = 1
+			continue
+		}
+		fmt.Printf("%s\t%s\n", r.GetCode(), r.GetUrl())
+	}
+	return status
+}
+
+// runGet prints links straight from Postgres, bypassing the cache.
+func runGet(args []string) int {
+	fs := newFlagSet("get", "get [flags] CODE...", "Prints each link's URL, owner and timestamps from Postgres.")
+	timeout := commandTimeout(fs)
+	if err := fs.Parse(args); err != nil {
+		return flagExit(err)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	pool, err := openDatabase(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	defer pool.Close()
+
+	status := 0
+	for i, code := range fs.Args() {
+		var (
+			url, owner, fallback string
+			createdAt            time.Time
+			expires, disabledAt  *time.Time
+		)
+		err := pool.QueryRow(ctx, `SELECT url, COALESCE(owner, ''), created_at, expires, COALESCE(fallback_url, ''), disabled_at
+			FROM links WHERE code = $1`, code).
+			Scan(&url, &owner, &createdAt, &expires, &fallback, &disabledAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			fmt.Fprintf(os.Stderr, "%s: not found\n", code)
+			status = 1
+			continue
+		}
+		if err != nil {
+			return fail(err)
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		expiry := "never"
+		if expires != nil {
+			expiry = expires.Format(time.RFC3339)
+			if !expires.After(time.Now()) {
+				expiry += " (expired)"
+			}
+		}
+		fmt.Printf("%-12s %s\n%-12s %s\n%-12s %s\n%-12s %s\n%-12s %s\n%-12s %s\n",
+			"code", code, "url", url, "owner", owner,
+			"created_at", createdAt.Format(time.RFC3339), "expires_at", expiry, "fallback_url", fallback)
+		if disabledAt != nil {
+			fmt.Printf("%-12s %s\n", "disabled_at", disabledAt.Format(time.RFC3339))
+		}
+	}
+	return status
+}
+
+// runDelete removes links from Postgres and evicts them from Redis so they
+// stop resolving immediately.
+func runDelete(args []string) int {
+	fs := newFlagSet("delete", "delete [flags] CODE...", `Deletes each link from Postgres and Redis. Deleted codes get the 404 page,
+not a fallback URL; use disable to keep the fallback.`)
+	timeout := commandTimeout(fs)
+	if err := fs.Parse(args); err != nil {
+		return flagExit(err)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	cache, err := openCache()
+	if err != nil {
+		return fail(err)
+	}
+	defer cache.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	pool, err := openDatabase(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	defer pool.Close()
+
+	rows, err := pool.Query(ctx, `DELETE FROM links WHERE code = ANY($1) RETURNING code`, fs.Args())
+	if err != nil {
+		return fail(err)
+	}
+	deleted := make(map[string]bool)
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			rows.Close()
+			return fail(err)
+		}
+		deleted[code] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fail(err)
+	}
+
+	// Evict every requested code, not just the deleted ones, in case the
+	// cache outlived its row. Click and password counters go too, so a link
+	// later stored under the same code starts with its own quota.
+	keys, err := evictionKeys(ctx, cache, fs.Args())
+	if err == nil {
+		err = cache.Del(ctx, keys...).Err()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: Redis eviction failed, links may resolve until their cache TTL: %v\n", err)
+	}
+
+	status := 0
+	for _, code := range fs.Args() {
+		if deleted[code] {
+			fmt.Printf("deleted %s\n", code)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%s: not found\n", code)
+		status = 1
+	}
+	return status
+}
+
+// evictionKeys lists the Redis keys of codes: their cache entries, click
+// counters and the wrong password counters of every address.
+func evictionKeys(ctx context.Context, cache *redis.Client, codes []string) ([]string, error) {
+	keys := append([]string(nil), codes...)
+	for _, code := range codes {
+		keys = append(keys, service.ClicksKey(code))
+		iter := cache.Scan(ctx, 0, service.PasswordFailuresKey(code, "*"), 100).Iterator()
+		for iter.Next(ctx) {
+			keys = append(keys, iter.Val())
+		}
+		if err := iter.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// runDisable turns links off without deleting them, so they keep their
+// fallback URL and can be turned back on with enable.
+func runDisable(args []string) int {
+	return setDisabled("disable", args, true)
+}
+
+// runEnable turns disabled links back on.
+func runEnable(args []string) int {
+	return setDisabled("enable", args, false)
+}
+
+func setDisabled(name string, args []string, disable bool) int {
+	description := "Disables each link: it stops resolving and sends visitors to its fallback URL, or serves the 410 page."
+	if !disable {
+		description = "Re-enables each disabled link."
+	}
+	fs := newFlagSet(name, name+" [flags] CODE...", description)
+	timeout := commandTimeout(fs)
+	if err := fs.Parse(args); err != nil {
+		return flagExit(err)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	// Disabled links are never cached, so only disabling needs an eviction.
+	var cache *redis.Client
+	if disable {
+		var err error
+		if cache, err = openCache(); err != nil {
+			return fail(err)
+		}
+		defer cache.Close()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	pool, err := openDatabase(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	defer pool.Close()
+
+	stmt := `UPDATE links SET disabled_at = NULL WHERE code = ANY($1) RETURNING code`
+	if disable {
+		stmt = `UPDATE links SET disabled_at = COALESCE(disabled_at, now()) WHERE code = ANY($1) RETURNING code`
+	}
+	rows, err := pool.Query(ctx, stmt, fs.Args())
+	if err != nil {
+		return fail(err)
+	}
+	updated := make(map[string]bool)
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			rows.Close()
+			return fail(err)
+		}
+		updated[code] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fail(err)
+	}
+
+	if cache != nil && len(updated) > 0 {
+		if err := cache.Del(ctx, fs.Args()...).Err(); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: Redis eviction failed, links may resolve until their cache TTL: %v\n", err)
+		}
+	}
+
+	status := 0
+	for _, code := range fs.Args() {
+		if updated[code] {
+			fmt.Printf("%sd %s\n", name, code)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%s: not found\n", code)
+		status = 1
+	}
+	return status
+}
+
+// runFallback shows, sets or clears an owner's fallback URL, where their
+// links that cannot resolve and have no fallback of their own redirect.
+func runFallback(args []string) int {
+	fs := newFlagSet("fallback", "fallback [flags] OWNER [URL]",
+		"Prints OWNER's fallback URL, or sets it to URL. Links of OWNER that are disabled, ended or out of clicks\nredirect there unless they have a fallback URL of their own.")
+	clearURL := fs.Bool("clear", false, "remove OWNER's fallback URL")
+	timeout := commandTimeout(fs)
+	if err := fs.Parse(args); err != nil {
+		return flagExit(err)
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 || (*clearURL && fs.NArg() != 1) {
+		fs.Usage()
+		return 2
+	}
+	owner := fs.Arg(0)
+	if fs.NArg() == 2 {
+		if u, err := neturl.Parse(fs.Arg(1)); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fmt.Fprintf(os.Stderr, "%q is not an absolute http or https URL\n", fs.Arg(1))
+			return 2
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	pool, err := openDatabase(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	defer pool.Close()
+
+	switch {
+	case *clearURL:
+		tag, err := pool.Exec(ctx, `DELETE FROM owner_fallbacks WHERE owner = $1`, owner)
+		if err != nil {
+			return fail(err)
+		}
+		if tag.RowsAffected() == 0 {
+			fmt.Fprintf(os.Stderr, "%s: no fallback URL\n", owner)
+			return 1
+		}
+		fmt.Printf("cleared %s\n", owner)
+	case fs.NArg() == 2:
+		_, err := pool.Exec(ctx, `INSERT INTO owner_fallbacks (owner, url) VALUES ($1, $2)
+			ON CONFLICT (owner) DO UPDATE SET url = EXCLUDED.url, updated_at = now()`, owner, fs.Arg(1))
+		if err != nil {
+			return fail(err)
+		}
+		fmt.Printf("%s\t%s\n", owner, fs.Arg(1))
+	default:
+		var url string
+		err := pool.QueryRow(ctx, `SELECT url FROM owner_fallbacks WHERE owner = $1`, owner).Scan(&url)
+		if errors.Is(err, pgx.ErrNoRows) {
+			fmt.Fprintf(os.Stderr, "%s: no fallback URL\n", owner)
+			return 1
+		}
+		if err != nil {
+			return fail(err)
+		}
+		fmt.Printf("%s\t%s\n", owner, url)
+	}
+	return 0
+}
+
+// runStats prints link counts and the largest owners.
+func runStats(args []string) int {
+	fs := newFlagSet("stats", "stats [flags]", "Summarises the links table.")
+	top := fs.Int("owners", 10, "number of owners to list by link count")
+	timeout := commandTimeout(fs)
+	if err := fs.Parse(args); err != nil {
+		return flagExit(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	pool, err := openDatabase(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	defer pool.Close()
+
+	var (
+		total, expired, lastDay int64
+		oldest, newest          *time.Time
+	)
+	err = pool.QueryRow(ctx, `SELECT count(*),
+			count(*) FILTER (WHERE expires <= now()),
+			count(*) FILTER (WHERE created_at > now() - INTERVAL '24 hours'),
+			min(created_at), max(created_at)
+		FROM links`).Scan(&total, &expired, &lastDay, &oldest, &newest)
+	if err != nil {
+		return fail(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "links\t%d\n", total)
+	fmt.Fprintf(w, "expired\t%d\n", expired)
+	fmt.Fprintf(w, "created (24h)\t%d\n", lastDay)
+	if oldest != nil && newest != nil {
+		fmt.Fprintf(w, "oldest\t%s\n", oldest.Format(time.RFC3339))
+		fmt.Fprintf(w, "newest\t%s\n", newest.Format(time.RFC3339))
+	}
+
+	if *top > 0 {
+		rows, err := pool.Query(ctx, `SELECT COALESCE(owner, '(none)'), count(*) FROM links
+			GROUP BY 1 ORDER BY 2 DESC, 1 LIMIT $1`, *top)
+		if err != nil {
+			return fail(err)
+		}
+		defer rows.Close()
+		fmt.Fprintln(w, "\nowner\tlinks")
+		for rows.Next() {
+			var owner string
+			var n int64
+			if err := rows.Scan(&owner, &n); err != nil {
+				return fail(err)
+			}
+			fmt.Fprintf(w, "%s\t%d\n", owner, n)
+		}
+		if err := rows.Err(); err != nil {
+			return fail(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fail(err)
+	}
+	return 0
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/JohnBPerkins/url-shortener/migrations"
+)
+
+// runMigrate applies the embedded schema migrations that the database has not
+// recorded yet.
+func runMigrate(args []string) int {
+	fs := newFlagSet("migrate", "migrate [flags]", "Applies pending schema migrations from the migrations directory.")
+	dryRun := fs.Bool("dry-run", false, "list pending migrations without applying them")
+	timeout := commandTimeout(fs)
+	if err := fs.Parse(args); err != nil {
+		return flagExit(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	pool, err := openDatabase(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	defer pool.Close()
+
+	if *dryRun {
+		pending, err := migrations.Pending(ctx, pool)
+		if err != nil {
+			return fail(err)
+		}
+		for _, m := range pending {
+			fmt.Printf("pending %s\n", m.Version)
+		}
+		fmt.Printf("%d pending migrations\n", len(pending))
+		return 0
+	}
+
+	applied, err := migrations.Apply(ctx, pool)
+	for _, v := range applied {
+		fmt.Printf("applied %s\n", v)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	fmt.Printf("%d migrations applied, schema is up to date\n", len(applied))
+	return 0
+}
@@ -0,0 +1,5 @@
+ALTER TABLE public.links ADD COLUMN IF NOT EXISTS owner TEXT;
+
+-- Serves ExportLinks, which filters by owner and orders by creation time.
+CREATE INDEX IF NOT EXISTS links_owner_created_at_idx ON public.links (owner, created_at);
+CREATE INDEX IF NOT EXISTS links_created_at_idx ON public.links (created_at);
@@ -0,0 +1,7 @@
+-- Claims on Sonyflake machine IDs, used when SONYFLAKE_MACHINE_ID_SOURCE or
+-- SONYFLAKE_MACHINE_ID_CHECK is postgres. A claim is live until expires_at.
+CREATE TABLE IF NOT EXISTS public.sonyflake_machine_ids (
+  machine_id INTEGER PRIMARY KEY CHECK (machine_id BETWEEN 0 AND 65535),
+  holder     TEXT NOT NULL,
+  expires_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,2 @@
+-- bcrypt hash of the password that protects a link; NULL for open links.
+ALTER TABLE public.links ADD COLUMN IF NOT EXISTS password_hash TEXT;
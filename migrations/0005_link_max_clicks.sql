@@ -0,0 +1,4 @@
+-- Click quotas. Redis counts down the remaining clicks; clicks is the count
+-- reconciled to Postgres after each one, used to rebuild the Redis counter.
+ALTER TABLE public.links ADD COLUMN IF NOT EXISTS max_clicks INTEGER CHECK (max_clicks > 0);
+ALTER TABLE public.links ADD COLUMN IF NOT EXISTS clicks INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+-- Activation windows. A link resolves only from not_before until not_after;
+-- NULL leaves that side open.
+ALTER TABLE public.links ADD COLUMN IF NOT EXISTS not_before TIMESTAMPTZ;
+ALTER TABLE public.links ADD COLUMN IF NOT EXISTS not_after TIMESTAMPTZ;
@@ -0,0 +1,12 @@
+-- Fallback destinations. Links that cannot resolve, because they are outside
+-- their window, out of clicks or disabled, redirect to their own
+-- fallback_url or else to their owner's. Disabling keeps the row, and so the
+-- fallback, where deleting would lose it.
+ALTER TABLE public.links ADD COLUMN IF NOT EXISTS fallback_url TEXT;
+ALTER TABLE public.links ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS public.owner_fallbacks (
+  owner      TEXT PRIMARY KEY,
+  url        TEXT NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,3 @@
+-- Routing rules: an ordered JSON array of {platforms, languages, countries,
+-- url} objects. The first rule matching a visitor picks the destination.
+ALTER TABLE public.links ADD COLUMN IF NOT EXISTS routing_rules JSONB;
@@ -0,0 +1,3 @@
+-- Links with a window or a click limit expire at not_after, or never if they
+-- have none, instead of a day after they are created. NULL means never.
+ALTER TABLE public.links ALTER COLUMN expires DROP NOT NULL;
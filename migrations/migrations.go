@@ -0,0 +1,128 @@
+// Package migrations holds the links schema as numbered SQL files and applies
+// the ones a database has not seen yet. The same files initialise the
+// docker-compose database, so each must be safe to run against a schema that
+// already has it.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// lockID is the advisory lock that stops two migrators running at once.
+const lockID = 0x75726c73 // "urls"
+
+// Migration is one SQL file; Version is its name without the extension.
+type Migration struct {
+	Version string
+	SQL     string
+}
+
+// All returns the embedded migrations in the order they apply.
+func All() ([]Migration, error) {
+	names, err := fs.Glob(files, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	migrations := make([]Migration, 0, len(names))
+	for _, name := range names {
+		sql, err := files.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: strings.TrimSuffix(name, ".sql"), SQL: string(sql)})
+	}
+	return migrations, nil
+}
+
+// Pending returns the migrations not yet recorded in schema_migrations.
+func Pending(ctx context.Context, pool *pgxpool.Pool) ([]Migration, error) {
+	if err := ensureTable(ctx, pool); err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, m := range all {
+		if !applied[m.Version] {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// Apply runs every pending migration, each in its own transaction, and
+// returns the versions it applied.
+func Apply(ctx context.Context, pool *pgxpool.Pool) ([]string, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return nil, fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() { _, _ = conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID) }()
+
+	pending, err := Pending(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+	var done []string
+	for _, m := range pending {
+		err := conn.BeginFunc(ctx, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, m.SQL); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, m.Version)
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %s: %w", m.Version, err)
+		}
+		done = append(done, m.Version)
+	}
+	return done, nil
+}
+
+func ensureTable(ctx context.Context, pool *pgxpool.Pool) error {
+	_, err := pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    TEXT PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	return err
+}
+
+func appliedVersions(ctx context.Context, pool *pgxpool.Pool) (map[string]bool, error) {
+	rows, err := pool.Query(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[string]bool)
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		applied[v] = true
+	}
+	return applied, rows.Err()
+}
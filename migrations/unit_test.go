@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAllIsOrderedAndIdempotent(t *testing.T) {
+	all, err := All()
+	if err != nil {
+		t.Fatalf("All failed: %v", err)
+	}
+	if len(all) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	for i, m := range all {
+		if i > 0 && m.Version <= all[i-1].Version {
+			t.Errorf("migration %s sorts before %s", m.Version, all[i-1].Version)
+		}
+		// docker-compose runs every file on a fresh database, and migrate may
+		// run them against a schema created that way.
+		for _, stmt := range []string{"CREATE TABLE ", "CREATE INDEX ", "ADD COLUMN "} {
+			if strings.Contains(m.SQL, stmt) && !strings.Contains(m.SQL, stmt+"IF NOT EXISTS") {
+				t.Errorf("migration %s uses %q without IF NOT EXISTS", m.Version, stmt)
+			}
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package flake
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sony/sonyflake"
+)
+
+var (
+	// ErrClockBackwards means the clock is too far behind the last ID to wait
+	// for it to catch up.
+	ErrClockBackwards = errors.New("clock is behind the last Sonyflake ID")
+	// ErrLifetimeExceeded means the 39-bit time field has run out, about 174
+	// years after the start time.
+	ErrLifetimeExceeded = errors.New("Sonyflake time range exhausted")
+	// ErrMachineIDLost means the machine ID lease was lost, so another
+	// instance may be generating IDs with the same machine ID.
+	ErrMachineIDLost = errors.New("Sonyflake machine ID lost")
+)
+
+// Values of the reason label on Metrics.FallbackIDs.
+const (
+	reasonClockBackwards = "clock_backwards"
+	reasonLifetime       = "lifetime"
+)
+
+const (
+	// timeUnit is the resolution of the time field.
+	timeUnit    = 10 * time.Millisecond
+	maxSequence = 1<<sonyflake.BitLenSequence - 1
+	maxElapsed  = 1<<sonyflake.BitLenTime - 1
+
+	// DefaultMaxWait is how long NextID waits by default for the clock to
+	// reach the next time slot.
+	DefaultMaxWait = 100 * time.Millisecond
+)
+
+// Clock tells the time and waits. Tests substitute a fake.
+type Clock interface {
+	Now() time.Time
+	Sleep(time.Duration)
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time        { return time.Now() }
+func (systemClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// IDSource produces 63-bit IDs.
+type IDSource interface {
+	NextID() (uint64, error)
+}
+
+// GeneratorOptions tunes a Generator. The zero value waits up to
+// DefaultMaxWait, has no fallback and reads the system clock.
+type GeneratorOptions struct {
+	// MaxWait bounds how long NextID blocks for the clock to reach the next
+	// time slot. Waits up to 10ms follow a used-up sequence; longer ones mean
+	// the clock was stepped back.
+	MaxWait time.Duration
+	// Fallback supplies IDs when Sonyflake cannot. Without one NextID fails
+	// with ErrClockBackwards or ErrLifetimeExceeded instead.
+	Fallback IDSource
+	// Lost is usually the machine ID lease's Lost channel. Once it is
+	// closed, NextID fails with ErrMachineIDLost, bypassing the fallback.
+	Lost    <-chan struct{}
+	Clock   Clock
+	Metrics *Metrics
+}
+
+// Generator produces IDs with the same layout as the sonyflake package, but
+// never blocks for longer than MaxWait. The library sleeps for as long as the
+// clock is behind, holding its lock, so a clock stepped back by an hour would
+// stall every Shorten call for an hour.
+type Generator struct {
+	mu        sync.Mutex
+	clock     Clock
+	startTime time.Time
+	start     int64
+	machineID uint16
+	elapsed   int64
+	sequence  uint16
+	lastSeen  int64
+	maxWait   time.Duration
+	fallback  IDSource
+	lost      <-chan struct{}
+	metrics   *Metrics
+}
+
+// NewGenerator returns a Generator for machineID.
+func NewGenerator(machineID uint16, opts GeneratorOptions) (*Generator, error) {
+	if opts.Clock == nil {
+		opts.Clock = systemClock{}
+	}
+	if opts.MaxWait <= 0 {
+		opts.MaxWait = DefaultMaxWait
+	}
+	if opts.Metrics == nil {
+		opts.Metrics = NewMetrics(nil)
+	}
+	now := opts.Clock.Now()
+	if now.Before(startTime) {
+		return nil, fmt.Errorf("clock reads %s, before the Sonyflake start time %s", now.UTC(), startTime)
+	}
+	g := &Generator{
+		clock:     opts.Clock,
+		startTime: startTime,
+		start:     toSlot(startTime),
+		machineID: machineID,
+		// The first call starts a new slot, whatever the clock says.
+		elapsed:  -1,
+		lastSeen: toSlot(now) - toSlot(startTime),
+		maxWait:  opts.MaxWait,
+		fallback: opts.Fallback,
+		lost:     opts.Lost,
+		metrics:  opts.Metrics,
+	}
+	g.metrics.LifetimeRemaining.Set(g.remaining(g.lastSeen).Seconds())
+	return g, nil
+}
+
+// NextID returns the next ID. While the clock is behind the last ID it keeps
+// counting in the last slot, which stays unique, and waits for the clock only
+// once that slot's sequence is used up.
+func (g *Generator) NextID() (uint64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	select {
+	case <-g.lost:
+		return 0, ErrMachineIDLost
+	default:
+	}
+
+	now := g.clock.Now()
+	current := toSlot(now) - g.start
+	if current < g.lastSeen {
+		g.metrics.ClockBackwards.Inc()
+		slog.Warn("clock moved backwards", "by", time.Duration(g.lastSeen-current)*timeUnit, "machine_id", g.machineID)
+	}
+	g.lastSeen = current
+	g.metrics.LifetimeRemaining.Set(g.remaining(current).Seconds())
+
+	switch {
+	case current > g.elapsed:
+		g.elapsed, g.sequence = current, 0
+	case g.sequence < maxSequence:
+		g.sequence++
+	default:
+		// No ID may be ahead of the clock, or a restart could repeat it.
+		g.metrics.SequenceExhausted.Inc()
+		wait := g.startTime.Add(time.Duration(g.elapsed+1) * timeUnit).Sub(now)
+		if wait > g.maxWait {
+			return g.fall(reasonClockBackwards, fmt.Errorf("%w: next slot is %s away, more than %s", ErrClockBackwards, wait, g.maxWait))
+		}
+		g.clock.Sleep(wait)
+		g.elapsed, g.sequence = g.elapsed+1, 0
+	}
+
+	if g.elapsed > maxElapsed {
+		return g.fall(reasonLifetime, ErrLifetimeExceeded)
+	}
+	return uint64(g.elapsed)<<(sonyflake.BitLenSequence+sonyflake.BitLenMachineID) |
+		uint64(g.sequence)<<sonyflake.BitLenMachineID |
+		uint64(g.machineID), nil
+}
+
+// fall returns an ID from the fallback, or err without one.
+func (g *Generator) fall(reason string, err error) (uint64, error) {
+	if g.fallback == nil {
+		return 0, err
+	}
+	g.metrics.FallbackIDs.WithLabelValues(reason).Inc()
+	return g.fallback.NextID()
+}
+
+// remaining is how long the time field lasts after elapsed.
+func (g *Generator) remaining(elapsed int64) time.Duration {
+	left := maxElapsed + 1 - elapsed
+	if left < 0 {
+		return 0
+	}
+	return time.Duration(left) * timeUnit
+}
+
+func toSlot(t time.Time) int64 {
+	return t.UnixNano() / int64(timeUnit)
+}
+
+// RandomIDs is a fallback that returns random 63-bit IDs. They are not time
+// ordered, and their uniqueness rests on the size of the space and on the
+// unique constraint on links.code, whose violations Shorten retries.
+type RandomIDs struct{}
+
+func (RandomIDs) NextID() (uint64, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b[:]) >> 1, nil
+}
+
+// Metrics holds the Generator's Prometheus collectors.
+type Metrics struct {
+	ClockBackwards    prometheus.Counter
+	SequenceExhausted prometheus.Counter
+	FallbackIDs       *prometheus.CounterVec
+	LifetimeRemaining prometheus.Gauge
+}
+
+// NewMetrics creates the Generator collectors and registers them on reg. A
+// nil reg leaves them unregistered.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		ClockBackwards: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: "url_shortener",
+				Subsystem: "sonyflake",
+				Name:      "clock_backwards_total",
+				Help:      "Total number of times the clock read earlier than on the previous NextID() call.",
+			},
+		),
+		SequenceExhausted: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: "url_shortener",
+				Subsystem: "sonyflake",
+				Name:      "sequence_exhausted_total",
+				Help:      "Total number of times a 10ms slot ran out of sequence numbers and NextID() had to wait.",
+			},
+		),
+		FallbackIDs: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "url_shortener",
+				Subsystem: "sonyflake",
+				Name:      "fallback_ids_total",
+				Help:      "Total number of IDs taken from the fallback generator, by reason.",
+			},
+			[]string{"reason"},
+		),
+		LifetimeRemaining: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: "url_shortener",
+				Subsystem: "sonyflake",
+				Name:      "lifetime_remaining_seconds",
+				Help:      "Time left before the Sonyflake time field overflows.",
+			},
+		),
+	}
+	if reg != nil {
+		reg.MustRegister(m.ClockBackwards, m.SequenceExhausted, m.FallbackIDs, m.LifetimeRemaining)
+	}
+	return m
+}
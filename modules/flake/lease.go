@@ -0,0 +1,160 @@
+package flake
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrInUse means another live instance holds the machine ID.
+var ErrInUse = errors.New("machine ID is held by another instance")
+
+// Registry records which instance holds each machine ID, so that no two live
+// instances generate IDs with the same one.
+type Registry interface {
+	// Claim makes holder the owner of id for ttl. It extends the claim if
+	// holder already owns id, takes it over if the previous claim expired,
+	// and returns ErrInUse if another holder's claim is still live.
+	Claim(ctx context.Context, id uint16, holder string, ttl time.Duration) error
+	// Release gives up holder's claim on id, if it still has it.
+	Release(ctx context.Context, id uint16, holder string) error
+}
+
+// acquireAttempts bounds how many random IDs Acquire tries. With fewer than
+// a few hundred instances nearly every first try succeeds.
+const acquireAttempts = 256
+
+// Lease is a claimed machine ID, renewed in the background until Close.
+type Lease struct {
+	ID uint16
+
+	reg    Registry
+	holder string
+	ttl    time.Duration
+	lost   chan struct{}
+	stop   context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+}
+
+// Hold claims id for holder, failing with ErrInUse if another live instance
+// has it. A nil registry skips the check and returns a lease that is never
+// lost.
+func Hold(ctx context.Context, reg Registry, id uint16, holder string, ttl time.Duration) (*Lease, error) {
+	if reg == nil {
+		return &Lease{ID: id, lost: make(chan struct{})}, nil
+	}
+	if err := reg.Claim(ctx, id, holder, ttl); err != nil {
+		return nil, fmt.Errorf("claim machine ID %d: %w", id, err)
+	}
+	return startLease(reg, id, holder, ttl), nil
+}
+
+// Acquire claims any free machine ID, trying IDs in random order.
+func Acquire(ctx context.Context, reg Registry, holder string, ttl time.Duration) (*Lease, error) {
+	for range acquireAttempts {
+		var b [2]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return nil, err
+		}
+		id := binary.BigEndian.Uint16(b[:])
+		err := reg.Claim(ctx, id, holder, ttl)
+		if errors.Is(err, ErrInUse) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("claim machine ID %d: %w", id, err)
+		}
+		return startLease(reg, id, holder, ttl), nil
+	}
+	return nil, fmt.Errorf("no free machine ID after %d attempts", acquireAttempts)
+}
+
+func startLease(reg Registry, id uint16, holder string, ttl time.Duration) *Lease {
+	ctx, stop := context.WithCancel(context.Background())
+	l := &Lease{
+		ID:     id,
+		reg:    reg,
+		holder: holder,
+		ttl:    ttl,
+		lost:   make(chan struct{}),
+		stop:   stop,
+		done:   make(chan struct{}),
+	}
+	go l.heartbeat(ctx)
+	return l
+}
+
+// heartbeat renews the claim three times per TTL. A failed renewal is only
+// logged at first, since a registry that is down lets no other instance
+// claim the ID either. Once renewals have failed for longer than the TTL the
+// claim may have expired for instances that can still reach the registry, so
+// the lease is lost, as it is when the registry names another holder.
+func (l *Lease) heartbeat(ctx context.Context) {
+	defer close(l.done)
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		renewCtx, cancel := context.WithTimeout(ctx, l.ttl/3)
+		err := l.reg.Claim(renewCtx, l.ID, l.holder, l.ttl)
+		cancel()
+		switch {
+		case err == nil:
+			renewed = time.Now()
+		case errors.Is(err, ErrInUse):
+			slog.Error("Sonyflake machine ID taken over by another instance", "machine_id", l.ID)
+			close(l.lost)
+			return
+		case ctx.Err() != nil:
+		case time.Since(renewed) > l.ttl:
+			slog.Error("Sonyflake machine ID lease expired without renewal", "machine_id", l.ID, "since", time.Since(renewed), "error", err)
+			close(l.lost)
+			return
+		default:
+			slog.Warn("failed to renew Sonyflake machine ID lease", "machine_id", l.ID, "error", err)
+		}
+	}
+}
+
+// Lost is closed when another instance takes over the ID, or may have
+// because the lease could not be renewed for a whole TTL. The holder must
+// stop generating IDs; GeneratorOptions.Lost does that for a Generator.
+func (l *Lease) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Close stops renewing and releases the ID.
+func (l *Lease) Close(ctx context.Context) error {
+	if l.reg == nil {
+		return nil
+	}
+	var err error
+	l.once.Do(func() {
+		l.stop()
+		<-l.done
+		err = l.reg.Release(ctx, l.ID, l.holder)
+	})
+	return err
+}
+
+// NewHolder returns a name for this process that is unique across restarts:
+// the hostname, PID and a random suffix.
+func NewHolder() string {
+	host, _ := os.Hostname()
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return fmt.Sprintf("%s/%d/%s", host, os.Getpid(), hex.EncodeToString(b[:]))
+}
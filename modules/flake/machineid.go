@@ -0,0 +1,163 @@
+package flake
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Sources of the machine ID, as named in SONYFLAKE_MACHINE_ID_SOURCE.
+const (
+	SourceEnv       = "env"
+	SourcePrivateIP = "private-ip"
+	SourceECS       = "ecs"
+	SourceRedis     = "redis"
+	SourcePostgres  = "postgres"
+)
+
+// MachineIDFromEnv reads SONYFLAKE_MACHINE_ID.
+func MachineIDFromEnv() (uint16, error) {
+	raw := os.Getenv("SONYFLAKE_MACHINE_ID")
+	if raw == "" {
+		return 0, errors.New("SONYFLAKE_MACHINE_ID is not set")
+	}
+	id, err := strconv.ParseUint(raw, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("SONYFLAKE_MACHINE_ID: %w", err)
+	}
+	return uint16(id), nil
+}
+
+// MachineIDFromPrivateIP uses the low 16 bits of the first private IPv4
+// address on the host's interfaces. IDs are unique while every instance sits
+// in the same /16.
+func MachineIDFromPrivateIP() (uint16, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return 0, err
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			if id, ok := machineIDFromIP(ipNet.IP); ok {
+				return id, nil
+			}
+		}
+	}
+	return 0, errors.New("no private IPv4 address found")
+}
+
+func machineIDFromIP(ip net.IP) (uint16, bool) {
+	ip4 := ip.To4()
+	if ip4 == nil || !ip4.IsPrivate() {
+		return 0, false
+	}
+	return uint16(ip4[2])<<8 | uint16(ip4[3]), true
+}
+
+// ecsMetadata is the part of the ECS container metadata, from the task
+// metadata endpoint or the metadata file, that carries the task's addresses.
+type ecsMetadata struct {
+	MetadataFileStatus string `json:"MetadataFileStatus"`
+	Networks           []struct {
+		NetworkMode   string   `json:"NetworkMode"`
+		IPv4Addresses []string `json:"IPv4Addresses"`
+	} `json:"Networks"`
+}
+
+// ecsFilePollInterval is how often a metadata file that is not ready yet is
+// re-read.
+const ecsFilePollInterval = 500 * time.Millisecond
+
+// MachineIDFromECS derives the ID from the task's own private IPv4 address,
+// read from the task metadata endpoint (ECS_CONTAINER_METADATA_URI_V4, set on
+// Fargate) or else the container metadata file (ECS_CONTAINER_METADATA_FILE,
+// on EC2 with ECS_ENABLE_CONTAINER_METADATA). Only awsvpc tasks have an
+// address of their own; other network modes share the host's.
+func MachineIDFromECS(ctx context.Context, client *http.Client) (uint16, error) {
+	var md *ecsMetadata
+	var err error
+	if uri := os.Getenv("ECS_CONTAINER_METADATA_URI_V4"); uri != "" {
+		md, err = fetchECSMetadata(ctx, client, uri)
+	} else if path := os.Getenv("ECS_CONTAINER_METADATA_FILE"); path != "" {
+		md, err = readECSMetadataFile(ctx, path)
+	} else {
+		return 0, errors.New("neither ECS_CONTAINER_METADATA_URI_V4 nor ECS_CONTAINER_METADATA_FILE is set")
+	}
+	if err != nil {
+		return 0, err
+	}
+	return md.machineID()
+}
+
+func (md *ecsMetadata) machineID() (uint16, error) {
+	for _, n := range md.Networks {
+		if n.NetworkMode != "awsvpc" {
+			continue
+		}
+		for _, addr := range n.IPv4Addresses {
+			if id, ok := machineIDFromIP(net.ParseIP(addr)); ok {
+				return id, nil
+			}
+		}
+	}
+	return 0, errors.New("ECS metadata has no private awsvpc IPv4 address; use a redis or postgres lease instead")
+}
+
+func fetchECSMetadata(ctx context.Context, client *http.Client, uri string) (*ecsMetadata, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ECS task metadata: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ECS task metadata: %s", resp.Status)
+	}
+	var md ecsMetadata
+	if err := json.NewDecoder(resp.Body).Decode(&md); err != nil {
+		return nil, fmt.Errorf("ECS task metadata: %w", err)
+	}
+	return &md, nil
+}
+
+// readECSMetadataFile waits for the agent to mark the file READY, which can
+// take a few seconds after the container starts.
+func readECSMetadataFile(ctx context.Context, path string) (*ecsMetadata, error) {
+	for {
+		md, err := parseECSMetadataFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if md.MetadataFileStatus == "READY" {
+			return md, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("ECS metadata file %s not ready: %w", path, ctx.Err())
+		case <-time.After(ecsFilePollInterval):
+		}
+	}
+}
+
+func parseECSMetadataFile(path string) (*ecsMetadata, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var md ecsMetadata
+	// The agent rewrites the file in place, so a partial read is retried as
+	// not ready rather than failing.
+	if err := json.Unmarshal(data, &md); err != nil {
+		return &ecsMetadata{}, nil
+	}
+	return &md, nil
+}
@@ -0,0 +1,82 @@
+package flake
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// RedisRegistry keeps each claim in a key that expires with it.
+type RedisRegistry struct {
+	Client *redis.Client
+}
+
+// redisKeyPrefix namespaces claim keys away from link codes, which may not
+// contain ':'.
+const redisKeyPrefix = "sonyflake:machine:"
+
+var redisClaim = redis.NewScript(`
+local holder = redis.call("GET", KEYS[1])
+if holder and holder ~= ARGV[1] then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+return 1
+`)
+
+var redisRelease = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+func (r RedisRegistry) Claim(ctx context.Context, id uint16, holder string, ttl time.Duration) error {
+	ok, err := redisClaim.Run(ctx, r.Client, []string{redisKey(id)}, holder, ttl.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrInUse
+	}
+	return nil
+}
+
+func (r RedisRegistry) Release(ctx context.Context, id uint16, holder string) error {
+	return redisRelease.Run(ctx, r.Client, []string{redisKey(id)}, holder).Err()
+}
+
+func redisKey(id uint16) string {
+	return redisKeyPrefix + strconv.Itoa(int(id))
+}
+
+// PostgresRegistry keeps claims in the sonyflake_machine_ids table created by
+// the migrations. Expiry is judged by the database clock, so instance clocks
+// do not need to agree.
+type PostgresRegistry struct {
+	Pool *pgxpool.Pool
+}
+
+func (r PostgresRegistry) Claim(ctx context.Context, id uint16, holder string, ttl time.Duration) error {
+	const stmt = `INSERT INTO sonyflake_machine_ids (machine_id, holder, expires_at)
+		VALUES ($1, $2, now() + $3 * interval '1 millisecond')
+		ON CONFLICT (machine_id) DO UPDATE SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at
+		WHERE sonyflake_machine_ids.holder = EXCLUDED.holder OR sonyflake_machine_ids.expires_at < now()
+		RETURNING machine_id`
+	var claimed int32
+	err := r.Pool.QueryRow(ctx, stmt, int32(id), holder, ttl.Milliseconds()).Scan(&claimed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrInUse
+	}
+	return err
+}
+
+func (r PostgresRegistry) Release(ctx context.Context, id uint16, holder string) error {
+	_, err := r.Pool.Exec(ctx, `DELETE FROM sonyflake_machine_ids WHERE machine_id = $1 AND holder = $2`, int32(id), holder)
+	return err
+}
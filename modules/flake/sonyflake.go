@@ -2,33 +2,86 @@
 package flake
 
 import (
-	"os"
-	"strconv"
+	"context"
+	"fmt"
+	"net/http"
 	"time"
-
-	"github.com/sony/sonyflake"
 )
 
-func NewSonyflake() *sonyflake.Sonyflake {
-    startTime := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
-
-    machineIDFunc := func() (uint16, error) {
-        raw := os.Getenv("SONYFLAKE_MACHINE_ID")
-        if raw == "" {
-            return 1, nil
-        }
-        id, err := strconv.ParseUint(raw, 10, 16)
-        return uint16(id), err
-    }
-
-    settings := sonyflake.Settings{
-        StartTime: startTime,
-        MachineID: machineIDFunc,
-    }
-
-    fl := sonyflake.NewSonyflake(settings)
-    if fl == nil {
-        panic("failed to initialize Sonyflake")
-    }
-    return fl
+var startTime = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// NewSonyflake reads the machine ID from SONYFLAKE_MACHINE_ID, defaulting to
+// 1. It does not guard against duplicate IDs; servers use ClaimMachineID and
+// NewGenerator instead.
+func NewSonyflake() *Generator {
+	id, err := MachineIDFromEnv()
+	if err != nil {
+		id = 1
+	}
+	fl, err := NewGenerator(id, GeneratorOptions{})
+	if err != nil {
+		panic(err)
+	}
+	return fl
+}
+
+// MachineIDOptions says where ClaimMachineID gets the ID and how it checks
+// that no other instance has it.
+type MachineIDOptions struct {
+	// Source is one of the Source constants.
+	Source string
+	// Check is the registry a static ID (env, private-ip, ecs) is claimed in:
+	// redis, postgres or none. Lease sources are checked by their own store.
+	Check string
+	// TTL is how long a claim outlives its last renewal.
+	TTL time.Duration
+
+	Redis    RedisRegistry
+	Postgres PostgresRegistry
+	// HTTPClient reads the ECS task metadata endpoint.
+	HTTPClient *http.Client
+}
+
+// ClaimMachineID resolves this instance's machine ID and claims it. It fails
+// with ErrInUse if a static ID is already held by another live instance.
+func ClaimMachineID(ctx context.Context, opts MachineIDOptions) (*Lease, error) {
+	holder := NewHolder()
+	switch opts.Source {
+	case SourceRedis:
+		return Acquire(ctx, opts.Redis, holder, opts.TTL)
+	case SourcePostgres:
+		return Acquire(ctx, opts.Postgres, holder, opts.TTL)
+	}
+
+	var id uint16
+	var err error
+	switch opts.Source {
+	case SourceEnv:
+		id, err = MachineIDFromEnv()
+	case SourcePrivateIP:
+		id, err = MachineIDFromPrivateIP()
+	case SourceECS:
+		client := opts.HTTPClient
+		if client == nil {
+			client = http.DefaultClient
+		}
+		id, err = MachineIDFromECS(ctx, client)
+	default:
+		return nil, fmt.Errorf("unknown machine ID source %q", opts.Source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var reg Registry
+	switch opts.Check {
+	case SourceRedis:
+		reg = opts.Redis
+	case SourcePostgres:
+		reg = opts.Postgres
+	case "none":
+	default:
+		return nil, fmt.Errorf("unknown machine ID check %q", opts.Check)
+	}
+	return Hold(ctx, reg, id, holder, opts.TTL)
 }
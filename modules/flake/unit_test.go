@@ -0,0 +1,482 @@
+package flake
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sony/sonyflake"
+)
+
+// memRegistry is an in-memory Registry. Claims never expire; tests take IDs
+// over by hand with steal.
+type memRegistry struct {
+	mu      sync.Mutex
+	holders map[uint16]string
+	claims  int
+	// down makes Claim fail as if the registry were unreachable.
+	down bool
+}
+
+func newMemRegistry() *memRegistry {
+	return &memRegistry{holders: map[uint16]string{}}
+}
+
+func (r *memRegistry) Claim(_ context.Context, id uint16, holder string, _ time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.claims++
+	if r.down {
+		return errors.New("registry unreachable")
+	}
+	if h, ok := r.holders[id]; ok && h != holder {
+		return ErrInUse
+	}
+	r.holders[id] = holder
+	return nil
+}
+
+func (r *memRegistry) Release(_ context.Context, id uint16, holder string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.holders[id] == holder {
+		delete(r.holders, id)
+	}
+	return nil
+}
+
+func (r *memRegistry) steal(id uint16, holder string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.holders[id] = holder
+}
+
+func (r *memRegistry) holder(id uint16) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h, ok := r.holders[id]
+	return h, ok
+}
+
+func TestMachineIDFromIP(t *testing.T) {
+	tests := []struct {
+		ip     string
+		want   uint16
+		wantOK bool
+	}{
+		{"10.0.1.2", 0x0102, true},
+		{"172.16.255.7", 0xff07, true},
+		{"192.168.0.1", 0x0001, true},
+		{"8.8.8.8", 0, false},
+		{"127.0.0.1", 0, false},
+		{"fd00::1", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := machineIDFromIP(net.ParseIP(tt.ip))
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("machineIDFromIP(%s) = %d, %v; want %d, %v", tt.ip, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestMachineIDFromEnv(t *testing.T) {
+	t.Setenv("SONYFLAKE_MACHINE_ID", "513")
+	if id, err := MachineIDFromEnv(); err != nil || id != 513 {
+		t.Errorf("MachineIDFromEnv() = %d, %v; want 513", id, err)
+	}
+	for _, raw := range []string{"", "65536", "-1", "one"} {
+		t.Setenv("SONYFLAKE_MACHINE_ID", raw)
+		if _, err := MachineIDFromEnv(); err == nil {
+			t.Errorf("SONYFLAKE_MACHINE_ID=%q: expected an error", raw)
+		}
+	}
+}
+
+const awsvpcMetadata = `{"Networks":[{"NetworkMode":"awsvpc","IPv4Addresses":["10.0.3.4"]}]}`
+
+func TestMachineIDFromECSEndpoint(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(awsvpcMetadata))
+	}))
+	defer srv.Close()
+	t.Setenv("ECS_CONTAINER_METADATA_URI_V4", srv.URL)
+
+	id, err := MachineIDFromECS(context.Background(), srv.Client())
+	if err != nil || id != 0x0304 {
+		t.Errorf("MachineIDFromECS() = %d, %v; want %d", id, err, 0x0304)
+	}
+}
+
+func TestMachineIDFromECSFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metadata.json")
+	if err := os.WriteFile(path, []byte(`{"MetadataFileStatus":"NOT_READY"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ECS_CONTAINER_METADATA_URI_V4", "")
+	t.Setenv("ECS_CONTAINER_METADATA_FILE", path)
+
+	// The agent finishes writing the file while the reader is polling.
+	go func() {
+		time.Sleep(ecsFilePollInterval / 2)
+		ready := `{"MetadataFileStatus":"READY","Networks":[{"NetworkMode":"awsvpc","IPv4Addresses":["172.31.9.10"]}]}`
+		os.WriteFile(path, []byte(ready), 0o644)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	id, err := MachineIDFromECS(ctx, nil)
+	if err != nil || id != 0x090a {
+		t.Errorf("MachineIDFromECS() = %d, %v; want %d", id, err, 0x090a)
+	}
+
+	bridge := `{"MetadataFileStatus":"READY","Networks":[{"NetworkMode":"bridge","IPv4Addresses":["172.17.0.2"]}]}`
+	if err := os.WriteFile(path, []byte(bridge), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MachineIDFromECS(ctx, nil); err == nil {
+		t.Error("bridge network mode: expected an error")
+	}
+}
+
+func TestHoldDuplicate(t *testing.T) {
+	reg := newMemRegistry()
+	ctx := context.Background()
+
+	first, err := Hold(ctx, reg, 42, "a", time.Minute)
+	if err != nil {
+		t.Fatalf("Hold failed: %v", err)
+	}
+	if _, err := Hold(ctx, reg, 42, "b", time.Minute); !errors.Is(err, ErrInUse) {
+		t.Errorf("second Hold error = %v; want ErrInUse", err)
+	}
+
+	if err := first.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, ok := reg.holder(42); ok {
+		t.Error("Close did not release the ID")
+	}
+	if _, err := Hold(ctx, reg, 42, "b", time.Minute); err != nil {
+		t.Errorf("Hold after release failed: %v", err)
+	}
+}
+
+func TestHoldWithoutRegistry(t *testing.T) {
+	lease, err := Hold(context.Background(), nil, 7, "a", time.Minute)
+	if err != nil || lease.ID != 7 {
+		t.Fatalf("Hold(nil) = %v, %v; want ID 7", lease, err)
+	}
+	select {
+	case <-lease.Lost():
+		t.Error("lease without a registry was lost")
+	default:
+	}
+	if err := lease.Close(context.Background()); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
+
+func TestAcquireSkipsHeldIDs(t *testing.T) {
+	reg := newMemRegistry()
+	ctx := context.Background()
+
+	seen := map[uint16]bool{}
+	for i := range 10 {
+		lease, err := Acquire(ctx, reg, string(rune('a'+i)), time.Minute)
+		if err != nil {
+			t.Fatalf("Acquire failed: %v", err)
+		}
+		defer lease.Close(ctx)
+		if seen[lease.ID] {
+			t.Fatalf("machine ID %d handed out twice", lease.ID)
+		}
+		seen[lease.ID] = true
+	}
+}
+
+func TestLeaseHeartbeat(t *testing.T) {
+	reg := newMemRegistry()
+	ctx := context.Background()
+
+	lease, err := Hold(ctx, reg, 9, "a", 30*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Hold failed: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	reg.mu.Lock()
+	claims := reg.claims
+	reg.mu.Unlock()
+	if claims < 3 {
+		t.Errorf("%d claims after 100ms with a 30ms TTL; want renewals", claims)
+	}
+
+	reg.steal(9, "b")
+	select {
+	case <-lease.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("lease not lost after another instance took the ID")
+	}
+	lease.Close(ctx)
+	if h, _ := reg.holder(9); h != "b" {
+		t.Errorf("Close released another instance's claim; holder = %q", h)
+	}
+}
+
+func TestLeaseLostWhenRenewalsFail(t *testing.T) {
+	reg := newMemRegistry()
+	ctx := context.Background()
+
+	lease, err := Hold(ctx, reg, 9, "a", 30*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Hold failed: %v", err)
+	}
+	defer lease.Close(ctx)
+	reg.mu.Lock()
+	reg.down = true
+	reg.mu.Unlock()
+	select {
+	case <-lease.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("lease not lost after renewals failed for longer than the TTL")
+	}
+}
+
+func TestClaimMachineID(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("SONYFLAKE_MACHINE_ID", "12")
+
+	lease, err := ClaimMachineID(ctx, MachineIDOptions{Source: SourceEnv, Check: "none", TTL: time.Minute})
+	if err != nil || lease.ID != 12 {
+		t.Fatalf("ClaimMachineID(env, none) = %v, %v; want ID 12", lease, err)
+	}
+	lease.Close(ctx)
+
+	if _, err := ClaimMachineID(ctx, MachineIDOptions{Source: "mac", Check: "none"}); err == nil {
+		t.Error("unknown source: expected an error")
+	}
+	if _, err := ClaimMachineID(ctx, MachineIDOptions{Source: SourceEnv, Check: "etcd"}); err == nil {
+		t.Error("unknown check: expected an error")
+	}
+}
+
+func TestNewHolderUnique(t *testing.T) {
+	if a, b := NewHolder(), NewHolder(); a == b {
+		t.Errorf("NewHolder returned %q twice", a)
+	}
+}
+
+// fakeClock stands still until a test or Sleep moves it.
+type fakeClock struct {
+	now   time.Time
+	slept time.Duration
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.slept += d
+	c.now = c.now.Add(d)
+}
+
+// fixedIDs is a fallback that counts how often it is asked.
+type fixedIDs struct{ calls int }
+
+func (f *fixedIDs) NextID() (uint64, error) {
+	f.calls++
+	return 1 << 62, nil
+}
+
+func newTestGenerator(t *testing.T, clock *fakeClock, fallback IDSource) (*Generator, *Metrics) {
+	t.Helper()
+	m := NewMetrics(nil)
+	opts := GeneratorOptions{Clock: clock, Metrics: m}
+	if fallback != nil {
+		opts.Fallback = fallback
+	}
+	g, err := NewGenerator(7, opts)
+	if err != nil {
+		t.Fatalf("NewGenerator failed: %v", err)
+	}
+	return g, m
+}
+
+func nextID(t *testing.T, g *Generator) uint64 {
+	t.Helper()
+	id, err := g.NextID()
+	if err != nil {
+		t.Fatalf("NextID failed: %v", err)
+	}
+	return id
+}
+
+func TestGeneratorLayout(t *testing.T) {
+	clock := &fakeClock{now: startTime.Add(90 * time.Second)}
+	g, _ := newTestGenerator(t, clock, nil)
+
+	id := nextID(t, g)
+	if got := sonyflake.ElapsedTime(id); got != 90*time.Second {
+		t.Errorf("ElapsedTime = %s; want 90s", got)
+	}
+	if got := sonyflake.MachineID(id); got != 7 {
+		t.Errorf("MachineID = %d; want 7", got)
+	}
+	if got := sonyflake.SequenceNumber(nextID(t, g)); got != 1 {
+		t.Errorf("second ID in the slot has sequence %d; want 1", got)
+	}
+}
+
+func TestGeneratorStopsWhenLeaseLost(t *testing.T) {
+	lost := make(chan struct{})
+	g, err := NewGenerator(7, GeneratorOptions{
+		Lost:     lost,
+		Fallback: RandomIDs{},
+		Clock:    &fakeClock{now: startTime.Add(time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("NewGenerator failed: %v", err)
+	}
+	nextID(t, g)
+
+	close(lost)
+	if _, err := g.NextID(); !errors.Is(err, ErrMachineIDLost) {
+		t.Errorf("NextID after the lease was lost = %v; want ErrMachineIDLost", err)
+	}
+}
+
+func TestGeneratorSequenceExhausted(t *testing.T) {
+	clock := &fakeClock{now: startTime.Add(time.Hour + 3*time.Millisecond)}
+	g, m := newTestGenerator(t, clock, nil)
+
+	var last uint64
+	for i := range maxSequence + 2 {
+		id := nextID(t, g)
+		if id <= last {
+			t.Fatalf("ID %d is %d, not above %d", i, id, last)
+		}
+		last = id
+	}
+	if clock.slept != 7*time.Millisecond {
+		t.Errorf("slept %s; want 7ms, to the start of the next slot", clock.slept)
+	}
+	if got := testutil.ToFloat64(m.SequenceExhausted); got != 1 {
+		t.Errorf("sequence_exhausted_total = %v; want 1", got)
+	}
+	if got := sonyflake.ElapsedTime(last); got != time.Hour+10*time.Millisecond {
+		t.Errorf("last ID elapsed time = %s; want the next slot", got)
+	}
+}
+
+func TestGeneratorClockBackwardsWithinMaxWait(t *testing.T) {
+	clock := &fakeClock{now: startTime.Add(time.Hour)}
+	g, m := newTestGenerator(t, clock, nil)
+
+	last := nextID(t, g)
+	clock.now = clock.now.Add(-50 * time.Millisecond)
+	for range maxSequence + 1 {
+		id := nextID(t, g)
+		if id <= last {
+			t.Fatalf("ID %d after the clock moved back is not above %d", id, last)
+		}
+		last = id
+	}
+	if got := testutil.ToFloat64(m.ClockBackwards); got != 1 {
+		t.Errorf("clock_backwards_total = %v; want 1", got)
+	}
+	if clock.slept != 60*time.Millisecond {
+		t.Errorf("slept %s; want 60ms, until the clock passed the last ID", clock.slept)
+	}
+}
+
+func TestGeneratorClockBackwardsBeyondMaxWait(t *testing.T) {
+	for _, withFallback := range []bool{false, true} {
+		clock := &fakeClock{now: startTime.Add(2 * time.Hour)}
+		fallback := &fixedIDs{}
+		var src IDSource
+		if withFallback {
+			src = fallback
+		}
+		g, m := newTestGenerator(t, clock, src)
+
+		nextID(t, g)
+		clock.now = clock.now.Add(-time.Hour)
+		// The slot still has room, so the first IDs need no wait.
+		for range maxSequence {
+			nextID(t, g)
+		}
+		id, err := g.NextID()
+		if clock.slept != 0 {
+			t.Errorf("slept %s for a clock an hour behind", clock.slept)
+		}
+		if !withFallback {
+			if !errors.Is(err, ErrClockBackwards) {
+				t.Errorf("NextID error = %v; want ErrClockBackwards", err)
+			}
+			continue
+		}
+		if err != nil || id != 1<<62 || fallback.calls != 1 {
+			t.Errorf("NextID = %d, %v after %d fallback calls; want the fallback ID", id, err, fallback.calls)
+		}
+		if got := testutil.ToFloat64(m.FallbackIDs.WithLabelValues(reasonClockBackwards)); got != 1 {
+			t.Errorf("fallback_ids_total{reason=clock_backwards} = %v; want 1", got)
+		}
+
+		// Once the clock passes the last ID, Sonyflake IDs resume.
+		clock.now = clock.now.Add(time.Hour + timeUnit)
+		if id := nextID(t, g); id == 1<<62 || sonyflake.MachineID(id) != 7 {
+			t.Errorf("ID after the clock caught up = %d; want a Sonyflake ID", id)
+		}
+	}
+}
+
+func TestGeneratorLifetime(t *testing.T) {
+	end := startTime.Add(time.Duration(maxElapsed) * timeUnit)
+	clock := &fakeClock{now: end}
+	g, m := newTestGenerator(t, clock, nil)
+	if got := testutil.ToFloat64(m.LifetimeRemaining); got != timeUnit.Seconds() {
+		t.Errorf("lifetime_remaining_seconds = %v; want one slot", got)
+	}
+	nextID(t, g)
+
+	clock.now = end.Add(timeUnit)
+	if _, err := g.NextID(); !errors.Is(err, ErrLifetimeExceeded) {
+		t.Errorf("NextID error = %v; want ErrLifetimeExceeded", err)
+	}
+	if got := testutil.ToFloat64(m.LifetimeRemaining); got != 0 {
+		t.Errorf("lifetime_remaining_seconds = %v; want 0", got)
+	}
+
+	fallback := &fixedIDs{}
+	g, m = newTestGenerator(t, clock, fallback)
+	if id := nextID(t, g); id != 1<<62 {
+		t.Errorf("NextID = %d; want the fallback ID", id)
+	}
+	if got := testutil.ToFloat64(m.FallbackIDs.WithLabelValues(reasonLifetime)); got != 1 {
+		t.Errorf("fallback_ids_total{reason=lifetime} = %v; want 1", got)
+	}
+}
+
+func TestNewGeneratorBeforeStartTime(t *testing.T) {
+	clock := &fakeClock{now: startTime.Add(-time.Second)}
+	if _, err := NewGenerator(1, GeneratorOptions{Clock: clock}); err == nil {
+		t.Error("expected an error for a clock before the start time")
+	}
+}
+
+func TestRandomIDs(t *testing.T) {
+	for range 100 {
+		id, err := RandomIDs{}.NextID()
+		if err != nil {
+			t.Fatalf("NextID failed: %v", err)
+		}
+		if id >= 1<<63 {
+			t.Fatalf("ID %d does not fit in 63 bits", id)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Package geoip finds the country of an IP address in a local database, so
+// lookups add no network round trip to a redirect.
+package geoip
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Locator finds the country of an IP address. Implementations are safe for
+// concurrent use.
+type Locator interface {
+	// Country returns the ISO 3166-1 alpha-2 code of addr's country, or
+	// false if it is unknown.
+	Country(addr netip.Addr) (string, bool)
+}
+
+// Ranges is a Locator over sorted, non-overlapping address ranges.
+type Ranges struct {
+	ranges []ipRange
+}
+
+type ipRange struct {
+	start, end netip.Addr
+	country    string
+}
+
+// LoadCSV reads a country database in the CSV layout of the free DB-IP and
+// IP2Location LITE downloads: rows of start address, end address and
+// country code, with any further columns ignored. Addresses may be written
+// as IPs or, as IP2Location does, as decimal integers.
+func LoadCSV(path string) (*Ranges, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := ReadCSV(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+// ReadCSV reads a country database as described for LoadCSV. A first row
+// that is not a range is taken for a header.
+func ReadCSV(r io.Reader) (*Ranges, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	var ranges []ipRange
+	for line := 1; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rng, err := parseRange(record)
+		if err != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if rng.country != "" {
+			ranges = append(ranges, rng)
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start.Less(ranges[j].start) })
+	for i := 1; i < len(ranges); i++ {
+		if !ranges[i-1].end.Less(ranges[i].start) {
+			return nil, fmt.Errorf("range %s-%s overlaps %s-%s", ranges[i].start, ranges[i].end, ranges[i-1].start, ranges[i-1].end)
+		}
+	}
+	return &Ranges{ranges: ranges}, nil
+}
+
+func parseRange(record []string) (ipRange, error) {
+	if len(record) < 3 {
+		return ipRange{}, fmt.Errorf("want at least 3 columns, got %d", len(record))
+	}
+	start, err := parseAddr(record[0])
+	if err != nil {
+		return ipRange{}, err
+	}
+	end, err := parseAddr(record[1])
+	if err != nil {
+		return ipRange{}, err
+	}
+	if start.Is4() != end.Is4() || end.Less(start) {
+		return ipRange{}, fmt.Errorf("invalid range %s-%s", start, end)
+	}
+	country := strings.ToUpper(strings.TrimSpace(record[2]))
+	switch {
+	case country == "-" || country == "ZZ":
+		// Reserved and unallocated ranges.
+		country = ""
+	case len(country) != 2:
+		return ipRange{}, fmt.Errorf("invalid country code %q", record[2])
+	}
+	return ipRange{start: start, end: end, country: country}, nil
+}
+
+// parseAddr reads an address written as an IP or as a decimal integer.
+// Integers up to 2^32-1 are IPv4, as IP2Location's IPv4 database has them.
+func parseAddr(s string) (netip.Addr, error) {
+	s = strings.TrimSpace(s)
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.Unmap(), nil
+	}
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok || n.Sign() < 0 || n.BitLen() > 128 {
+		return netip.Addr{}, fmt.Errorf("invalid address %q", s)
+	}
+	var b [16]byte
+	n.FillBytes(b[:])
+	if n.BitLen() <= 32 {
+		return netip.AddrFrom4([4]byte(b[12:])), nil
+	}
+	// IP2Location's IPv6 database writes IPv4 as IPv4-mapped addresses.
+	return netip.AddrFrom16(b).Unmap(), nil
+}
+
+// Country implements Locator.
+func (r *Ranges) Country(addr netip.Addr) (string, bool) {
+	addr = addr.Unmap()
+	i := sort.Search(len(r.ranges), func(i int) bool { return !r.ranges[i].end.Less(addr) })
+	if i == len(r.ranges) || addr.Less(r.ranges[i].start) {
+		return "", false
+	}
+	return r.ranges[i].country, true
+}
+
+// Len is the number of ranges with a country.
+func (r *Ranges) Len() int {
+	return len(r.ranges)
+}
@@ -0,0 +1,82 @@
+package geoip
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadCSV(t *testing.T) {
+	const db = `ip_start,ip_end,country
+1.0.0.0,1.0.0.255,AU
+"16777472","16778239","cn","China"
+8.8.8.0,8.8.8.255,us
+10.0.0.0,10.255.255.255,ZZ
+2001:4860::,2001:4860:ffff:ffff:ffff:ffff:ffff:ffff,US
+281470833330432,281470833330687,CH
+`
+	r, err := ReadCSV(strings.NewReader(db))
+	if err != nil {
+		t.Fatalf("ReadCSV failed: %v", err)
+	}
+	if r.Len() != 5 {
+		t.Errorf("Len = %d; want 5 ranges with a country", r.Len())
+	}
+	tests := []struct {
+		addr   string
+		want   string
+		wantOK bool
+	}{
+		{"1.0.0.1", "AU", true},
+		{"1.0.1.5", "CN", true}, // decimal 16777472 is 1.0.1.0
+		{"8.8.8.8", "US", true},
+		{"::ffff:8.8.8.8", "US", true},
+		{"2001:4860:4860::8888", "US", true},
+		{"1.0.0.0", "AU", true},
+		{"9.9.9.9", "CH", true}, // IPv4-mapped in decimal
+		{"1.0.4.0", "", false},
+		{"10.1.2.3", "", false},
+		{"192.168.0.1", "", false},
+		{"::1", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := r.Country(netip.MustParseAddr(tt.addr))
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("Country(%s) = %q, %v; want %q, %v", tt.addr, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestReadCSVRejectsBadRows(t *testing.T) {
+	for _, db := range []string{
+		"1.0.0.0,1.0.0.255,AU\n1.0.0.128,1.0.1.255,CN\n",
+		"1.0.0.0,1.0.0.255,AU\nnot-an-ip,1.0.1.255,CN\n",
+		"1.0.0.0,1.0.0.255,AU\n1.0.2.0,1.0.1.0,CN\n",
+		"1.0.0.0,1.0.0.255,AU\n1.0.2.0,1.0.2.255,Australia\n",
+		"1.0.0.0,1.0.0.255,AU\n1.0.2.0,1.0.2.255\n",
+	} {
+		if _, err := ReadCSV(strings.NewReader(db)); err == nil {
+			t.Errorf("ReadCSV(%q) succeeded", db)
+		}
+	}
+}
+
+func TestLoadCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "countries.csv")
+	if err := os.WriteFile(path, []byte("8.8.8.0,8.8.8.255,US\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := LoadCSV(path)
+	if err != nil {
+		t.Fatalf("LoadCSV failed: %v", err)
+	}
+	var _ Locator = r
+	if got, _ := r.Country(netip.MustParseAddr("8.8.8.8")); got != "US" {
+		t.Errorf("Country(8.8.8.8) = %q; want US", got)
+	}
+	if _, err := LoadCSV(filepath.Join(t.TempDir(), "missing.csv")); err == nil {
+		t.Error("LoadCSV of a missing file succeeded")
+	}
+}
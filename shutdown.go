@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"google.golang.org/grpc"
+)
+
+// shutdownHooks collects cleanup work registered as the server starts up.
+// Hooks run in reverse registration order so that anything flushing into a
+// dependency (e.g. buffered writes into Redis) finishes before that
+// dependency is closed.
+type shutdownHooks struct {
+	names []string
+	fns   []func(context.Context) error
+}
+
+func (h *shutdownHooks) add(name string, fn func(context.Context) error) {
+	h.names = append(h.names, name)
+	h.fns = append(h.fns, fn)
+}
+
+func (h *shutdownHooks) run(ctx context.Context) {
+	for i := len(h.fns) - 1; i >= 0; i-- {
+		if err := h.fns[i](ctx); err != nil {
+			slog.Error("shutdown hook failed", "hook", h.names[i], "error", err)
+		}
+	}
+}
+
+// drainServers stops both listeners from accepting new connections and waits
+// for in-flight requests to finish. The HTTP server goes first: the gateway,
+// Connect and export handlers call the service over loopback gRPC, so their
+// requests would fail once the gRPC server stops accepting. If the deadline
+// passes first, remaining HTTP connections are closed and gRPC streams are
+// cancelled.
+func drainServers(ctx context.Context, httpServer *http.Server, grpcServer *grpc.Server) {
+	if err := httpServer.Shutdown(ctx); err != nil {
+		slog.Warn("HTTP server did not drain in time", "error", err)
+		httpServer.Close()
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		slog.Warn("gRPC server did not drain in time; forcing stop", "error", ctx.Err())
+		grpcServer.Stop()
+	}
+}
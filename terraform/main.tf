@@ -201,6 +201,14 @@ resource "aws_security_group" "app" {
     security_groups = [aws_security_group.alb.id]
   }
 
+  ingress {
+    description     = "Admin health checks from ALB"
+    from_port       = 9090
+    to_port         = 9090
+    protocol        = "tcp"
+    security_groups = [aws_security_group.alb.id]
+  }
+
   egress {
     from_port   = 0
     to_port     = 0
@@ -460,8 +468,8 @@ resource "aws_lb_target_group" "web" {
     healthy_threshold   = 2
     interval            = 30
     matcher             = "200"
-    path                = "/"
-    port                = "traffic-port"
+    path                = "/readyz"
+    port                = "9090"
     protocol            = "HTTP"
     timeout             = 5
     unhealthy_threshold = 2
@@ -544,6 +552,10 @@ resource "aws_ecs_task_definition" "app" {
         {
           containerPort = 50051
           protocol      = "tcp"
+        },
+        {
+          containerPort = 9090
+          protocol      = "tcp"
         }
       ]
       environment = [
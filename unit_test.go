@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/JohnBPerkins/url-shortener/gen"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestImportReader(t *testing.T) {
+	input := strings.Join([]string{
+		"url,code,owner,expires_at",
+		"https://example.com/a,abc,,2030-01-01T00:00:00Z",
+		`"https://example.com/b",def,sales,`,
+		"https://example.com/c,ghi,,tomorrow",
+		"https://example.com/d,jkl",
+	}, "\n")
+	ir := newImportReader(strings.NewReader(input), "marketing")
+
+	links, lines, done := ir.next(2)
+	if done || len(links) != 2 {
+		t.Fatalf("first batch = %d links, done %v; want 2 links", len(links), done)
+	}
+	if links[0].GetCode() != "abc" || links[0].GetUrl() != "https://example.com/a" || links[0].GetOwner() != "marketing" {
+		t.Errorf("links[0] = %v", links[0])
+	}
+	if want := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC); !links[0].GetExpiresAt().AsTime().Equal(want) {
+		t.Errorf("links[0] expires_at = %v; want %v", links[0].GetExpiresAt().AsTime(), want)
+	}
+	if links[1].GetOwner() != "sales" || links[1].ExpiresAt != nil {
+		t.Errorf("links[1] = %v; want owner sales and no expiry", links[1])
+	}
+	if lines[0] != 2 || lines[1] != 3 {
+		t.Errorf("lines = %v; want [2 3]", lines)
+	}
+
+	links, lines, done = ir.next(2)
+	if !done || len(links) != 1 || links[0].GetCode() != "jkl" || lines[0] != 5 {
+		t.Errorf("second batch = %v at lines %v, done %v; want jkl at line 5", links, lines, done)
+	}
+	if len(ir.errs) != 1 || !strings.HasPrefix(ir.errs[0], "line 4: expires_at") {
+		t.Errorf("errs = %q; want one expires_at error on line 4", ir.errs)
+	}
+}
+
+func TestImportReaderWithoutHeader(t *testing.T) {
+	ir := newImportReader(strings.NewReader("abc,https://example.com/a,2024-01-01T00:00:00Z\n"), "")
+	links, _, _ := ir.next(10)
+	if len(links) != 1 || links[0].GetCode() != "abc" || links[0].CreatedAt == nil {
+		t.Fatalf("links = %v; want abc with created_at", links)
+	}
+}
+
+func TestImportReaderReadsExport(t *testing.T) {
+	input := "code,url,owner,created_at,expires_at,password_hash,max_clicks,clicks,not_before,not_after,fallback_url,disabled_at,rules\n" +
+		`abc,https://example.com/a,,2025-01-02T03:04:05Z,2025-01-03T03:04:05Z,$2a$10$hash,5,2,2025-01-02T04:00:00Z,,https://example.com/gone,2025-01-02T05:00:00Z,` +
+		`"[{""platforms"":[""PLATFORM_IOS""],""url"":""https://apps.apple.com/app/id1""}]"` + "\n"
+	ir := newImportReader(strings.NewReader(input), "")
+	links, _, _ := ir.next(10)
+	if len(links) != 1 || len(ir.errs) != 0 {
+		t.Fatalf("links = %v, errs = %q; want one link", links, ir.errs)
+	}
+	l := links[0]
+	if l.GetPasswordHash() != "$2a$10$hash" || l.GetMaxClicks() != 5 || l.GetClicks() != 2 || l.GetFallbackUrl() != "https://example.com/gone" {
+		t.Errorf("link = %v; want the password hash, click counts and fallback URL", l)
+	}
+	if l.NotBefore == nil || l.NotAfter != nil || l.DisabledAt == nil {
+		t.Errorf("link = %v; want not_before and disabled_at but no not_after", l)
+	}
+	if len(l.GetRules()) != 1 || l.GetRules()[0].GetUrl() != "https://apps.apple.com/app/id1" ||
+		len(l.GetRules()[0].GetPlatforms()) != 1 || l.GetRules()[0].GetPlatforms()[0] != pb.Platform_PLATFORM_IOS {
+		t.Errorf("rules = %v; want the iOS rule", l.GetRules())
+	}
+}
+
+func TestDrainServersStopsHTTPBeforeGRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	grpcServer := grpc.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, health.NewServer())
+	go grpcServer.Serve(lis)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	// Like the gateway, the handler calls the gRPC server over loopback, and
+	// only does so once draining has begun.
+	started, release := make(chan struct{}), make(chan struct{})
+	httpServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		if _, err := client.Check(r.Context(), &healthpb.HealthCheckRequest{}); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+	}))
+	httpServer.Start()
+	defer httpServer.Close()
+
+	result := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(httpServer.URL)
+		if err != nil {
+			result <- 0
+			return
+		}
+		resp.Body.Close()
+		result <- resp.StatusCode
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	drained := make(chan struct{})
+	go func() {
+		drainServers(ctx, httpServer.Config, grpcServer)
+		close(drained)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	if code := <-result; code != http.StatusOK {
+		t.Errorf("in-flight request during drain = %d; want 200", code)
+	}
+	<-drained
+}